@@ -0,0 +1,102 @@
+// Package cli implements the commands of the binary, they are clients of the API of a running server.
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes of the commands.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+// clientTimeout limits the requests of the commands to the API.
+const clientTimeout = time.Minute
+
+const usage = `usage: %s <command> [flags] [args]
+
+commands:
+  manifest plan <file>     show the changes applying the manifest would make
+  manifest apply <file>    make the healthchecks match the manifest
+  import postman <file>    create healthchecks from the requests of a Postman collection
+
+The server is given by -server or $HEALTHCHECK_SERVER and the API key by -api-key or $HEALTHCHECK_API_KEY.
+`
+
+// Run runs the command of the arguments and returns the exit code, name is the name of the binary.
+func Run(name string, args []string) int {
+	if len(args) >= 2 && args[0] == "manifest" && (args[1] == "plan" || args[1] == "apply") {
+		return runManifest(args[1], args[2:])
+	}
+	if len(args) >= 2 && args[0] == "import" && args[1] == "postman" {
+		return runImportPostman(args[2:])
+	}
+
+	fmt.Fprintf(os.Stderr, usage, name)
+	return exitUsage
+}
+
+// client sends the requests of a command to the API.
+type client struct {
+	server string
+	apiKey string
+	http   *http.Client
+}
+
+// newFlags returns the flag set of a command with the flags of the client.
+func newFlags(command string, c *client) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	server := os.Getenv("HEALTHCHECK_SERVER")
+	if server == "" {
+		server = "http://localhost:8080"
+	}
+	flags.StringVar(&c.server, "server", server, "url of the server")
+	flags.StringVar(&c.apiKey, "api-key", os.Getenv("HEALTHCHECK_API_KEY"), "API key sent in the X-API-Key header")
+	c.http = &http.Client{Timeout: clientTimeout}
+	return flags
+}
+
+// post sends the body to the path and returns the status and the body of the response.
+func (c *client) post(path, contentType string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.server, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+// apiError is the body of the error responses of the API.
+type apiError struct {
+	Message json.RawMessage `json:"message"`
+}
+
+// responseError returns the error of an error response, the message of the body if it has one.
+func responseError(status int, body []byte) error {
+	var e apiError
+	if err := json.Unmarshal(body, &e); err == nil && len(e.Message) > 0 {
+		return fmt.Errorf("%d %s: %s", status, http.StatusText(status), strings.TrimSpace(string(body)))
+	}
+	return fmt.Errorf("%d %s", status, http.StatusText(status))
+}
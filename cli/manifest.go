@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/therealak12/api-health-check/handler"
+)
+
+// manifestSymbols prefix the changes of a plan.
+var manifestSymbols = map[string]string{
+	handler.ManifestCreate: "+",
+	handler.ManifestUpdate: "~",
+	handler.ManifestDelete: "-",
+}
+
+// runManifest sends the manifest file to the plan or apply endpoint and prints the plan, the manifest is
+// JSON if the file has the .json extension and YAML otherwise.
+func runManifest(action string, args []string) int {
+	c := &client{}
+	flags := newFlags("manifest "+action, c)
+	asJSON := flags.Bool("json", false, "print the plan as json")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: manifest %s [flags] <file>\n", action)
+		return exitUsage
+	}
+
+	file := flags.Arg(0)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	contentType := "application/yaml"
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		contentType = "application/json"
+	}
+
+	status, body, err := c.post("/manifest/"+action, contentType, data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	var plan handler.ManifestPlan
+	// an invalid manifest is responded as a plan with the errors of its healthchecks.
+	if (status != http.StatusOK && status != http.StatusBadRequest) || json.Unmarshal(body, &plan) != nil ||
+		plan.Changes == nil {
+		fmt.Fprintln(os.Stderr, responseError(status, body))
+		return exitError
+	}
+
+	if *asJSON {
+		fmt.Println(strings.TrimSpace(string(body)))
+	} else {
+		printPlan(plan)
+	}
+	if status != http.StatusOK {
+		return exitError
+	}
+	return exitOK
+}
+
+func printPlan(plan handler.ManifestPlan) {
+	for _, change := range plan.Changes {
+		line := fmt.Sprintf("%s %s %s", manifestSymbols[change.Action], change.Action, change.ExternalName)
+		if change.ID != 0 {
+			line += fmt.Sprintf(" (id %d)", change.ID)
+		}
+		if len(change.Changes) > 0 {
+			fields := make([]string, 0, len(change.Changes))
+			for field := range change.Changes {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			line += ": " + strings.Join(fields, ", ")
+		}
+		if change.Error != "" {
+			line += " failed: " + change.Error
+		}
+		fmt.Println(line)
+	}
+
+	summary := fmt.Sprintf("%d to change, %d unchanged in project %d", len(plan.Changes), plan.Unchanged,
+		plan.ProjectID)
+	if plan.Applied {
+		summary = fmt.Sprintf("applied, %d changed, %d unchanged in project %d", len(plan.Changes),
+			plan.Unchanged, plan.ProjectID)
+	}
+	fmt.Println(summary)
+}
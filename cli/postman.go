@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/therealak12/api-health-check/handler"
+)
+
+// variableFlags are the repeated -var key=value flags.
+type variableFlags map[string]string
+
+func (v variableFlags) String() string {
+	return fmt.Sprint(map[string]string(v))
+}
+
+func (v variableFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("%q isn't key=value", value)
+	}
+	v[parts[0]] = parts[1]
+	return nil
+}
+
+// runImportPostman sends the collection file, and the environment file if given, to the import endpoint
+// and prints the outcome of each request of the collection.
+func runImportPostman(args []string) int {
+	c := &client{}
+	flags := newFlags("import postman", c)
+	dryRun := flags.Bool("dry-run", false, "show the healthchecks which would be created without creating them")
+	environment := flags.String("environment", "", "postman environment file the variables are resolved from")
+	projectID := flags.Int("project", 0, "project of the healthchecks")
+	interval := flags.Int("interval", 0, "interval of the healthchecks in seconds, 60 if not set")
+	asJSON := flags.Bool("json", false, "print the response as json")
+	variables := variableFlags{}
+	flags.Var(variables, "var", "variable overriding the collection and the environment ones, as key=value")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: import postman [flags] <collection file>")
+		return exitUsage
+	}
+
+	collection, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	body := map[string]interface{}{
+		"collection":      json.RawMessage(collection),
+		"projectId":       *projectID,
+		"intervalSeconds": *interval,
+		"variables":       variables,
+	}
+	if *environment != "" {
+		data, err := os.ReadFile(*environment)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		body["environment"] = json.RawMessage(data)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid json file: %s\n", err)
+		return exitError
+	}
+
+	path := "/import/postman"
+	if *dryRun {
+		path += "?dryRun=true"
+	}
+	status, respBody, err := c.post(path, "application/json", data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	var response handler.ImportResponse
+	if status != http.StatusOK || json.Unmarshal(respBody, &response) != nil {
+		fmt.Fprintln(os.Stderr, responseError(status, respBody))
+		return exitError
+	}
+
+	if *asJSON {
+		fmt.Println(strings.TrimSpace(string(respBody)))
+	} else {
+		printImport(response)
+	}
+	if response.Failed > 0 {
+		return exitError
+	}
+	return exitOK
+}
+
+func printImport(response handler.ImportResponse) {
+	for _, result := range response.Results {
+		switch {
+		case result.Error != "":
+			fmt.Printf("! %s failed: %s\n", result.Item, result.Error)
+		case response.DryRun:
+			fmt.Printf("+ %s: %s %s\n", result.Item, result.Healthcheck.HttpMethod, result.Healthcheck.Url)
+		default:
+			fmt.Printf("+ %s (id %d)\n", result.Item, result.Healthcheck.ID)
+		}
+		for _, warning := range result.Warnings {
+			fmt.Printf("    warning: %s\n", warning)
+		}
+	}
+
+	if response.DryRun {
+		fmt.Printf("%d to import, %d failed\n", response.Imported, response.Failed)
+	} else {
+		fmt.Printf("imported %d, %d failed\n", response.Imported, response.Failed)
+	}
+}
@@ -12,9 +12,18 @@ import (
 
 type (
 	Config struct {
-		Logger   Logger   `koanf:"logger"`
-		Database Database `koanf:"database"`
-		Webhook  Webhook  `koanf:"webhook"`
+		Logger    Logger    `koanf:"logger"`
+		Database  Database  `koanf:"database"`
+		Webhook   Webhook   `koanf:"webhook"`
+		Retention Retention `koanf:"retention"`
+		Events    Events    `koanf:"events"`
+		Probe     Probe     `koanf:"probe"`
+		Tracing   Tracing   `koanf:"tracing"`
+		Watchdog  Watchdog  `koanf:"watchdog"`
+		Heartbeat Heartbeat `koanf:"heartbeat"`
+		Auth      Auth      `koanf:"auth"`
+		CORS      CORS      `koanf:"cors"`
+		Proxy     Proxy     `koanf:"proxy"`
 	}
 
 	Logger struct {
@@ -36,6 +45,102 @@ type (
 		MessageFieldName string        `koanf:"messageFieldName"`
 		Timeout          time.Duration `koanf:"timeout"`
 	}
+
+	// Retention configures how long events are kept, raw events older than RawPeriod are rolled up
+	// into hourly and daily aggregates. Hourly rollups are kept for HourlyPeriod, daily ones forever.
+	Retention struct {
+		Enabled      bool          `koanf:"enabled"`
+		RawPeriod    time.Duration `koanf:"rawPeriod"`
+		HourlyPeriod time.Duration `koanf:"hourlyPeriod"`
+		Interval     time.Duration `koanf:"interval"`
+		BatchSize    int           `koanf:"batchSize"`
+	}
+
+	// Events configures writing events, they are buffered and inserted in batches of BufferSize or
+	// every FlushInterval. Monthly partitions are created PremakeMonths ahead.
+	Events struct {
+		BufferSize    int           `koanf:"bufferSize"`
+		FlushInterval time.Duration `koanf:"flushInterval"`
+		PremakeMonths int           `koanf:"premakeMonths"`
+	}
+
+	// Probe configures the modules of the blackbox exporter compatible probe endpoint.
+	Probe struct {
+		Modules map[string]ProbeModule `koanf:"modules"`
+	}
+
+	// ProbeModule defines the request sent to the probed target, any 2xx status code is valid when
+	// ValidStatusCodes is empty.
+	ProbeModule struct {
+		Method           string            `koanf:"method"`
+		Headers          map[string]string `koanf:"headers"`
+		Body             string            `koanf:"body"`
+		Timeout          time.Duration     `koanf:"timeout"`
+		ValidStatusCodes []int             `koanf:"validStatusCodes"`
+		InsecureSkipTLS  bool              `koanf:"insecureSkipTLS"`
+	}
+
+	// Tracing configures exporting spans to an OTLP/HTTP collector, Endpoint is its traces url.
+	Tracing struct {
+		Enabled       bool          `koanf:"enabled"`
+		Endpoint      string        `koanf:"endpoint"`
+		ServiceName   string        `koanf:"serviceName"`
+		BatchSize     int           `koanf:"batchSize"`
+		FlushInterval time.Duration `koanf:"flushInterval"`
+	}
+
+	// Watchdog configures detecting stalled healthchecks, a healthcheck is stalled when it hasn't finished
+	// the run that was due StallTimeout ago. The watchdog checks every Interval.
+	Watchdog struct {
+		Interval     time.Duration `koanf:"interval"`
+		StallTimeout time.Duration `koanf:"stallTimeout"`
+	}
+
+	// Heartbeat configures pinging an external dead man's switch every Interval, e.g. a healthchecks.io
+	// check url, while the healthchecks are running.
+	Heartbeat struct {
+		Enabled  bool          `koanf:"enabled"`
+		Url      string        `koanf:"url"`
+		Interval time.Duration `koanf:"interval"`
+		Timeout  time.Duration `koanf:"timeout"`
+	}
+
+	// Auth configures authenticating the API requests with API keys and JWT bearer tokens. AdminKey is an
+	// admin API key which isn't stored, it's used to create the first API keys. Authentication is enabled
+	// by default and the server doesn't start without an AdminKey or a JWT secret.
+	Auth struct {
+		Enabled  bool   `koanf:"enabled"`
+		AdminKey string `koanf:"adminKey"`
+		JWT      JWT    `koanf:"jwt"`
+	}
+
+	// JWT configures validating HS256 bearer tokens signed with Secret, Issuer and Audience are checked
+	// when set. The role of the token is its RoleClaim claim, the token is limited to the project id in its
+	// ProjectClaim claim if it has one.
+	JWT struct {
+		Secret       string `koanf:"secret"`
+		Issuer       string `koanf:"issuer"`
+		Audience     string `koanf:"audience"`
+		RoleClaim    string `koanf:"roleClaim"`
+		ProjectClaim string `koanf:"projectClaim"`
+	}
+
+	// CORS configures the cross-origin requests of browser clients such as dashboards, no origin is allowed
+	// unless AllowOrigins is set.
+	CORS struct {
+		AllowOrigins     []string      `koanf:"allowOrigins"`
+		AllowHeaders     []string      `koanf:"allowHeaders"`
+		ExposeHeaders    []string      `koanf:"exposeHeaders"`
+		AllowCredentials bool          `koanf:"allowCredentials"`
+		MaxAge           time.Duration `koanf:"maxAge"`
+	}
+
+	// Proxy configures the reverse proxies the server is behind, the client ip of a request is taken from
+	// the X-Forwarded-For header set by the proxies in the TrustedRanges CIDRs. The ip of the connection is
+	// used when there is none, the header can be forged by the clients.
+	Proxy struct {
+		TrustedRanges []string `koanf:"trustedRanges"`
+	}
 )
 
 var defaultConfig = Config{
@@ -56,6 +161,54 @@ var defaultConfig = Config{
 		MessageFieldName: "message",
 		Timeout:          5,
 	},
+	Retention: Retention{
+		Enabled:      true,
+		RawPeriod:    7 * 24 * time.Hour,
+		HourlyPeriod: 90 * 24 * time.Hour,
+		Interval:     time.Hour,
+		BatchSize:    10000,
+	},
+	Events: Events{
+		BufferSize:    500,
+		FlushInterval: time.Second,
+		PremakeMonths: 3,
+	},
+	Probe: Probe{
+		Modules: map[string]ProbeModule{
+			"http_2xx": {
+				Method:  "GET",
+				Timeout: 5 * time.Second,
+			},
+		},
+	},
+	Tracing: Tracing{
+		Enabled:       false,
+		Endpoint:      "http://localhost:4318/v1/traces",
+		ServiceName:   "api-health-check",
+		BatchSize:     512,
+		FlushInterval: 5 * time.Second,
+	},
+	Watchdog: Watchdog{
+		Interval:     10 * time.Second,
+		StallTimeout: time.Minute,
+	},
+	Heartbeat: Heartbeat{
+		Enabled:  false,
+		Interval: time.Minute,
+		Timeout:  10 * time.Second,
+	},
+	Auth: Auth{
+		Enabled: true,
+		JWT: JWT{
+			RoleClaim:    "role",
+			ProjectClaim: "project",
+		},
+	},
+	CORS: CORS{
+		AllowHeaders:  []string{"Authorization", "Content-Type", "If-Match", "Accept-Language"},
+		ExposeHeaders: []string{"ETag", "X-Total-Count", "Content-Language"},
+		MaxAge:        time.Hour,
+	},
 }
 
 func New() Config {
@@ -10,7 +10,8 @@ import (
 )
 
 func NewPostgresInstance(cfg config.Database) (*gorm.DB, error) {
-	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s",
+	// the session is pinned to UTC so the buckets of date_trunc match the ones truncated in Go.
+	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s TimeZone=UTC",
 		cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.Name)
 	db, err := gorm.Open(postgres.Open(dataSourceName))
 	if err != nil {
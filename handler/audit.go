@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultAuditLimit = 50
+
+// anonymousActor is the actor of the changes made while authentication is disabled.
+const anonymousActor = "anonymous"
+
+// AuditHandler handles the audit log of the changes made through the API.
+type AuditHandler struct {
+	AuditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) AuditHandler {
+	return AuditHandler{
+		AuditService: auditService,
+	}
+}
+
+// AuditPage is a page of the audit log, NextCursor is nil on the last page.
+type AuditPage struct {
+	Entries    []repository.AuditEntry `json:"entries"`
+	NextCursor *int                    `json:"nextCursor"`
+}
+
+// List lists the audit log, the newest entries first unless the order is asc. The requests limited to a
+// project only see its entries.
+func (h AuditHandler) List(c echo.Context) error {
+	req := &request.ListAudit{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("list audit log: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	projectID, err := projectFor(c, req.ProjectID, 0)
+	if err != nil {
+		return err
+	}
+
+	filter := repository.AuditFilter{
+		Actor:        req.Actor,
+		ProjectID:    projectID,
+		Action:       req.Action,
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		From:         req.From,
+		To:           req.To,
+		Cursor:       req.Cursor,
+		Limit:        req.Limit,
+		Ascending:    req.Order == "asc",
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+
+	entries, err := h.AuditService.Find(c.Request().Context(), filter)
+	if err != nil {
+		logrus.Errorf("failed to list audit log: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list audit log")
+	}
+
+	page := AuditPage{Entries: entries}
+	if len(entries) == filter.Limit {
+		page.NextCursor = &entries[len(entries)-1].ID
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// recordAudit records a change made by the request in the audit log, before and after are the states of
+// the resource, nil if it didn't exist. projectID is zero for resources which aren't in a project. ctx is
+// the context of the transaction making the change, so the change isn't committed if it can't be recorded.
+// The errors are http errors.
+func recordAudit(ctx context.Context, c echo.Context, auditService service.AuditService, action,
+	resourceType string, resourceID, projectID int, before, after interface{}) error {
+	entry := repository.AuditEntry{
+		Actor:        anonymousActor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		SourceIP:     c.RealIP(),
+	}
+	if principal, ok := PrincipalFrom(c); ok {
+		entry.Actor = principal.Subject
+	}
+	if projectID != 0 {
+		entry.ProjectID = &projectID
+	}
+
+	if err := auditService.Record(ctx, entry, before, after); err != nil {
+		logrus.Errorf("failed to record %s of %s %d in audit log: %s", action, resourceType, resourceID, err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record audit log")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// principalKey is the key of the principal in the echo context.
+const principalKey = "principal"
+
+// AuthHandler authenticates the requests and manages the API keys.
+type AuthHandler struct {
+	AuthService  service.AuthService
+	ProjectRepo  repository.ProjectRepo
+	AuditService service.AuditService
+	Transactor   repository.Transactor
+	// Enabled is false if the API is open, then every request has all the roles.
+	Enabled bool
+}
+
+// CreatedAPIKey is the response of creating an API key, Key is shown only once.
+type CreatedAPIKey struct {
+	repository.APIKey
+	Key string `json:"key"`
+}
+
+func NewAuthHandler(authService service.AuthService, projectRepo repository.ProjectRepo,
+	auditService service.AuditService, transactor repository.Transactor, enabled bool) AuthHandler {
+	return AuthHandler{
+		AuthService:  authService,
+		ProjectRepo:  projectRepo,
+		AuditService: auditService,
+		Transactor:   transactor,
+		Enabled:      enabled,
+	}
+}
+
+// Require returns a middleware which authenticates the requests and requires the role. The credentials are
+// an API key or a JWT in the Authorization header as a bearer token, or an API key in the X-API-Key header.
+func (h AuthHandler) Require(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !h.Enabled {
+				return next(c)
+			}
+
+			token := c.Request().Header.Get("X-API-Key")
+			if authorization := c.Request().Header.Get(echo.HeaderAuthorization); authorization != "" {
+				const bearer = "bearer "
+				if len(authorization) <= len(bearer) || !strings.EqualFold(authorization[:len(bearer)], bearer) {
+					return unauthorized(c, "unsupported authorization scheme")
+				}
+				token = strings.TrimSpace(authorization[len(bearer):])
+			}
+			if token == "" {
+				return unauthorized(c, "missing credentials")
+			}
+
+			principal, err := h.AuthService.Authenticate(c.Request().Context(), token)
+			if errors.Is(err, service.ErrUnauthenticated) {
+				return unauthorized(c, err.Error())
+			}
+			if err != nil {
+				logrus.Errorf("failed to authenticate request: %s", err)
+
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to authenticate request")
+			}
+			if !principal.Has(role) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("the %s role is required", role))
+			}
+
+			c.Set(principalKey, principal)
+			return next(c)
+		}
+	}
+}
+
+func unauthorized(c echo.Context, message string) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
+
+// PrincipalFrom returns the principal of an authenticated request.
+func PrincipalFrom(c echo.Context) (service.Principal, bool) {
+	principal, ok := c.Get(principalKey).(service.Principal)
+	return principal, ok
+}
+
+// projectScope returns the project the request is limited to, zero if it can access all of them.
+func projectScope(c echo.Context) int {
+	principal, _ := PrincipalFrom(c)
+	return principal.ProjectID
+}
+
+// projectFor returns the project a request acts on, requested is the project in the request or zero.
+// A request limited to a project acts on it, other requests act on the requested project or on fallback.
+func projectFor(c echo.Context, requested, fallback int) (int, error) {
+	scope := projectScope(c)
+	if scope == 0 {
+		if requested == 0 {
+			return fallback, nil
+		}
+		return requested, nil
+	}
+	if requested != 0 && requested != scope {
+		return 0, echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("the credentials are limited to project %d", scope))
+	}
+	return scope, nil
+}
+
+// requireUnscoped rejects the requests which are limited to a project.
+func requireUnscoped(c echo.Context) error {
+	if scope := projectScope(c); scope != 0 {
+		return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("the credentials are limited to project %d", scope))
+	}
+	return nil
+}
+
+// Me responds the principal of the request.
+func (h AuthHandler) Me(c echo.Context) error {
+	principal, ok := PrincipalFrom(c)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "authentication is disabled")
+	}
+
+	return c.JSON(http.StatusOK, principal)
+}
+
+func (h AuthHandler) ListAPIKeys(c echo.Context) error {
+	keys, err := h.AuthService.ListAPIKeys(c.Request().Context(), projectScope(c))
+	if err != nil {
+		logrus.Errorf("failed to list API keys: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list API keys")
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+func (h AuthHandler) CreateAPIKey(c echo.Context) error {
+	req := &request.CreateAPIKey{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("create API key: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	// the keys of the unscoped requests aren't limited to a project unless one is requested.
+	projectID, err := projectFor(c, req.ProjectID, 0)
+	if err != nil {
+		return err
+	}
+	if projectID != 0 {
+		if _, err := h.ProjectRepo.FindOne(c.Request().Context(), projectID); err != nil {
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: project %d not found", projectID))
+			}
+			logrus.Errorf("failed to get project: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create API key")
+		}
+	}
+
+	var (
+		key   repository.APIKey
+		token string
+	)
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		var err error
+		key, token, err = h.AuthService.CreateAPIKey(ctx, projectID, req.Name, req.Role, req.ExpiresAt)
+		if err != nil {
+			logrus.Errorf("failed to create API key: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create API key")
+		}
+		return recordAudit(ctx, c, h.AuditService, service.AuditCreate, service.ResourceAPIKey, key.ID, projectID,
+			nil, key)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, CreatedAPIKey{APIKey: key, Key: token})
+}
+
+func (h AuthHandler) DeleteAPIKey(c echo.Context) error {
+	req := &request.DeleteAPIKey{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("delete API key: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	err := inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		key, err := h.AuthService.DeleteAPIKey(ctx, projectScope(c), req.ID)
+		if err != nil {
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				return echo.NewHTTPError(http.StatusNotFound, "API key id not found")
+			}
+			logrus.Errorf("failed to delete API key: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete API key")
+		}
+		projectID := 0
+		if key.ProjectID != nil {
+			projectID = *key.ProjectID
+		}
+		return recordAudit(ctx, c, h.AuditService, service.AuditDelete, service.ResourceAPIKey, key.ID, projectID,
+			key, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBulkHealthchecks limits the healthchecks a bulk operation acts on.
+const maxBulkHealthchecks = 1000
+
+// BulkResult is the outcome of a bulk operation on a healthcheck, Status is the http status the operation
+// on the single healthcheck would have responded.
+type BulkResult struct {
+	ID     int    `json:"id"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkResponse is the outcome of a bulk operation, Matched is the number of selected healthchecks.
+type BulkResponse struct {
+	Matched   int          `json:"matched"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+func (r *BulkResponse) add(id int, err error) {
+	result := BulkResult{ID: id, Status: http.StatusOK}
+	if err != nil {
+		result.Status, result.Error = errorStatus(err)
+	}
+
+	if result.Status < http.StatusBadRequest {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// errorStatus returns the http status and the message the error would be responded with.
+func errorStatus(err error) (int, string) {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code, fmt.Sprint(httpErr.Message)
+	}
+	var validationErr *request.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// BulkStart starts the selected healthchecks, the started ones are reported as conflicts.
+func (h HealthcheckHandler) BulkStart(c echo.Context) error {
+	return h.bulkToggle(c, service.AuditStart, h.HealthcheckService.StartHealthCheck)
+}
+
+// BulkStop stops the selected healthchecks, the stopped ones are reported as conflicts.
+func (h HealthcheckHandler) BulkStop(c echo.Context) error {
+	return h.bulkToggle(c, service.AuditStop, h.HealthcheckService.StoptHealthCheck)
+}
+
+func (h HealthcheckHandler) bulkToggle(c echo.Context, operation string,
+	toggle func(ctx context.Context, id int) error) error {
+	req := &request.BulkHealthchecks{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("bulk %s healthchecks: bind failed: %s", operation, err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	healthchecks, response, err := h.findBulk(c, *req)
+	if err != nil {
+		return err
+	}
+	for _, healthcheck := range healthchecks {
+		response.add(healthcheck.ID, h.toggle(c, operation, healthcheck, toggle))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// BulkDelete deletes the selected healthchecks in a single statement and stops the running ones.
+func (h HealthcheckHandler) BulkDelete(c echo.Context) error {
+	req := &request.BulkHealthchecks{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("bulk delete healthchecks: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	healthchecks, response, err := h.findBulk(c, *req)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(healthchecks))
+	for _, healthcheck := range healthchecks {
+		ids = append(ids, healthcheck.ID)
+	}
+	isDeleted := make(map[int]bool, len(ids))
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		deleted, err := h.repo(c).DeleteAll(ctx, ids)
+		if err != nil {
+			logrus.Errorf("failed to delete healthchecks: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete healthchecks")
+		}
+
+		for _, id := range deleted {
+			isDeleted[id] = true
+		}
+		for _, healthcheck := range healthchecks {
+			if !isDeleted[healthcheck.ID] {
+				continue
+			}
+			if err := h.audit(ctx, c, service.AuditDelete, healthcheck, healthcheck, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, healthcheck := range healthchecks {
+		if !isDeleted[healthcheck.ID] {
+			// it was deleted concurrently.
+			response.add(healthcheck.ID, echo.NewHTTPError(http.StatusNotFound, "healthcheck id not found"))
+			continue
+		}
+		h.HealthcheckService.Forget(healthcheck)
+		response.add(healthcheck.ID, nil)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// BulkUpdate applies a json merge patch to the selected healthchecks in a single transaction, none of them
+// is updated if the patch makes one of them invalid. The running ones are rescheduled.
+func (h HealthcheckHandler) BulkUpdate(c echo.Context) error {
+	req := &request.BulkUpdateHealthchecks{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("bulk update healthchecks: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	for key := range req.Patch {
+		// the dependencies of each healthcheck are validated against the current graph, so changing many
+		// of them at once could create cycles.
+		if strings.EqualFold(key, "dependsOn") {
+			return echo.NewHTTPError(http.StatusBadRequest, "bad request: dependsOn can't be changed in bulk")
+		}
+	}
+
+	healthchecks, response, err := h.findBulk(c, req.BulkHealthchecks)
+	if err != nil {
+		return err
+	}
+
+	updated := make([]*repository.Healthcheck, 0, len(healthchecks))
+	errs := make([]error, len(healthchecks))
+	invalid := false
+	for i, current := range healthchecks {
+		healthcheck, err := h.patchHealthcheck(c, current, req)
+		if err != nil {
+			errs[i] = err
+			invalid = true
+			continue
+		}
+		updated = append(updated, healthcheck)
+	}
+
+	if invalid {
+		for i, current := range healthchecks {
+			err := errs[i]
+			if err == nil {
+				err = echo.NewHTTPError(http.StatusFailedDependency,
+					"not updated, the patch is invalid for other healthchecks")
+			}
+			response.add(current.ID, err)
+		}
+
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.repo(c).UpdateAll(ctx, updated); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return echo.NewHTTPError(http.StatusConflict, "healthchecks were modified concurrently")
+			}
+			if errors.Is(err, repository.ErrDuplicateExternalName) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			logrus.Errorf("failed to update healthchecks: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update healthchecks")
+		}
+
+		for i, current := range healthchecks {
+			if err := h.audit(ctx, c, service.AuditUpdate, current, current, updated[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the updates are committed, rescheduling failures are reported per healthcheck.
+	for _, current := range healthchecks {
+		response.add(current.ID, h.HealthcheckService.Reschedule(c.Request().Context(), current))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// patchHealthcheck builds the healthcheck the bulk update patch makes of the current one.
+func (h HealthcheckHandler) patchHealthcheck(c echo.Context, current repository.Healthcheck,
+	req *request.BulkUpdateHealthchecks) (*repository.Healthcheck, error) {
+	patched, err := mergePatch(createRequest(current, nil), req.Patch)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err))
+	}
+	if err := c.Validate(patched); err != nil {
+		return nil, err
+	}
+
+	return h.replacement(c, current, patched)
+}
+
+// findBulk returns the healthchecks selected by the request in its project and a response with the not
+// found ids.
+func (h HealthcheckHandler) findBulk(c echo.Context, req request.BulkHealthchecks) ([]repository.Healthcheck,
+	*BulkResponse, error) {
+	labels, err := service.ParseLabelSelector(req.Selector)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+	}
+
+	healthchecks, total, err := h.repo(c).Find(c.Request().Context(), repository.HealthcheckFilter{
+		IDs:    req.IDs,
+		Labels: labels,
+		Limit:  maxBulkHealthchecks,
+	})
+	if err != nil {
+		logrus.Errorf("failed to find healthchecks: %s", err)
+
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to find healthchecks")
+	}
+	if total > maxBulkHealthchecks {
+		return nil, nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"bad request: %d healthchecks match, a bulk operation acts on at most %d", total, maxBulkHealthchecks))
+	}
+
+	response := &BulkResponse{Matched: len(healthchecks), Results: []BulkResult{}}
+	found := make(map[int]bool, len(healthchecks))
+	for _, healthcheck := range healthchecks {
+		found[healthcheck.ID] = true
+	}
+	for _, id := range req.IDs {
+		if !found[id] {
+			response.add(id, echo.NewHTTPError(http.StatusNotFound,
+				"healthcheck id not found or doesn't match the selector"))
+			found[id] = true
+		}
+	}
+
+	return healthchecks, response, nil
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultEventsLimit = 50
+
+// EventHandler handles the history of healthchecks.
+type EventHandler struct {
+	HealthcheckRepo      repository.HealthcheckRepo
+	HealthcheckEventRepo repository.HealthcheckEventRepo
+}
+
+func NewEventHandler(healthcheckRepo repository.HealthcheckRepo,
+	healthcheckEventRepo repository.HealthcheckEventRepo) EventHandler {
+	return EventHandler{
+		HealthcheckRepo:      healthcheckRepo,
+		HealthcheckEventRepo: healthcheckEventRepo,
+	}
+}
+
+// EventsPage is a page of events, NextCursor is nil on the last page.
+type EventsPage struct {
+	Events     []repository.HealthcheckEvent `json:"events"`
+	NextCursor *int                          `json:"nextCursor"`
+}
+
+// HealthcheckStatus is the current state of a healthcheck and the time it has been in that state since.
+type HealthcheckStatus struct {
+	HealthcheckID int       `json:"healthcheckId"`
+	State         string    `json:"state"`
+	Status        string    `json:"status"`
+	Since         time.Time `json:"since"`
+	LastCheckedAt time.Time `json:"lastCheckedAt"`
+}
+
+// List lists the events of all healthchecks.
+func (h EventHandler) List(c echo.Context) error {
+	return h.list(c, false)
+}
+
+// ListForHealthcheck lists the events of a single healthcheck.
+func (h EventHandler) ListForHealthcheck(c echo.Context) error {
+	return h.list(c, true)
+}
+
+func (h EventHandler) list(c echo.Context, forHealthcheck bool) error {
+	req := &request.ListEvents{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("list events: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	projectID, err := projectFor(c, req.ProjectID, 0)
+	if err != nil {
+		return err
+	}
+
+	filter := repository.EventFilter{
+		ProjectID: projectID,
+		From:      req.From,
+		To:        req.To,
+		States:    req.State,
+		Cursor:    req.Cursor,
+		Limit:     req.Limit,
+		Ascending: req.Order == "asc",
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultEventsLimit
+	}
+
+	if forHealthcheck {
+		if err := h.findHealthcheck(c, req.ID); err != nil {
+			return err
+		}
+		filter.HealthcheckID = req.ID
+	}
+
+	events, err := h.HealthcheckEventRepo.Find(c.Request().Context(), filter)
+	if err != nil {
+		logrus.Errorf("failed to list events: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list events")
+	}
+
+	page := EventsPage{Events: events}
+	if len(events) == filter.Limit {
+		page.NextCursor = &events[len(events)-1].ID
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+func (h EventHandler) Status(c echo.Context) error {
+	req := &request.GetHealthcheckStatus{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("get healthcheck status: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.findHealthcheck(c, req.ID); err != nil {
+		return err
+	}
+
+	event, err := h.HealthcheckEventRepo.FindLast(c.Request().Context(), req.ID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "healthcheck has not been checked yet")
+	}
+	if err != nil {
+		logrus.Errorf("failed to get last healthcheck event: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck status")
+	}
+
+	since, err := h.HealthcheckEventRepo.FindStateSince(c.Request().Context(), req.ID, event.State)
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck state since: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck status")
+	}
+
+	return c.JSON(http.StatusOK, HealthcheckStatus{
+		HealthcheckID: req.ID,
+		State:         event.State,
+		Status:        event.Status,
+		Since:         since,
+		LastCheckedAt: event.CreatedAt,
+	})
+}
+
+// findHealthcheck checks the healthcheck exists in the project of the request.
+func (h EventHandler) findHealthcheck(c echo.Context, id int) error {
+	if _, err := h.HealthcheckRepo.InProject(projectScope(c)).FindOne(c.Request().Context(), id); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "healthcheck id not found")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck")
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// readinessTimeout bounds the readiness checks, so probes don't hang on an unresponsive database.
+const readinessTimeout = 2 * time.Second
+
+// HealthHandler handles the liveness and readiness probes of the service itself.
+type HealthHandler struct {
+	HealthService service.HealthService
+}
+
+func NewHealthHandler(healthService service.HealthService) HealthHandler {
+	return HealthHandler{
+		HealthService: healthService,
+	}
+}
+
+// Healthz reports the process is alive.
+func (h HealthHandler) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the service is ready, it responds 503 if any of the checks failed.
+func (h HealthHandler) Readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	readiness := h.HealthService.Ready(ctx)
+	if !readiness.Ready {
+		return c.JSON(http.StatusServiceUnavailable, readiness)
+	}
+	return c.JSON(http.StatusOK, readiness)
+}
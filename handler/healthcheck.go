@@ -1,32 +1,67 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/therealak12/api-health-check/repository"
 	"github.com/therealak12/api-health-check/request"
 	"github.com/therealak12/api-health-check/service"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 )
 
+const defaultHealthchecksLimit = 100
+
 // HealthcheckHandler handles operations defined for healthcheck.
 type HealthcheckHandler struct {
-	HealthcheckRepo    repository.HealthcheckRepo
-	HealthcheckService service.HealthcheckService
+	HealthcheckRepo         repository.HealthcheckRepo
+	HealthcheckRevisionRepo repository.HealthcheckRevisionRepo
+	HealthcheckService      service.HealthcheckService
+	DependencyService       service.DependencyService
+	CompositeService        service.CompositeService
+	TenantService           service.TenantService
+	AuditService            service.AuditService
+	Transactor              repository.Transactor
+}
+
+// auditedHealthcheck is the state of a healthcheck recorded in the audit log with its dependencies.
+type auditedHealthcheck struct {
+	repository.Healthcheck
+	DependsOn []int `json:"dependsOn"`
 }
 
 func NewHealthcheckHandler(healthcheckRepo repository.HealthcheckRepo,
-	healthcheckService service.HealthcheckService) HealthcheckHandler {
+	healthcheckRevisionRepo repository.HealthcheckRevisionRepo,
+	healthcheckService service.HealthcheckService,
+	dependencyService service.DependencyService,
+	compositeService service.CompositeService,
+	tenantService service.TenantService,
+	auditService service.AuditService,
+	transactor repository.Transactor) HealthcheckHandler {
 	return HealthcheckHandler{
-		HealthcheckRepo:    healthcheckRepo,
-		HealthcheckService: healthcheckService,
+		HealthcheckRepo:         healthcheckRepo,
+		HealthcheckRevisionRepo: healthcheckRevisionRepo,
+		HealthcheckService:      healthcheckService,
+		DependencyService:       dependencyService,
+		CompositeService:        compositeService,
+		TenantService:           tenantService,
+		AuditService:            auditService,
+		Transactor:              transactor,
 	}
 }
 
+// repo returns the repo limited to the project of the request.
+func (h HealthcheckHandler) repo(c echo.Context) repository.HealthcheckRepo {
+	return h.HealthcheckRepo.InProject(projectScope(c))
+}
+
 func (h HealthcheckHandler) Register(c echo.Context) error {
 	req := &request.CreateHealthcheck{}
 
@@ -36,29 +71,581 @@ func (h HealthcheckHandler) Register(c echo.Context) error {
 	}
 
 	if err := c.Validate(req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+		return err
+	}
+
+	projectID, err := projectFor(c, req.ProjectID, repository.DefaultProjectID)
+	if err != nil {
+		return err
+	}
+	req.ProjectID = projectID
+
+	if err := h.DependencyService.Validate(c.Request().Context(), req.ProjectID, 0, req.DependsOn); err != nil {
+		return dependencyError(err)
+	}
+
+	healthcheck, err := h.newHealthcheck(c, 0, req)
+	if err != nil {
+		return err
+	}
+	if err := h.checkQuota(c, *healthcheck); err != nil {
+		return err
+	}
+	if healthcheck.Kind == repository.KindHeartbeat {
+		healthcheck.PingToken, err = service.NewPingToken()
+		if err != nil {
+			logrus.Errorf("failed to generate ping token: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create healthcheck")
+		}
+	}
+
+	// the healthcheck isn't created without its dependencies and its audit entry, or next to the ones
+	// created concurrently in its organization.
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.checkCount(ctx, healthcheck.ProjectID, 1); err != nil {
+			return err
+		}
+		if err := h.repo(c).Save(ctx, healthcheck); err != nil {
+			if errors.Is(err, repository.ErrDuplicateExternalName) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			logrus.Errorf("failed to create healthcheck: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create healthcheck")
+		}
+
+		if err := h.DependencyService.Save(ctx, healthcheck.ID, req.DependsOn); err != nil {
+			logrus.Errorf("failed to save healthcheck dependencies: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to save healthcheck dependencies")
+		}
+		return h.audit(ctx, c, service.AuditCreate, *healthcheck, nil, auditedHealthcheck{*healthcheck, req.DependsOn})
+	})
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set("ETag", etag(*healthcheck))
+	return c.JSON(http.StatusCreated, healthcheck)
+}
+
+// Get responds the healthcheck, its version is the ETag.
+func (h HealthcheckHandler) Get(c echo.Context) error {
+	req := &request.GetHealthcheck{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("get healthcheck: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	healthcheck, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+	h.setRunState(&healthcheck, h.HealthcheckService.Running())
+
+	c.Response().Header().Set("ETag", etag(healthcheck))
+	return c.JSON(http.StatusOK, healthcheck)
+}
+
+// Update replaces the healthcheck, a running healthcheck is rescheduled with the new definition.
+func (h HealthcheckHandler) Update(c echo.Context) error {
+	req := &request.UpdateHealthcheck{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("update healthcheck: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	current, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+
+	return h.update(c, current, &req.CreateHealthcheck)
+}
+
+// Patch updates the fields of the healthcheck given in the body, a json merge patch of the fields of
+// the create request. Nested objects such as the headers are replaced as a whole.
+func (h HealthcheckHandler) Patch(c echo.Context) error {
+	req := &request.PatchHealthcheck{}
+
+	if err := (&echo.DefaultBinder{}).BindPathParams(c, req); err != nil {
+		logrus.Errorf("patch healthcheck: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+	patch := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	current, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+	graph, err := h.DependencyService.Graph(c.Request().Context(), current.ID)
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck dependencies: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck dependencies")
+	}
+
+	patched, err := mergePatch(createRequest(current, graph.DependsOn), patch)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+	if err := c.Validate(patched); err != nil {
+		return err
+	}
+
+	return h.update(c, current, patched)
+}
+
+func (h HealthcheckHandler) update(c echo.Context, current repository.Healthcheck,
+	req *request.CreateHealthcheck) error {
+	if !ifMatch(c.Request().Header.Get("If-Match"), current) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, "healthcheck was modified")
+	}
+
+	err := h.DependencyService.Validate(c.Request().Context(), current.ProjectID, current.ID, req.DependsOn)
+	if err != nil {
+		return dependencyError(err)
+	}
+	previous, err := h.DependencyService.Graph(c.Request().Context(), current.ID)
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck dependencies: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck dependencies")
 	}
 
-	headersJson, err := json.Marshal(req.Headers)
+	healthcheck, err := h.replacement(c, current, req)
 	if err != nil {
+		return err
+	}
+
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.repo(c).Update(ctx, healthcheck); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return echo.NewHTTPError(http.StatusPreconditionFailed, "healthcheck was modified")
+			}
+			if errors.Is(err, repository.ErrDuplicateExternalName) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			logrus.Errorf("failed to update healthcheck: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update healthcheck")
+		}
+
+		if err := h.DependencyService.Save(ctx, healthcheck.ID, req.DependsOn); err != nil {
+			logrus.Errorf("failed to save healthcheck dependencies: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to save healthcheck dependencies")
+		}
+		return h.audit(ctx, c, service.AuditUpdate, *healthcheck, auditedHealthcheck{current, previous.DependsOn},
+			auditedHealthcheck{*healthcheck, req.DependsOn})
+	})
+	if err != nil {
+		return err
+	}
+
+	// the update is committed, a rescheduling failure is reported as a warning and the previous definition
+	// keeps running.
+	if err := h.HealthcheckService.Reschedule(c.Request().Context(), current); err != nil {
+		_, message := errorStatus(err)
+		c.Response().Header().Set("Warning", fmt.Sprintf("199 - %q", "not rescheduled: "+message))
+	}
+	h.setRunState(healthcheck, h.HealthcheckService.Running())
+
+	c.Response().Header().Set("ETag", etag(*healthcheck))
+	return c.JSON(http.StatusOK, healthcheck)
+}
+
+// replacement builds the healthcheck which replaces the current one, it keeps the project, version and
+// ping token of the current one. The errors are http errors.
+func (h HealthcheckHandler) replacement(c echo.Context, current repository.Healthcheck,
+	req *request.CreateHealthcheck) (*repository.Healthcheck, error) {
+	if req.ProjectID != 0 && req.ProjectID != current.ProjectID {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "bad request: the project can't be changed")
+	}
+	req.ProjectID = current.ProjectID
+
+	healthcheck, err := h.newHealthcheck(c, current.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkQuota(c, *healthcheck); err != nil {
+		return nil, err
+	}
+	healthcheck.Version = current.Version
+	healthcheck.PingToken = current.PingToken
+	if healthcheck.Kind == repository.KindHeartbeat && healthcheck.PingToken == "" {
+		healthcheck.PingToken, err = service.NewPingToken()
+		if err != nil {
+			logrus.Errorf("failed to generate ping token: %s", err)
+
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to update healthcheck")
+		}
+	}
+
+	return healthcheck, nil
+}
+
+// findHealthcheck finds the healthcheck in the project of the request, the errors are http errors.
+func (h HealthcheckHandler) findHealthcheck(c echo.Context, id int) (repository.Healthcheck, error) {
+	healthcheck, err := h.repo(c).FindOne(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return healthcheck, echo.NewHTTPError(http.StatusNotFound, "healthcheck id not found")
+		}
+		logrus.Errorf("failed to get healthcheck: %s", err)
+
+		return healthcheck, echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck")
+	}
+
+	return healthcheck, nil
+}
+
+// audit records a change of the healthcheck in the audit log in the transaction of ctx, before or after is
+// nil if the healthcheck didn't exist. The errors are http errors.
+func (h HealthcheckHandler) audit(ctx context.Context, c echo.Context, action string,
+	healthcheck repository.Healthcheck, before, after interface{}) error {
+	return recordAudit(ctx, c, h.AuditService, action, service.ResourceHealthcheck, healthcheck.ID,
+		healthcheck.ProjectID, before, after)
+}
+
+// auditToggle records starting or stopping the healthcheck in the audit log as a change of its run state.
+func (h HealthcheckHandler) auditToggle(ctx context.Context, c echo.Context, action string,
+	healthcheck repository.Healthcheck) error {
+	before, after := healthcheck, healthcheck
+	before.Running = action != service.AuditStart
+	after.Running = action == service.AuditStart
+	return h.audit(ctx, c, action, healthcheck, before, after)
+}
+
+// toggle starts or stops the healthcheck, the toggle isn't made if it can't be recorded in the audit log.
+func (h HealthcheckHandler) toggle(c echo.Context, action string, healthcheck repository.Healthcheck,
+	toggle func(ctx context.Context, id int) error) error {
+	return inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.auditToggle(ctx, c, action, healthcheck); err != nil {
+			return err
+		}
+		return toggle(ctx, healthcheck.ID)
+	})
+}
+
+// checkQuota checks the healthcheck fits in the quotas of the organization of its project, the errors are
+// http errors.
+func (h HealthcheckHandler) checkQuota(c echo.Context, healthcheck repository.Healthcheck) error {
+	return quotaError(h.TenantService.CheckQuota(c.Request().Context(), healthcheck))
+}
+
+// checkCount checks created more healthchecks fit in the organization of the project, they must be created
+// in the transaction of ctx. The errors are http errors.
+func (h HealthcheckHandler) checkCount(ctx context.Context, projectID int, created int) error {
+	return quotaError(h.TenantService.CheckCount(ctx, projectID, created))
+}
+
+// quotaError returns the http error of a quota check.
+func quotaError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	if errors.Is(err, service.ErrProjectNotFound) {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
 	}
+	logrus.Errorf("failed to check quotas: %s", err)
 
+	return echo.NewHTTPError(http.StatusInternalServerError, "failed to check quotas")
+}
+
+// etag is the entity tag of a version of the healthcheck.
+func etag(healthcheck repository.Healthcheck) string {
+	return strconv.Quote(strconv.Itoa(healthcheck.Version))
+}
+
+// ifMatch reports whether the If-Match header matches the version of the healthcheck, an empty header
+// matches any version.
+func ifMatch(header string, healthcheck repository.Healthcheck) bool {
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag(healthcheck) {
+			return true
+		}
+	}
+	return false
+}
+
+// createRequest converts the healthcheck to the request which creates it.
+func createRequest(healthcheck repository.Healthcheck, dependsOn []int) *request.CreateHealthcheck {
+	req := &request.CreateHealthcheck{
+		ProjectID:          healthcheck.ProjectID,
+		ExternalName:       healthcheck.ExternalName,
+		Name:               healthcheck.Name,
+		Description:        healthcheck.Description,
+		Owner:              healthcheck.Owner,
+		Labels:             healthcheck.Labels,
+		Kind:               healthcheck.Kind,
+		IntervalSeconds:    healthcheck.IntervalSeconds,
+		Url:                healthcheck.Url,
+		HttpMethod:         healthcheck.HttpMethod,
+		Headers:            healthcheck.Headers,
+		Body:               healthcheck.Body,
+		ValidStatusCodes:   healthcheck.ValidStatusCodes,
+		DependsOn:          dependsOn,
+		CompositeRule:      healthcheck.CompositeRule,
+		CompositeMinUp:     healthcheck.CompositeMinUp,
+		CompositeThreshold: healthcheck.CompositeThreshold,
+		GraceSeconds:       healthcheck.GraceSeconds,
+		CronExpression:     healthcheck.CronExpression,
+		Timezone:           healthcheck.Timezone,
+	}
+	for _, member := range healthcheck.Members {
+		req.Members = append(req.Members, request.CompositeMember{ID: member.MemberID, Weight: member.Weight})
+	}
+	for _, activeHours := range healthcheck.ActiveHours {
+		req.ActiveHours = append(req.ActiveHours, request.ActiveHours{
+			Days: activeHours.Days,
+			From: activeHours.From,
+			To:   activeHours.To,
+		})
+	}
+
+	return req
+}
+
+// mergePatch applies a json merge patch to the top level fields of the request, the field names are
+// matched case insensitively like encoding/json does.
+func mergePatch(req *request.CreateHealthcheck, patch map[string]json.RawMessage) (*request.CreateHealthcheck, error) {
+	current, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(current, &merged); err != nil {
+		return nil, err
+	}
+
+	for key, value := range patch {
+		for name := range merged {
+			if strings.EqualFold(name, key) {
+				delete(merged, name)
+			}
+		}
+		merged[key] = value
+	}
+
+	patched, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	result := &request.CreateHealthcheck{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// newHealthcheck builds and validates a healthcheck from the request, id is zero for new healthchecks.
+// The errors are http errors.
+func (h HealthcheckHandler) newHealthcheck(c echo.Context, id int,
+	req *request.CreateHealthcheck) (*repository.Healthcheck, error) {
 	healthcheck := &repository.Healthcheck{
+		ID:              id,
+		ProjectID:       req.ProjectID,
+		ExternalName:    req.ExternalName,
+		Name:            req.Name,
+		Description:     req.Description,
+		Owner:           req.Owner,
+		Labels:          req.Labels,
+		Kind:            req.Kind,
 		IntervalSeconds: req.IntervalSeconds,
 		Url:             req.Url,
 		HttpMethod:      req.HttpMethod,
-		HeadersJson:     string(headersJson),
+		Headers:         req.Headers,
 		Body:            req.Body,
+		CronExpression:  req.CronExpression,
+		Timezone:        req.Timezone,
+	}
+	for _, activeHours := range req.ActiveHours {
+		healthcheck.ActiveHours = append(healthcheck.ActiveHours, repository.ActiveHours{
+			Days: activeHours.Days,
+			From: activeHours.From,
+			To:   activeHours.To,
+		})
 	}
+	if healthcheck.Kind == "" {
+		healthcheck.Kind = repository.KindHTTP
+	}
+
+	switch healthcheck.Kind {
+	case repository.KindHTTP:
+		healthcheck.ValidStatusCodes = req.ValidStatusCodes
+	case repository.KindComposite:
+		healthcheck.CompositeRule = req.CompositeRule
+		healthcheck.CompositeMinUp = req.CompositeMinUp
+		healthcheck.CompositeThreshold = req.CompositeThreshold
+		for _, member := range req.Members {
+			weight := member.Weight
+			if weight == 0 {
+				weight = 1
+			}
+			healthcheck.Members = append(healthcheck.Members, repository.CompositeMember{
+				MemberID: member.ID,
+				Weight:   weight,
+			})
+		}
 
-	if err := h.HealthcheckRepo.Save(healthcheck); err != nil {
-		logrus.Errorf("failed to create healthcheck: %s", err)
+		if err := h.CompositeService.Validate(c.Request().Context(), *healthcheck); err != nil {
+			if errors.Is(err, service.ErrInvalidComposite) {
+				return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+			}
+			logrus.Errorf("failed to validate composite healthcheck: %s", err)
 
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create healthcheck")
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to validate composite healthcheck")
+		}
+	case repository.KindHeartbeat:
+		healthcheck.GraceSeconds = req.GraceSeconds
+	default:
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("bad request: unknown kind %q", healthcheck.Kind))
 	}
 
-	return c.JSON(http.StatusCreated, healthcheck)
+	if healthcheck.Kind != repository.KindHeartbeat {
+		if _, err := service.NewSchedule(*healthcheck); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+		}
+	}
+
+	return healthcheck, nil
+}
+
+// Run runs a saved healthcheck once and responds its full result.
+func (h HealthcheckHandler) Run(c echo.Context) error {
+	req := &request.RunHealthcheck{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("run healthcheck: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+	// the query params of POST requests aren't bound by Bind.
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, req); err != nil {
+		logrus.Errorf("run healthcheck: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	healthcheck, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+
+	return h.run(c, healthcheck, req.Record)
+}
+
+// Test runs an unsaved healthcheck once and responds its full result, nothing is recorded.
+func (h HealthcheckHandler) Test(c echo.Context) error {
+	req := &request.CreateHealthcheck{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("test healthcheck: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if req.Kind == repository.KindHeartbeat {
+		return echo.NewHTTPError(http.StatusBadRequest, "bad request: unsaved heartbeats have no pings to test")
+	}
+	// the members of composites are looked for in the project.
+	projectID, err := projectFor(c, req.ProjectID, repository.DefaultProjectID)
+	if err != nil {
+		return err
+	}
+	req.ProjectID = projectID
+	healthcheck, err := h.newHealthcheck(c, 0, req)
+	if err != nil {
+		return err
+	}
+
+	return h.run(c, *healthcheck, false)
+}
+
+func (h HealthcheckHandler) run(c echo.Context, healthcheck repository.Healthcheck, record bool) error {
+	result, err := h.HealthcheckService.Run(c.Request().Context(), healthcheck, record)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidHealthcheck) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+		}
+		logrus.Errorf("failed to run healthcheck: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to run healthcheck")
+	}
+
+	return c.JSON(http.StatusOK, result)
+}
+
+func (h HealthcheckHandler) Dependencies(c echo.Context) error {
+	req := &request.GetHealthcheckDependencies{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("get healthcheck dependencies: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if _, err := h.findHealthcheck(c, req.ID); err != nil {
+		return err
+	}
+
+	graph, err := h.DependencyService.Graph(c.Request().Context(), req.ID)
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck dependencies: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck dependencies")
+	}
+
+	return c.JSON(http.StatusOK, graph)
+}
+
+func dependencyError(err error) error {
+	if errors.Is(err, service.ErrDependencyCycle) || errors.Is(err, service.ErrDependencyNotFound) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+	}
+	logrus.Errorf("failed to validate healthcheck dependencies: %s", err)
+
+	return echo.NewHTTPError(http.StatusInternalServerError, "failed to validate healthcheck dependencies")
 }
 
 func (h HealthcheckHandler) Start(c echo.Context) error {
@@ -69,7 +656,11 @@ func (h HealthcheckHandler) Start(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
 	}
 
-	if err := h.HealthcheckService.StartHealthCheck(req.ID); err != nil {
+	healthcheck, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+	if err := h.toggle(c, service.AuditStart, healthcheck, h.HealthcheckService.StartHealthCheck); err != nil {
 		return err
 	}
 
@@ -84,22 +675,88 @@ func (h HealthcheckHandler) Stop(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
 	}
 
-	if err := h.HealthcheckService.StoptHealthCheck(req.ID); err != nil {
+	healthcheck, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+	if err := h.toggle(c, service.AuditStop, healthcheck, h.HealthcheckService.StoptHealthCheck); err != nil {
 		return err
 	}
 
 	return c.JSON(http.StatusOK, "")
 }
 
+// List lists the healthchecks matching the filters of the request, the X-Total-Count header is the number
+// of matching healthchecks on all pages.
 func (h HealthcheckHandler) List(c echo.Context) error {
-	healthchecks, err := h.HealthcheckRepo.FindAll()
+	req := &request.ListHealthchecks{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("list healthchecks: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	labels, err := service.ParseLabelSelector(req.Selector)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+	}
+	projectID, err := projectFor(c, req.ProjectID, 0)
 	if err != nil {
+		return err
+	}
+
+	running := h.HealthcheckService.Running()
+	filter := repository.HealthcheckFilter{
+		Labels:     labels,
+		Url:        req.Url,
+		Owner:      req.Owner,
+		States:     req.State,
+		Sort:       req.Sort,
+		Descending: req.Order == "desc",
+		Offset:     req.Offset,
+		Limit:      req.Limit,
+	}
+	if req.Running != "" {
+		isRunning := req.Running == "true"
+		filter.Running = &isRunning
+		filter.RunningIDs = running
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultHealthchecksLimit
+	}
+
+	healthchecks, total, err := h.HealthcheckRepo.InProject(projectID).Find(c.Request().Context(), filter)
+	if err != nil {
+		logrus.Errorf("failed to list healthchecks: %s", err)
+
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list healthchecks")
 	}
+	for i := range healthchecks {
+		h.setRunState(&healthchecks[i], running)
+	}
 
+	c.Response().Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
 	return c.JSON(http.StatusOK, healthchecks)
 }
 
+// setRunState sets the fields of the healthcheck which are computed by the scheduler, running are the ids
+// of the started healthchecks.
+func (h HealthcheckHandler) setRunState(healthcheck *repository.Healthcheck, running []int) {
+	for _, id := range running {
+		if id == healthcheck.ID {
+			healthcheck.Running = true
+			break
+		}
+	}
+	if next, ok := h.HealthcheckService.NextRun(healthcheck.ID); ok {
+		healthcheck.NextRunAt = &next
+	}
+}
+
 func (h HealthcheckHandler) Delete(c echo.Context) error {
 	req := &request.DeleteHealthcheck{}
 
@@ -110,16 +767,33 @@ func (h HealthcheckHandler) Delete(c echo.Context) error {
 	}
 
 	if err := c.Validate(req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+		return err
 	}
 
-	if err := h.HealthcheckRepo.Delete(req.ID); err != nil {
-		if errors.Is(err, repository.ErrRecordNotFound) {
-			return echo.NewHTTPError(http.StatusNotFound, "healthcheck id not found")
-		}
+	current, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+	if !ifMatch(c.Request().Header.Get("If-Match"), current) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, "healthcheck was modified")
+	}
 
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete healthcheck")
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		// the healthcheck may be modified since it was compared with If-Match.
+		if err := h.repo(c).Delete(ctx, req.ID, current.Version); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return echo.NewHTTPError(http.StatusPreconditionFailed, "healthcheck was modified")
+			}
+			logrus.Errorf("failed to delete healthcheck: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete healthcheck")
+		}
+		return h.audit(ctx, c, service.AuditDelete, current, current, nil)
+	})
+	if err != nil {
+		return err
 	}
+	h.HealthcheckService.Forget(current)
 
 	return c.NoContent(http.StatusOK)
 }
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultImportIntervalSeconds is the interval of the imported healthchecks if the import sets none.
+const defaultImportIntervalSeconds = 60
+
+// ImportResult is the outcome of importing a request of a collection, Item is its path in the folders of
+// the collection. Healthcheck is the created healthcheck, or the one a dry run would create, and Warnings
+// are the parts of the request which aren't imported.
+type ImportResult struct {
+	Item        string                  `json:"item"`
+	Status      int                     `json:"status"`
+	Healthcheck *repository.Healthcheck `json:"healthcheck,omitempty"`
+	Warnings    []string                `json:"warnings,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+// ImportResponse is the outcome of an import, Imported is the number of the requests which are imported or
+// would be by a dry run.
+type ImportResponse struct {
+	DryRun   bool           `json:"dryRun"`
+	Imported int            `json:"imported"`
+	Failed   int            `json:"failed"`
+	Results  []ImportResult `json:"results"`
+}
+
+// ImportPostman creates an http healthcheck for each request of the Postman collection, the requests which
+// can't be imported are reported and the others are still created. A dry run responds the healthchecks
+// which would be created.
+func (h HealthcheckHandler) ImportPostman(c echo.Context) error {
+	req := &request.ImportPostman{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("import postman collection: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+	// the query params of POST requests aren't bound by Bind.
+	if err := (&echo.DefaultBinder{}).BindQueryParams(c, req); err != nil {
+		logrus.Errorf("import postman collection: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	if !req.SupportedPostmanSchema() {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"bad request: only postman collections of the v2.0 and v2.1 formats are supported")
+	}
+
+	projectID, err := projectFor(c, req.ProjectID, repository.DefaultProjectID)
+	if err != nil {
+		return err
+	}
+	if req.IntervalSeconds == 0 {
+		req.IntervalSeconds = defaultImportIntervalSeconds
+	}
+
+	converted := service.PostmanHealthchecks(*req)
+	if len(converted) > maxBulkHealthchecks {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("bad request: more than %d requests in the collection", maxBulkHealthchecks))
+	}
+
+	response := ImportResponse{DryRun: req.DryRun, Results: []ImportResult{}}
+	for _, item := range converted {
+		result := ImportResult{Item: item.Item, Warnings: item.Warnings}
+		err := item.Err
+		if err == nil {
+			item.Healthcheck.ProjectID = projectID
+			result.Healthcheck, err = h.importHealthcheck(c, &item.Healthcheck, req.DryRun)
+		}
+
+		switch {
+		case err != nil:
+			if item.Err != nil {
+				result.Status, result.Error = http.StatusBadRequest, fmt.Sprintf("bad request: %s", err)
+			} else {
+				result.Status, result.Error = errorStatus(err)
+			}
+			response.Failed++
+		case req.DryRun:
+			result.Status = http.StatusOK
+			response.Imported++
+		default:
+			result.Status = http.StatusCreated
+			response.Imported++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// importHealthcheck creates the healthcheck of an imported request like a registration, the healthcheck
+// is only built by a dry run. The errors are http errors.
+func (h HealthcheckHandler) importHealthcheck(c echo.Context, req *request.CreateHealthcheck,
+	dryRun bool) (*repository.Healthcheck, error) {
+	if err := c.Validate(req); err != nil {
+		return nil, err
+	}
+
+	healthcheck, err := h.newHealthcheck(c, 0, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkQuota(c, *healthcheck); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		if err := h.checkCount(c.Request().Context(), healthcheck.ProjectID, 1); err != nil {
+			return nil, err
+		}
+		return healthcheck, nil
+	}
+
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.checkCount(ctx, healthcheck.ProjectID, 1); err != nil {
+			return err
+		}
+		if err := h.repo(c).Save(ctx, healthcheck); err != nil {
+			if errors.Is(err, repository.ErrDuplicateExternalName) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			logrus.Errorf("failed to create healthcheck: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create healthcheck")
+		}
+		return h.audit(ctx, c, service.AuditCreate, *healthcheck, nil, auditedHealthcheck{*healthcheck, nil})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return healthcheck, nil
+}
@@ -0,0 +1,585 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Manifest change actions.
+const (
+	ManifestCreate = "create"
+	ManifestUpdate = "update"
+	ManifestDelete = "delete"
+)
+
+// ManifestChange is a change applying a manifest makes to a healthcheck, ID is zero for the created ones
+// until the manifest is applied. Changes are the fields an update changes and Error is set if the declared
+// healthcheck is invalid.
+type ManifestChange struct {
+	Action       string                         `json:"action"`
+	ExternalName string                         `json:"externalName"`
+	ID           int                            `json:"id,omitempty"`
+	Changes      map[string]service.AuditChange `json:"changes,omitempty"`
+	Error        string                         `json:"error,omitempty"`
+}
+
+// ManifestPlan is the difference between a manifest and the healthchecks of its project, Unchanged is
+// the number of declared healthchecks which are up to date. Error is set if the declared dependencies or
+// members make a cycle or the created healthchecks exceed the quota of the organization. Until the plan is
+// applied, the changes reference the created healthchecks by negative planned ids, -1 for the first
+// declared healthcheck.
+type ManifestPlan struct {
+	ProjectID int              `json:"projectId"`
+	Changes   []ManifestChange `json:"changes"`
+	Unchanged int              `json:"unchanged"`
+	Applied   bool             `json:"applied"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// manifestStep is a planned change and the healthchecks it's applied with, desired is nil for deletions.
+// previous are the current dependencies and plannedID is the id of a created healthcheck until it's saved.
+type manifestStep struct {
+	change    *ManifestChange
+	current   repository.Healthcheck
+	previous  []int
+	desired   *repository.Healthcheck
+	dependsOn []int
+	plannedID int
+}
+
+// plannedID returns the id the healthcheck declared at index i of a manifest has until it's created.
+func plannedID(i int) int {
+	return -(i + 1)
+}
+
+// manifestState is the state of a healthcheck a manifest is compared with.
+type manifestState struct {
+	repository.HealthcheckDefinition
+	DependsOn []int `json:"dependsOn"`
+}
+
+// newManifestState returns the state of the healthcheck with its dependencies. The empty collections are
+// nil and the unordered ones are sorted, they are stored differently than they may be declared.
+func newManifestState(healthcheck repository.Healthcheck, dependsOn []int) manifestState {
+	state := manifestState{HealthcheckDefinition: repository.NewHealthcheckDefinition(healthcheck)}
+	if len(state.Labels) == 0 {
+		state.Labels = nil
+	}
+	if len(state.Headers) == 0 {
+		state.Headers = nil
+	}
+	if len(state.Members) > 0 {
+		state.Members = append([]repository.CompositeMember{}, state.Members...)
+		sort.Slice(state.Members, func(i, j int) bool {
+			return state.Members[i].MemberID < state.Members[j].MemberID
+		})
+	}
+	if len(dependsOn) > 0 {
+		state.DependsOn = append([]int{}, dependsOn...)
+		sort.Ints(state.DependsOn)
+	}
+	return state
+}
+
+// PlanManifest responds the changes applying the manifest in the body would make, the body is YAML
+// unless the content type is JSON.
+func (h HealthcheckHandler) PlanManifest(c echo.Context) error {
+	return h.manifest(c, false)
+}
+
+// ApplyManifest makes the healthchecks of the project match the manifest in the body and responds the
+// applied plan, nothing is changed if a declared healthcheck is invalid. Applying the same manifest again
+// changes nothing.
+func (h HealthcheckHandler) ApplyManifest(c echo.Context) error {
+	return h.manifest(c, true)
+}
+
+func (h HealthcheckHandler) manifest(c echo.Context, apply bool) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+	format := request.ManifestYAML
+	if strings.Contains(c.Request().Header.Get(echo.HeaderContentType), "json") {
+		format = request.ManifestJSON
+	}
+	manifest, err := request.ParseManifest(body, format)
+	if err != nil {
+		logrus.Errorf("manifest: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(&manifest); err != nil {
+		return err
+	}
+	projectID, err := projectFor(c, manifest.ProjectID, repository.DefaultProjectID)
+	if err != nil {
+		return err
+	}
+
+	plan, steps, err := h.planManifest(c, projectID, manifest)
+	if err != nil {
+		return err
+	}
+	if !apply {
+		return c.JSON(http.StatusOK, plan)
+	}
+	if plan.Error != "" {
+		return c.JSON(http.StatusBadRequest, plan)
+	}
+	for _, change := range plan.Changes {
+		if change.Error != "" {
+			return c.JSON(http.StatusBadRequest, plan)
+		}
+	}
+
+	if err := h.applyManifest(c, projectID, steps); err != nil {
+		return err
+	}
+	plan.Applied = true
+
+	return c.JSON(http.StatusOK, plan)
+}
+
+// planManifest compares the manifest with the healthchecks of the project which have an external name.
+func (h HealthcheckHandler) planManifest(c echo.Context, projectID int, manifest request.Manifest) (*ManifestPlan,
+	[]manifestStep, error) {
+	existing, err := h.HealthcheckRepo.InProject(projectID).FindAll(c.Request().Context())
+	if err != nil {
+		logrus.Errorf("failed to list healthchecks: %s", err)
+
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to list healthchecks")
+	}
+	managed := make(map[string]repository.Healthcheck)
+	for _, healthcheck := range existing {
+		if healthcheck.ExternalName != "" {
+			managed[healthcheck.ExternalName] = healthcheck
+		}
+	}
+
+	// the declared healthchecks are referenced by external name, the created ones by their planned ids.
+	declared := make(map[string]int, len(manifest.Healthchecks))
+	for i, item := range manifest.Healthchecks {
+		declared[item.ExternalName] = plannedID(i)
+		if current, found := managed[item.ExternalName]; found {
+			declared[item.ExternalName] = current.ID
+		}
+	}
+
+	plan := &ManifestPlan{ProjectID: projectID}
+	var steps []manifestStep
+	for i, item := range manifest.Healthchecks {
+		req := request.CreateHealthcheck(item.ManifestDefinition)
+		req.ProjectID = projectID
+		current, found := managed[req.ExternalName]
+		delete(managed, req.ExternalName)
+
+		step := manifestStep{
+			change:  &ManifestChange{Action: ManifestCreate, ExternalName: req.ExternalName},
+			current: current,
+		}
+		if found {
+			step.change.Action = ManifestUpdate
+			step.change.ID = current.ID
+			graph, err := h.DependencyService.Graph(c.Request().Context(), current.ID)
+			if err != nil {
+				logrus.Errorf("failed to get healthcheck dependencies: %s", err)
+
+				return nil, nil, echo.NewHTTPError(http.StatusInternalServerError,
+					"failed to get healthcheck dependencies")
+			}
+			step.previous = graph.DependsOn
+		} else {
+			step.plannedID = plannedID(i)
+		}
+
+		req.DependsOn, req.Members, err = manifestReferences(item, declared)
+		if err == nil {
+			step.dependsOn = req.DependsOn
+			step.desired, err = h.declaredHealthcheck(c, current, &req)
+		}
+		if err != nil {
+			_, step.change.Error = errorStatus(err)
+		} else if found {
+			changes, err := service.Diff(newManifestState(current, step.previous),
+				newManifestState(*step.desired, req.DependsOn))
+			if err != nil {
+				logrus.Errorf("failed to compare healthchecks: %s", err)
+
+				return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to plan manifest")
+			}
+			if len(changes) == 0 {
+				plan.Unchanged++
+				continue
+			}
+			step.change.Changes = changes
+		}
+		steps = append(steps, step)
+	}
+
+	// the undeclared healthchecks are deleted in the order of their names so the plan is stable.
+	names := make([]string, 0, len(managed))
+	for name := range managed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		current := managed[name]
+		steps = append(steps, manifestStep{
+			change:  &ManifestChange{Action: ManifestDelete, ExternalName: name, ID: current.ID},
+			current: current,
+		})
+	}
+
+	if err := h.validateManifestGraph(c, plan, steps); err != nil {
+		return nil, nil, err
+	}
+	if plan.Error == "" {
+		err := h.checkCount(c.Request().Context(), projectID, manifestCreated(steps))
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) && httpErr.Code == http.StatusForbidden {
+			_, plan.Error = errorStatus(err)
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the changes of the steps are the ones of the plan, applying them fills in the ids.
+	plan.Changes = make([]ManifestChange, len(steps))
+	for i := range steps {
+		plan.Changes[i] = *steps[i].change
+		steps[i].change = &plan.Changes[i]
+	}
+	return plan, steps, nil
+}
+
+// validateManifestGraph checks the dependency and membership graphs the planned changes make at once, the
+// healthchecks may reference each other. The dependencies on deleted healthchecks are reported on their
+// changes and cycles on the plan.
+func (h HealthcheckHandler) validateManifestGraph(c echo.Context, plan *ManifestPlan, steps []manifestStep) error {
+	var deleted []int
+	isDeleted := make(map[int]bool)
+	for _, step := range steps {
+		if step.change.Action == ManifestDelete {
+			deleted = append(deleted, step.current.ID)
+			isDeleted[step.current.ID] = true
+		}
+	}
+
+	dependsOn := make(map[int][]int)
+	members := make(map[int][]int)
+	for _, step := range steps {
+		if step.change.Action == ManifestDelete || step.change.Error != "" {
+			continue
+		}
+		for _, parentID := range step.dependsOn {
+			if isDeleted[parentID] {
+				_, step.change.Error = errorStatus(dependencyError(
+					fmt.Errorf("%w: healthcheck %d is deleted", service.ErrDependencyNotFound, parentID)))
+				break
+			}
+		}
+		// the created healthchecks may be referenced by the other declared ones, so they can be part of a cycle.
+		id := step.current.ID
+		if step.change.Action == ManifestCreate {
+			id = step.plannedID
+		}
+		dependsOn[id] = step.dependsOn
+		members[id] = []int{}
+		for _, member := range step.desired.Members {
+			members[id] = append(members[id], member.MemberID)
+		}
+	}
+
+	err := h.DependencyService.ValidateGraph(c.Request().Context(), dependsOn, deleted)
+	if errors.Is(err, service.ErrDependencyCycle) {
+		_, plan.Error = errorStatus(dependencyError(err))
+		return nil
+	}
+	if err != nil {
+		return dependencyError(err)
+	}
+
+	err = h.CompositeService.ValidateGraph(c.Request().Context(), members, deleted)
+	if errors.Is(err, service.ErrInvalidComposite) {
+		plan.Error = fmt.Sprintf("bad request: %s", err.Error())
+		return nil
+	}
+	if err != nil {
+		logrus.Errorf("failed to validate composite healthchecks: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to validate composite healthchecks")
+	}
+	return nil
+}
+
+// manifestReferences returns the dependencies and the members of the declared healthcheck, the references
+// by external name are resolved with the ids of the declared healthchecks. The errors are http errors.
+func manifestReferences(item request.ManifestHealthcheck, declared map[string]int) ([]int,
+	[]request.CompositeMember, error) {
+	resolve := func(reference request.ManifestReference) (int, error) {
+		if id, ok := reference.ID(); ok {
+			return id, nil
+		}
+		if id, ok := declared[string(reference)]; ok {
+			return id, nil
+		}
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("bad request: healthcheck %q isn't declared by the manifest", reference))
+	}
+
+	var dependsOn []int
+	for _, reference := range item.DependsOn {
+		id, err := resolve(reference)
+		if err != nil {
+			return nil, nil, err
+		}
+		dependsOn = append(dependsOn, id)
+	}
+	var members []request.CompositeMember
+	for _, member := range item.Members {
+		id, err := resolve(member.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		members = append(members, request.CompositeMember{ID: id, Weight: member.Weight})
+	}
+	return dependsOn, members, nil
+}
+
+// manifestCreated returns the number of healthchecks the planned changes add to the project, the deleted
+// ones make room for the created ones.
+func manifestCreated(steps []manifestStep) int {
+	created := 0
+	for _, step := range steps {
+		switch step.change.Action {
+		case ManifestCreate:
+			created++
+		case ManifestDelete:
+			created--
+		}
+	}
+	return created
+}
+
+// declaredHealthcheck builds the healthcheck declared by the request like a create or an update of the
+// current one if it exists, the number of created healthchecks is checked on the whole plan. The errors are
+// http errors.
+func (h HealthcheckHandler) declaredHealthcheck(c echo.Context, current repository.Healthcheck,
+	req *request.CreateHealthcheck) (*repository.Healthcheck, error) {
+	// the cycles are checked on the whole graph the manifest makes.
+	if err := h.DependencyService.Validate(c.Request().Context(), req.ProjectID, 0, req.DependsOn); err != nil {
+		return nil, dependencyError(err)
+	}
+	if current.ID != 0 {
+		return h.replacement(c, current, req)
+	}
+
+	healthcheck, err := h.newHealthcheck(c, 0, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.checkQuota(c, *healthcheck); err != nil {
+		return nil, err
+	}
+	return healthcheck, nil
+}
+
+// applyManifest applies the planned changes in a single transaction, the creations before the updates and
+// the deletions. The members of a composite are created before it and the planned ids are replaced by the
+// ids of the created healthchecks, which are set on their changes.
+func (h HealthcheckHandler) applyManifest(c echo.Context, projectID int, steps []manifestStep) error {
+	var updated []*repository.Healthcheck
+	var deleted []int
+	for _, step := range steps {
+		switch step.change.Action {
+		case ManifestUpdate:
+			updated = append(updated, step.desired)
+		case ManifestDelete:
+			deleted = append(deleted, step.current.ID)
+		}
+	}
+
+	err := inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		// the healthchecks may be created concurrently since the plan.
+		if err := h.checkCount(ctx, projectID, manifestCreated(steps)); err != nil {
+			return err
+		}
+
+		ids := make(map[int]int)
+		for _, step := range manifestCreations(steps) {
+			resolvePlannedMembers(step.desired, ids)
+			if err := h.createDeclared(ctx, c, step); err != nil {
+				return err
+			}
+			ids[step.plannedID] = step.desired.ID
+		}
+		for _, healthcheck := range updated {
+			resolvePlannedMembers(healthcheck, ids)
+		}
+
+		if err := h.repo(c).UpdateAll(ctx, updated); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return echo.NewHTTPError(http.StatusConflict, "healthchecks were modified concurrently")
+			}
+			if errors.Is(err, repository.ErrDuplicateExternalName) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			logrus.Errorf("failed to update healthchecks: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update healthchecks")
+		}
+
+		for i := range steps {
+			step := &steps[i]
+			if step.change.Action == ManifestDelete {
+				continue
+			}
+			dependsOn := make([]int, len(step.dependsOn))
+			for j, parentID := range step.dependsOn {
+				dependsOn[j] = plannedOrSaved(parentID, ids)
+			}
+			step.dependsOn = dependsOn
+
+			id := step.current.ID
+			if step.change.Action == ManifestCreate {
+				id = step.desired.ID
+			}
+			if err := h.DependencyService.Save(ctx, id, step.dependsOn); err != nil {
+				logrus.Errorf("failed to save healthcheck dependencies: %s", err)
+
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to save healthcheck dependencies")
+			}
+
+			var err error
+			if step.change.Action == ManifestCreate {
+				err = h.audit(ctx, c, service.AuditCreate, *step.desired, nil,
+					auditedHealthcheck{*step.desired, step.dependsOn})
+			} else {
+				err = h.audit(ctx, c, service.AuditUpdate, step.current,
+					auditedHealthcheck{step.current, step.previous}, auditedHealthcheck{*step.desired, step.dependsOn})
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(deleted) == 0 {
+			return nil
+		}
+		if _, err := h.repo(c).DeleteAll(ctx, deleted); err != nil {
+			logrus.Errorf("failed to delete healthchecks: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete healthchecks")
+		}
+		for _, step := range steps {
+			if step.change.Action != ManifestDelete {
+				continue
+			}
+			if err := h.audit(ctx, c, service.AuditDelete, step.current, step.current, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the changes are committed, a rescheduling failure is reported on the change.
+	for _, step := range steps {
+		switch step.change.Action {
+		case ManifestUpdate:
+			if err := h.HealthcheckService.Reschedule(c.Request().Context(), step.current); err != nil {
+				_, step.change.Error = errorStatus(err)
+			}
+		case ManifestDelete:
+			h.HealthcheckService.Forget(step.current)
+		}
+	}
+
+	return nil
+}
+
+// createDeclared saves a healthcheck created by a manifest in the transaction of ctx, its dependencies are
+// saved once all the declared healthchecks are.
+func (h HealthcheckHandler) createDeclared(ctx context.Context, c echo.Context, step manifestStep) error {
+	healthcheck := step.desired
+	if healthcheck.Kind == repository.KindHeartbeat {
+		var err error
+		healthcheck.PingToken, err = service.NewPingToken()
+		if err != nil {
+			logrus.Errorf("failed to generate ping token: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create healthcheck")
+		}
+	}
+
+	if err := h.repo(c).Save(ctx, healthcheck); err != nil {
+		if errors.Is(err, repository.ErrDuplicateExternalName) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		logrus.Errorf("failed to create healthcheck: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create healthcheck")
+	}
+	step.change.ID = healthcheck.ID
+	return nil
+}
+
+// manifestCreations returns the created steps in the order they're saved, the members of a composite
+// before it. The plan has no membership cycle.
+func manifestCreations(steps []manifestStep) []manifestStep {
+	created := make(map[int]manifestStep)
+	for _, step := range steps {
+		if step.change.Action == ManifestCreate {
+			created[step.plannedID] = step
+		}
+	}
+
+	var ordered []manifestStep
+	visited := make(map[int]bool)
+	var visit func(step manifestStep)
+	visit = func(step manifestStep) {
+		visited[step.plannedID] = true
+		for _, member := range step.desired.Members {
+			if memberStep, ok := created[member.MemberID]; ok && !visited[member.MemberID] {
+				visit(memberStep)
+			}
+		}
+		ordered = append(ordered, step)
+	}
+	for _, step := range steps {
+		if step.change.Action == ManifestCreate && !visited[step.plannedID] {
+			visit(step)
+		}
+	}
+	return ordered
+}
+
+// resolvePlannedMembers replaces the planned ids of the members of the healthcheck by the saved ids.
+func resolvePlannedMembers(healthcheck *repository.Healthcheck, ids map[int]int) {
+	for i := range healthcheck.Members {
+		healthcheck.Members[i].MemberID = plannedOrSaved(healthcheck.Members[i].MemberID, ids)
+	}
+}
+
+// plannedOrSaved returns the saved id of a created healthcheck if id is its planned id, id otherwise.
+func plannedOrSaved(id int, ids map[int]int) int {
+	if saved, ok := ids[id]; ok {
+		return saved
+	}
+	return id
+}
@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// manifestRepo is a project with the healthchecks stored by an applied manifest.
+type manifestRepo struct {
+	repository.HealthcheckRepo
+	healthchecks []repository.Healthcheck
+}
+
+func (r manifestRepo) InProject(int) repository.HealthcheckRepo { return r }
+
+func (r manifestRepo) FindAll(context.Context) ([]repository.Healthcheck, error) {
+	return r.healthchecks, nil
+}
+
+// manifestDependencies accepts every dependency and keeps the stored ones.
+type manifestDependencies struct {
+	service.DependencyService
+	dependsOn map[int][]int
+}
+
+func (d manifestDependencies) Validate(context.Context, int, int, []int) error { return nil }
+
+func (d manifestDependencies) ValidateGraph(context.Context, map[int][]int, []int) error { return nil }
+
+func (d manifestDependencies) Graph(_ context.Context, healthcheckID int) (service.DependencyGraph, error) {
+	return service.DependencyGraph{HealthcheckID: healthcheckID, DependsOn: d.dependsOn[healthcheckID]}, nil
+}
+
+type manifestComposites struct {
+	service.CompositeService
+}
+
+func (manifestComposites) Validate(context.Context, repository.Healthcheck) error { return nil }
+
+func (manifestComposites) ValidateGraph(context.Context, map[int][]int, []int) error { return nil }
+
+// manifestTenants is an organization with count healthchecks of at most max ones, zero is unlimited.
+type manifestTenants struct {
+	count int
+	max   int
+}
+
+func (manifestTenants) CheckQuota(context.Context, repository.Healthcheck) error { return nil }
+
+func (t manifestTenants) CheckCount(_ context.Context, _ int, created int) error {
+	if t.max > 0 && t.count+created > t.max {
+		return fmt.Errorf("%w: organization 1 has at most %d healthchecks", service.ErrQuotaExceeded, t.max)
+	}
+	return nil
+}
+
+const baseManifest = `
+projectId: 1
+healthchecks:
+  - externalName: api
+    url: https://api.example.com/health
+    IntervalSeconds: 30
+    labels:
+      team: payments
+      app.kubernetes.io/name: api
+    headers:
+      Accept: application/json
+    validStatusCodes: [200, 204]
+    activeHours:
+      - days: [mon, fri]
+        from: "09:00"
+        to: "17:00"
+  - externalName: web
+    url: https://web.example.com
+    cronExpression: "*/5 * * * *"
+    dependsOn: [3, 1]
+  - externalName: checkout
+    kind: composite
+    compositeRule: all
+    IntervalSeconds: 60
+    members:
+      - id: 2
+      - id: 1
+        weight: 2
+`
+
+// planManifestTest plans the manifest against the healthchecks and dependencies of the project.
+func planManifestTest(t *testing.T, healthchecks []repository.Healthcheck, dependsOn map[int][]int,
+	tenants manifestTenants, data string) (*ManifestPlan, []manifestStep) {
+	manifest, err := request.ParseManifest([]byte(data), request.ManifestYAML)
+	if err != nil {
+		t.Fatalf("failed to parse manifest: %s", err)
+	}
+
+	h := HealthcheckHandler{
+		HealthcheckRepo:   manifestRepo{healthchecks: healthchecks},
+		DependencyService: manifestDependencies{dependsOn: dependsOn},
+		CompositeService:  manifestComposites{},
+		TenantService:     tenants,
+	}
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/manifest/plan", nil),
+		httptest.NewRecorder())
+	plan, steps, err := h.planManifest(c, manifest.ProjectID, manifest)
+	if err != nil {
+		t.Fatalf("failed to plan manifest: %s", err)
+	}
+	return plan, steps
+}
+
+// storeManifest stores the created healthchecks like the database returns them, the empty collections are
+// empty and the members and dependencies are in another order.
+func storeManifest(steps []manifestStep) ([]repository.Healthcheck, map[int][]int) {
+	var healthchecks []repository.Healthcheck
+	dependsOn := make(map[int][]int)
+	for i, step := range steps {
+		healthcheck := *step.desired
+		healthcheck.ID = i + 1
+		healthcheck.Version = 1
+		if healthcheck.Labels == nil {
+			healthcheck.Labels = map[string]string{}
+		}
+		if healthcheck.Headers == nil {
+			healthcheck.Headers = repository.Headers{}
+		}
+		sort.Slice(healthcheck.Members, func(i, j int) bool {
+			return healthcheck.Members[i].MemberID > healthcheck.Members[j].MemberID
+		})
+		healthchecks = append(healthchecks, healthcheck)
+
+		parents := append([]int{}, step.dependsOn...)
+		sort.Sort(sort.Reverse(sort.IntSlice(parents)))
+		dependsOn[healthcheck.ID] = parents
+	}
+	return healthchecks, dependsOn
+}
+
+func TestPlanManifestIsIdempotent(t *testing.T) {
+	plan, steps := planManifestTest(t, nil, nil, manifestTenants{}, baseManifest)
+	for _, change := range plan.Changes {
+		if change.Action != ManifestCreate || change.Error != "" {
+			t.Fatalf("change is %+v, want a create", change)
+		}
+	}
+	healthchecks, dependsOn := storeManifest(steps)
+	// the healthchecks without an external name aren't managed by manifests.
+	healthchecks = append(healthchecks, repository.Healthcheck{ID: 4, ProjectID: 1, Name: "manual",
+		Kind: repository.KindHTTP})
+
+	tests := []struct {
+		name      string
+		manifest  string
+		want      []ManifestChange
+		unchanged int
+	}{
+		{"same manifest", baseManifest, nil, 3},
+		{"reordered manifest", `
+projectId: 1
+healthchecks:
+  - externalName: checkout
+    kind: composite
+    compositeRule: all
+    IntervalSeconds: 60
+    members:
+      - id: 1
+        weight: 2
+      - id: 2
+        weight: 1
+  - externalName: web
+    url: https://web.example.com
+    cronExpression: "*/5 * * * *"
+    dependsOn: [1, 3]
+  - externalName: api
+    url: https://api.example.com/health
+    IntervalSeconds: 30
+    labels:
+      app.kubernetes.io/name: api
+      team: payments
+    headers:
+      Accept: application/json
+    validStatusCodes: [200, 204]
+    activeHours:
+      - days: [mon, fri]
+        from: "09:00"
+        to: "17:00"
+`, nil, 3},
+		{"changed and removed", `
+projectId: 1
+healthchecks:
+  - externalName: api
+    url: https://api.example.com/ready
+    IntervalSeconds: 30
+    labels:
+      team: payments
+      app.kubernetes.io/name: api
+    headers:
+      Accept: application/json
+    validStatusCodes: [200, 204]
+    activeHours:
+      - days: [mon, fri]
+        from: "09:00"
+        to: "17:00"
+  - externalName: web
+    url: https://web.example.com
+    cronExpression: "*/5 * * * *"
+    dependsOn: [3, 1]
+`, []ManifestChange{
+			{Action: ManifestUpdate, ExternalName: "api", ID: 1, Changes: map[string]service.AuditChange{
+				"url": {From: []byte(`"https://api.example.com/health"`), To: []byte(`"https://api.example.com/ready"`)},
+			}},
+			{Action: ManifestDelete, ExternalName: "checkout", ID: 3},
+		}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, _ := planManifestTest(t, healthchecks, dependsOn, manifestTenants{}, test.manifest)
+			if plan.Unchanged != test.unchanged {
+				t.Errorf("unchanged is %d, want %d", plan.Unchanged, test.unchanged)
+			}
+			var got []ManifestChange
+			if len(plan.Changes) > 0 {
+				got = plan.Changes
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("changes are %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanManifestCountsCreatedHealthchecks(t *testing.T) {
+	existing := []repository.Healthcheck{
+		{ID: 1, ProjectID: 1, ExternalName: "legacy", Kind: repository.KindHTTP, Url: "https://legacy.example.com",
+			IntervalSeconds: 60},
+		{ID: 2, ProjectID: 1, Name: "manual", Kind: repository.KindHTTP},
+	}
+	tests := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{"unlimited", 0, ""},
+		{"deleted healthcheck makes room", 4, ""},
+		{"exceeded", 3, "quota exceeded: organization 1 has at most 3 healthchecks"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the three declared healthchecks replace legacy.
+			plan, _ := planManifestTest(t, existing, nil, manifestTenants{count: len(existing), max: test.max},
+				baseManifest)
+			if plan.Error != test.want {
+				t.Errorf("error is %q, want %q", plan.Error, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanManifestResolvesExternalNames(t *testing.T) {
+	existing := []repository.Healthcheck{
+		{ID: 7, ProjectID: 1, ExternalName: "payments-db", Kind: repository.KindHTTP,
+			Url: "https://db.example.com", IntervalSeconds: 60},
+	}
+	tests := []struct {
+		name      string
+		manifest  string
+		dependsOn map[string][]int
+		members   map[string][]int
+		errors    map[string]string
+	}{
+		{"existing and created healthchecks", `
+projectId: 1
+healthchecks:
+  - externalName: checkout
+    kind: composite
+    compositeRule: all
+    IntervalSeconds: 60
+    members:
+      - id: payments-api
+      - id: 3
+  - externalName: payments-api
+    url: https://api.example.com
+    IntervalSeconds: 60
+    dependsOn: [payments-db]
+  - externalName: payments-db
+    url: https://db.example.com
+    IntervalSeconds: 60
+  - externalName: web
+    url: https://web.example.com
+    IntervalSeconds: 60
+    dependsOn: [checkout, payments-db]
+`, map[string][]int{"payments-api": {7}, "web": {-1, 7}},
+			map[string][]int{"checkout": {-2, 3}}, nil},
+		{"undeclared healthcheck", `
+projectId: 1
+healthchecks:
+  - externalName: payments-api
+    url: https://api.example.com
+    IntervalSeconds: 60
+    dependsOn: [payments-cache]
+`, nil, nil, map[string]string{
+			"payments-api": `bad request: healthcheck "payments-cache" isn't declared by the manifest`,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, steps := planManifestTest(t, existing, nil, manifestTenants{}, test.manifest)
+			for _, step := range steps {
+				name := step.change.ExternalName
+				if step.change.Error != test.errors[name] {
+					t.Errorf("error of %s is %q, want %q", name, step.change.Error, test.errors[name])
+				}
+				if step.desired == nil {
+					continue
+				}
+				if !reflect.DeepEqual(step.dependsOn, test.dependsOn[name]) {
+					t.Errorf("dependencies of %s are %v, want %v", name, step.dependsOn, test.dependsOn[name])
+				}
+				var members []int
+				for _, member := range step.desired.Members {
+					members = append(members, member.MemberID)
+				}
+				if !reflect.DeepEqual(members, test.members[name]) {
+					t.Errorf("members of %s are %v, want %v", name, members, test.members[name])
+				}
+			}
+		})
+	}
+}
+
+func TestManifestCreationsCreateMembersFirst(t *testing.T) {
+	step := func(plannedID int, action string, members ...int) manifestStep {
+		desired := &repository.Healthcheck{}
+		for _, member := range members {
+			desired.Members = append(desired.Members, repository.CompositeMember{MemberID: member})
+		}
+		return manifestStep{change: &ManifestChange{Action: action}, desired: desired, plannedID: plannedID}
+	}
+	steps := []manifestStep{
+		step(-1, ManifestCreate, -3, 5),
+		step(0, ManifestUpdate, -2),
+		step(-2, ManifestCreate),
+		step(-3, ManifestCreate, -4),
+		step(-4, ManifestCreate),
+	}
+
+	var got []int
+	for _, created := range manifestCreations(steps) {
+		got = append(got, created.plannedID)
+	}
+	want := []int{-4, -3, -1, -2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("creations are %v, want %v", got, want)
+	}
+}
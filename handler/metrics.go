@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/therealak12/api-health-check/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsHandler exposes the metrics of a registry to Prometheus.
+type MetricsHandler struct {
+	Registry *metrics.Registry
+}
+
+func NewMetricsHandler(registry *metrics.Registry) MetricsHandler {
+	return MetricsHandler{Registry: registry}
+}
+
+// Metrics responds the metrics of every project, so credentials limited to a project are rejected.
+func (h MetricsHandler) Metrics(c echo.Context) error {
+	if err := requireUnscoped(c); err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+
+	return h.Registry.Write(c.Response())
+}
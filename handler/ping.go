@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// PingHandler handles the pings of heartbeat healthchecks.
+type PingHandler struct {
+	HealthcheckService service.HealthcheckService
+}
+
+func NewPingHandler(healthcheckService service.HealthcheckService) PingHandler {
+	return PingHandler{
+		HealthcheckService: healthcheckService,
+	}
+}
+
+// Success reports the job succeeded.
+func (h PingHandler) Success(c echo.Context) error {
+	return h.ping(c, service.PingSuccess)
+}
+
+// Start reports the job started.
+func (h PingHandler) Start(c echo.Context) error {
+	return h.ping(c, service.PingStart)
+}
+
+// Fail reports the job failed.
+func (h PingHandler) Fail(c echo.Context) error {
+	return h.ping(c, service.PingFail)
+}
+
+// ExitCode reports the exit code of the job.
+func (h PingHandler) ExitCode(c echo.Context) error {
+	req := &request.PingExitCode{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("ping: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	return h.send(c, req.Token, service.Ping{Kind: service.PingExitCode, ExitCode: req.ExitCode})
+}
+
+func (h PingHandler) ping(c echo.Context, kind string) error {
+	req := &request.Ping{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("ping: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	return h.send(c, req.Token, service.Ping{Kind: kind})
+}
+
+func (h PingHandler) send(c echo.Context, token string, ping service.Ping) error {
+	if err := h.HealthcheckService.Ping(c.Request().Context(), token, ping); err != nil {
+		if errors.Is(err, service.ErrPingNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "ping token not found")
+		}
+		logrus.Errorf("failed to record ping: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to record ping")
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/therealak12/api-health-check/metrics"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultProbeModule = "http_2xx"
+	// scrapeTimeoutOffset is subtracted from the Prometheus scrape timeout to leave time for the response.
+	scrapeTimeoutOffset = 500 * time.Millisecond
+	// minProbeTimeout is the timeout of the probes of scrapes with shorter timeouts than the offset.
+	minProbeTimeout = 100 * time.Millisecond
+)
+
+// ProbeHandler handles the blackbox exporter compatible probes.
+type ProbeHandler struct {
+	ProbeService service.ProbeService
+}
+
+func NewProbeHandler(probeService service.ProbeService) ProbeHandler {
+	return ProbeHandler{
+		ProbeService: probeService,
+	}
+}
+
+func (h ProbeHandler) Probe(c echo.Context) error {
+	req := &request.Probe{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("probe: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	if req.Module == "" {
+		req.Module = defaultProbeModule
+	}
+
+	ctx := c.Request().Context()
+	if seconds, err := strconv.ParseFloat(c.Request().Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64); err == nil {
+		timeout := time.Duration(seconds*float64(time.Second)) - scrapeTimeoutOffset
+		if timeout < minProbeTimeout {
+			timeout = minProbeTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	if err := h.ProbeService.Probe(ctx, req.Target, req.Module, c.Response()); err != nil {
+		if errors.Is(err, service.ErrUnknownProbeModule) {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err.Error()))
+		}
+		logrus.Errorf("failed to probe %s: %s", req.Target, err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to probe target")
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultReportRange = 30 * 24 * time.Hour
+
+// ReportHandler handles the reports of healthchecks.
+type ReportHandler struct {
+	HealthcheckRepo repository.HealthcheckRepo
+	ReportService   service.ReportService
+}
+
+func NewReportHandler(healthcheckRepo repository.HealthcheckRepo, reportService service.ReportService) ReportHandler {
+	return ReportHandler{
+		HealthcheckRepo: healthcheckRepo,
+		ReportService:   reportService,
+	}
+}
+
+func (h ReportHandler) Uptime(c echo.Context) error {
+	req := &request.GetHealthcheckUptime{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("get healthcheck uptime: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	from, to, err := reportRange(req.From, req.To)
+	if err != nil {
+		return err
+	}
+	if req.Granularity == "" {
+		req.Granularity = repository.GranularityDay
+	}
+
+	if err := h.findHealthcheck(c, req.ID); err != nil {
+		return err
+	}
+
+	excluded := make([]repository.TimeRange, 0, len(req.Exclude))
+	for _, r := range req.Exclude {
+		excluded = append(excluded, repository.TimeRange{From: r.From, To: r.To})
+	}
+
+	report, err := h.ReportService.Uptime(c.Request().Context(), req.ID, from, to, req.Granularity, excluded)
+	if errors.Is(err, service.ErrHourlyUptimeExpired) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bad request: %s", err))
+	}
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck uptime: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck uptime")
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+func (h ReportHandler) Latency(c echo.Context) error {
+	req := &request.GetHealthcheckLatency{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("get healthcheck latency: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	from, to, err := reportRange(req.From, req.To)
+	if err != nil {
+		return err
+	}
+
+	if err := h.findHealthcheck(c, req.ID); err != nil {
+		return err
+	}
+
+	report, err := h.ReportService.Latency(c.Request().Context(), req.ID, from, to)
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck latency: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck latency")
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// findHealthcheck checks the healthcheck exists in the project of the request.
+func (h ReportHandler) findHealthcheck(c echo.Context, id int) error {
+	if _, err := h.HealthcheckRepo.InProject(projectScope(c)).FindOne(c.Request().Context(), id); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "healthcheck id not found")
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck")
+	}
+
+	return nil
+}
+
+// reportRange fills the missing ends of a report range, to defaults to now and from to 30 days before to.
+func reportRange(from, to time.Time) (time.Time, time.Time, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultReportRange)
+	}
+	if !to.After(from) {
+		return from, to, echo.NewHTTPError(http.StatusBadRequest, "bad request: to must be after from")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// Revisions lists the revisions of the definition of the healthcheck, the latest first. The events refer
+// to the revision they were produced by.
+func (h HealthcheckHandler) Revisions(c echo.Context) error {
+	req := &request.ListHealthcheckRevisions{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("list healthcheck revisions: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	if _, err := h.findHealthcheck(c, req.ID); err != nil {
+		return err
+	}
+
+	revisions, err := h.HealthcheckRevisionRepo.FindAll(c.Request().Context(), req.ID)
+	if err != nil {
+		logrus.Errorf("failed to list healthcheck revisions: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list healthcheck revisions")
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+// RestoreRevision updates the healthcheck with the definition of one of its revisions, which is kept as a
+// new revision. The dependencies aren't part of the revisions, they are kept.
+func (h HealthcheckHandler) RestoreRevision(c echo.Context) error {
+	req := &request.RestoreHealthcheckRevision{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("restore healthcheck revision: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+
+	current, err := h.findHealthcheck(c, req.ID)
+	if err != nil {
+		return err
+	}
+	revision, err := h.HealthcheckRevisionRepo.FindOne(c.Request().Context(), req.ID, req.Revision)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "healthcheck revision not found")
+		}
+		logrus.Errorf("failed to get healthcheck revision: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck revision")
+	}
+	graph, err := h.DependencyService.Graph(c.Request().Context(), current.ID)
+	if err != nil {
+		logrus.Errorf("failed to get healthcheck dependencies: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck dependencies")
+	}
+
+	// the revision is checked like an update, e.g. a member of a composite may have been deleted since.
+	restored := createRequest(revision.Definition.ApplyTo(current), graph.DependsOn)
+	if err := c.Validate(restored); err != nil {
+		return err
+	}
+
+	return h.update(c, current, restored)
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/request"
+	"github.com/therealak12/api-health-check/service"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// TenantHandler manages the organizations and their projects. The organizations can only be managed by
+// requests which aren't limited to a project.
+type TenantHandler struct {
+	OrganizationRepo repository.OrganizationRepo
+	ProjectRepo      repository.ProjectRepo
+	AuditService     service.AuditService
+	Transactor       repository.Transactor
+}
+
+func NewTenantHandler(organizationRepo repository.OrganizationRepo,
+	projectRepo repository.ProjectRepo,
+	auditService service.AuditService,
+	transactor repository.Transactor) TenantHandler {
+	return TenantHandler{
+		OrganizationRepo: organizationRepo,
+		ProjectRepo:      projectRepo,
+		AuditService:     auditService,
+		Transactor:       transactor,
+	}
+}
+
+func (h TenantHandler) ListOrganizations(c echo.Context) error {
+	if err := requireUnscoped(c); err != nil {
+		return err
+	}
+
+	organizations, err := h.OrganizationRepo.FindAll(c.Request().Context())
+	if err != nil {
+		logrus.Errorf("failed to list organizations: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list organizations")
+	}
+
+	return c.JSON(http.StatusOK, organizations)
+}
+
+func (h TenantHandler) CreateOrganization(c echo.Context) error {
+	req := &request.CreateOrganization{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("create organization: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	if err := requireUnscoped(c); err != nil {
+		return err
+	}
+
+	organization := &repository.Organization{
+		Name:               req.Name,
+		MaxHealthchecks:    req.MaxHealthchecks,
+		MinIntervalSeconds: req.MinIntervalSeconds,
+	}
+	err := inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.OrganizationRepo.Save(ctx, organization); err != nil {
+			logrus.Errorf("failed to create organization: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create organization")
+		}
+		return recordAudit(ctx, c, h.AuditService, service.AuditCreate, service.ResourceOrganization,
+			organization.ID, 0, nil, organization)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, organization)
+}
+
+// UpdateOrganization replaces the name and the quotas of the organization, the quotas apply to the
+// healthchecks created or updated afterwards.
+func (h TenantHandler) UpdateOrganization(c echo.Context) error {
+	req := &request.UpdateOrganization{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("update organization: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	if err := requireUnscoped(c); err != nil {
+		return err
+	}
+
+	organization, err := h.OrganizationRepo.FindOne(c.Request().Context(), req.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "organization id not found")
+		}
+		logrus.Errorf("failed to get organization: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get organization")
+	}
+
+	before := organization
+	organization.Name = req.Name
+	organization.MaxHealthchecks = req.MaxHealthchecks
+	organization.MinIntervalSeconds = req.MinIntervalSeconds
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.OrganizationRepo.Save(ctx, &organization); err != nil {
+			logrus.Errorf("failed to update organization: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update organization")
+		}
+		return recordAudit(ctx, c, h.AuditService, service.AuditUpdate, service.ResourceOrganization,
+			organization.ID, 0, before, organization)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, organization)
+}
+
+// ListProjects lists the projects the request can access.
+func (h TenantHandler) ListProjects(c echo.Context) error {
+	projects, err := h.ProjectRepo.FindAll(c.Request().Context(), projectScope(c))
+	if err != nil {
+		logrus.Errorf("failed to list projects: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list projects")
+	}
+
+	return c.JSON(http.StatusOK, projects)
+}
+
+func (h TenantHandler) CreateProject(c echo.Context) error {
+	req := &request.CreateProject{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("create project: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	if err := requireUnscoped(c); err != nil {
+		return err
+	}
+
+	if _, err := h.OrganizationRepo.FindOne(c.Request().Context(), req.OrganizationID); err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("bad request: organization %d not found", req.OrganizationID))
+		}
+		logrus.Errorf("failed to get organization: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create project")
+	}
+
+	project := &repository.Project{
+		OrganizationID: req.OrganizationID,
+		Name:           req.Name,
+		WebhookURL:     req.WebhookURL,
+	}
+	err := inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.ProjectRepo.Save(ctx, project); err != nil {
+			logrus.Errorf("failed to create project: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create project")
+		}
+		return recordAudit(ctx, c, h.AuditService, service.AuditCreate, service.ResourceProject, project.ID,
+			project.ID, nil, project)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, project)
+}
+
+// UpdateProject replaces the name and the notification settings of the project.
+func (h TenantHandler) UpdateProject(c echo.Context) error {
+	req := &request.UpdateProject{}
+
+	if err := c.Bind(req); err != nil {
+		logrus.Errorf("update project: bind failed: %s", err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("bind request failed: %s", err))
+	}
+
+	if err := c.Validate(req); err != nil {
+		return err
+	}
+	if _, err := projectFor(c, req.ID, 0); err != nil {
+		return err
+	}
+
+	project, err := h.ProjectRepo.FindOne(c.Request().Context(), req.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "project id not found")
+		}
+		logrus.Errorf("failed to get project: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get project")
+	}
+
+	before := project
+	project.Name = req.Name
+	project.WebhookURL = req.WebhookURL
+	err = inTransaction(c, h.Transactor, func(ctx context.Context) error {
+		if err := h.ProjectRepo.Save(ctx, &project); err != nil {
+			logrus.Errorf("failed to update project: %s", err)
+
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update project")
+		}
+		return recordAudit(ctx, c, h.AuditService, service.AuditUpdate, service.ResourceProject, project.ID,
+			project.ID, before, project)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, project)
+}
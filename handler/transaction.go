@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/therealak12/api-health-check/repository"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// inTransaction runs fn in a transaction with the context of the request, fn returns http errors. The
+// transaction is rolled back if fn fails.
+func inTransaction(c echo.Context, transactor repository.Transactor, fn func(ctx context.Context) error) error {
+	err := transactor.Transaction(c.Request().Context(), fn)
+	var httpError *echo.HTTPError
+	if err != nil && !errors.As(err, &httpError) {
+		logrus.Errorf("failed to commit transaction: %s", err)
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to commit changes")
+	}
+	return err
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"github.com/therealak12/api-health-check/cli"
 	"github.com/therealak12/api-health-check/handler"
+	"github.com/therealak12/api-health-check/metrics"
+	"github.com/therealak12/api-health-check/migrations"
 	"github.com/therealak12/api-health-check/repository"
 	"github.com/therealak12/api-health-check/service"
+	"github.com/therealak12/api-health-check/tracing"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
@@ -20,33 +25,188 @@ import (
 )
 
 func main() {
+	// the commands are clients of a running server.
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[0], os.Args[1:]))
+	}
+
 	server := echo.New()
 
-	server.Validator = request.NewValidator()
-
-	server.Use(middleware.Recover())
-	server.Use(middleware.CORS())
-	server.Use(middleware.RemoveTrailingSlash())
-	server.Use(middleware.Logger())
+	validator := request.NewValidator()
+	server.Validator = validator
+	server.HTTPErrorHandler = validator.HTTPErrorHandler(server.DefaultHTTPErrorHandler)
 
 	cfg := config.New()
 	logrus.SetOutput(os.Stdout)
 	logrus.SetLevel(cfg.Logger.Level)
 
+	if cfg.Auth.Enabled && cfg.Auth.AdminKey == "" && cfg.Auth.JWT.Secret == "" {
+		logrus.Fatal("authentication is enabled without an admin key or a JWT secret")
+	}
+
+	// the client ip is recorded in the audit log, it's only taken from the headers of trusted proxies.
+	server.IPExtractor = echo.ExtractIPDirect()
+	if len(cfg.Proxy.TrustedRanges) > 0 {
+		options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false),
+			echo.TrustPrivateNet(false)}
+		for _, trusted := range cfg.Proxy.TrustedRanges {
+			_, ipRange, err := net.ParseCIDR(trusted)
+			if err != nil {
+				logrus.Fatalf("invalid trusted proxy range %q: %s", trusted, err)
+			}
+			options = append(options, echo.TrustIPRange(ipRange))
+		}
+		server.IPExtractor = echo.ExtractIPFromXFFHeader(options...)
+	}
+
+	server.Use(middleware.Recover())
+	// echo allows every origin when none is configured.
+	if len(cfg.CORS.AllowOrigins) > 0 {
+		server.Use(middleware.CORSWithConfig(middleware.CORSConfig{
+			AllowOrigins:     cfg.CORS.AllowOrigins,
+			AllowHeaders:     cfg.CORS.AllowHeaders,
+			ExposeHeaders:    cfg.CORS.ExposeHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           int(cfg.CORS.MaxAge.Seconds()),
+		}))
+	}
+	server.Use(middleware.RemoveTrailingSlash())
+	server.Use(middleware.Logger())
+
 	db, err := database.NewPostgresInstance(cfg.Database)
 	if err != nil {
 		logrus.Fatalf("failed to connect to database: %s", err.Error())
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var tracer *tracing.Tracer
+	spansExported := make(chan struct{})
+	if cfg.Tracing.Enabled {
+		exporter := tracing.NewExporter(cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, cfg.Tracing.BatchSize,
+			cfg.Tracing.FlushInterval)
+		tracer = tracing.NewTracer(exporter)
+		go func() {
+			exporter.Start(ctx)
+			close(spansExported)
+		}()
+
+		server.Use(tracing.EchoMiddleware(tracer))
+		if err := db.Use(tracing.GormPlugin{Tracer: tracer}); err != nil {
+			logrus.Fatalf("failed to register tracing plugin: %s", err.Error())
+		}
+	} else {
+		close(spansExported)
+	}
+
 	healthcheckRepo := repository.SQLHealthcheckRepo{DB: db}
-	healthcheckEventRepo := repository.SQLHealthcheckEventRepo{DB: db}
-	healthcheckService := service.NewHealthcheckService(healthcheckRepo, healthcheckEventRepo, cfg.Webhook)
-	healthcheckHandler := handler.NewHealthcheckHandler(healthcheckRepo, healthcheckService)
+	healthcheckEventRepo := repository.NewBufferedHealthcheckEventRepo(repository.SQLHealthcheckEventRepo{DB: db},
+		cfg.Events.BufferSize, cfg.Events.FlushInterval)
+	eventsFlushed := make(chan struct{})
+	go func() {
+		healthcheckEventRepo.Start(ctx)
+		close(eventsFlushed)
+	}()
+	healthcheckDependencyRepo := repository.SQLHealthcheckDependencyRepo{DB: db}
+	organizationRepo := repository.SQLOrganizationRepo{DB: db}
+	projectRepo := repository.SQLProjectRepo{DB: db}
+	transactor := repository.SQLTransactor{DB: db}
+	dependencyService := service.NewDependencyService(healthcheckRepo, healthcheckEventRepo, healthcheckDependencyRepo)
+	compositeService := service.NewCompositeService(healthcheckRepo, healthcheckEventRepo)
+	tenantService := service.NewTenantService(organizationRepo, projectRepo)
+	auditService := service.NewAuditService(repository.SQLAuditLogRepo{DB: db})
+	healthcheckService := service.NewHealthcheckService(healthcheckRepo, healthcheckEventRepo, dependencyService,
+		compositeService, projectRepo, cfg.Webhook, tracer)
+	healthcheckHandler := handler.NewHealthcheckHandler(healthcheckRepo,
+		repository.SQLHealthcheckRevisionRepo{DB: db}, healthcheckService, dependencyService, compositeService,
+		tenantService, auditService, transactor)
+	tenantHandler := handler.NewTenantHandler(organizationRepo, projectRepo, auditService, transactor)
+	auditHandler := handler.NewAuditHandler(auditService)
+	healthcheckEventRollupRepo := repository.SQLHealthcheckEventRollupRepo{DB: db}
+	reportService := service.NewReportService(healthcheckEventRepo, healthcheckEventRollupRepo, cfg.Retention)
+	reportHandler := handler.NewReportHandler(healthcheckRepo, reportService)
+	eventHandler := handler.NewEventHandler(healthcheckRepo, healthcheckEventRepo)
+	metricsHandler := handler.NewMetricsHandler(metrics.Default)
+	pingHandler := handler.NewPingHandler(healthcheckService)
+	probeHandler := handler.NewProbeHandler(service.NewProbeService(cfg.Probe, tracer))
+	schemaVersion, err := migrations.LatestVersion()
+	if err != nil {
+		logrus.Fatalf("failed to read migrations: %s", err.Error())
+	}
+	watchdogService := service.NewWatchdogService(cfg.Watchdog)
+	healthHandler := handler.NewHealthHandler(service.NewHealthService(repository.SQLSchemaRepo{DB: db},
+		watchdogService, schemaVersion))
 
-	server.GET("/healthchecks", healthcheckHandler.List)
-	server.POST("/healthchecks", healthcheckHandler.Register)
-	server.GET("/healthchecks/:id/start", healthcheckHandler.Start)
-	server.GET("/healthchecks/:id/stop", healthcheckHandler.Stop)
-	server.DELETE("/healthchecks/:id", healthcheckHandler.Delete)
+	if !cfg.Auth.Enabled {
+		logrus.Warn("authentication is disabled, every client has the admin role")
+	}
+	authHandler := handler.NewAuthHandler(service.NewAuthService(repository.SQLAPIKeyRepo{DB: db}, cfg.Auth),
+		projectRepo, auditService, transactor, cfg.Auth.Enabled)
+	viewer := authHandler.Require(service.RoleViewer)
+	editor := authHandler.Require(service.RoleEditor)
+	admin := authHandler.Require(service.RoleAdmin)
+
+	server.GET("/healthchecks", healthcheckHandler.List, viewer)
+	server.POST("/healthchecks", healthcheckHandler.Register, editor)
+	server.POST("/healthchecks/test", healthcheckHandler.Test, editor)
+	server.POST("/healthchecks/bulk/start", healthcheckHandler.BulkStart, editor)
+	server.POST("/healthchecks/bulk/stop", healthcheckHandler.BulkStop, editor)
+	server.POST("/healthchecks/bulk/delete", healthcheckHandler.BulkDelete, editor)
+	server.POST("/healthchecks/bulk/update", healthcheckHandler.BulkUpdate, editor)
+	server.POST("/healthchecks/:id/run", healthcheckHandler.Run, editor)
+	server.GET("/healthchecks/:id", healthcheckHandler.Get, viewer)
+	server.PUT("/healthchecks/:id", healthcheckHandler.Update, editor)
+	server.PATCH("/healthchecks/:id", healthcheckHandler.Patch, editor)
+	server.POST("/healthchecks/:id/start", healthcheckHandler.Start, editor)
+	server.POST("/healthchecks/:id/stop", healthcheckHandler.Stop, editor)
+	server.DELETE("/healthchecks/:id", healthcheckHandler.Delete, editor)
+	server.GET("/healthchecks/:id/dependencies", healthcheckHandler.Dependencies, viewer)
+	server.GET("/healthchecks/:id/revisions", healthcheckHandler.Revisions, viewer)
+	server.POST("/healthchecks/:id/revisions/:rev/restore", healthcheckHandler.RestoreRevision, editor)
+	server.POST("/manifest/plan", healthcheckHandler.PlanManifest, viewer)
+	server.POST("/manifest/apply", healthcheckHandler.ApplyManifest, editor)
+	server.POST("/import/postman", healthcheckHandler.ImportPostman, editor)
+	server.GET("/healthchecks/:id/uptime", reportHandler.Uptime, viewer)
+	server.GET("/healthchecks/:id/latency", reportHandler.Latency, viewer)
+	server.GET("/healthchecks/:id/events", eventHandler.ListForHealthcheck, viewer)
+	server.GET("/healthchecks/:id/status", eventHandler.Status, viewer)
+	server.GET("/events", eventHandler.List, viewer)
+	server.GET("/metrics", metricsHandler.Metrics, viewer)
+	// probes send requests to any target, they are limited to the clients which can create healthchecks.
+	server.GET("/probe", probeHandler.Probe, editor)
+	server.POST("/ping/:token", pingHandler.Success)
+	server.POST("/ping/:token/start", pingHandler.Start)
+	server.POST("/ping/:token/fail", pingHandler.Fail)
+	server.POST("/ping/:token/:exitCode", pingHandler.ExitCode)
+	server.GET("/healthz", healthHandler.Healthz)
+	server.GET("/readyz", healthHandler.Readyz)
+	server.GET("/me", authHandler.Me, viewer)
+	server.GET("/apikeys", authHandler.ListAPIKeys, admin)
+	server.POST("/apikeys", authHandler.CreateAPIKey, admin)
+	server.DELETE("/apikeys/:id", authHandler.DeleteAPIKey, admin)
+	server.GET("/organizations", tenantHandler.ListOrganizations, admin)
+	server.POST("/organizations", tenantHandler.CreateOrganization, admin)
+	server.PUT("/organizations/:id", tenantHandler.UpdateOrganization, admin)
+	server.GET("/projects", tenantHandler.ListProjects, viewer)
+	server.POST("/projects", tenantHandler.CreateProject, admin)
+	server.PUT("/projects/:id", tenantHandler.UpdateProject, admin)
+	server.GET("/audit", auditHandler.List, admin)
+
+	partitionService := service.NewPartitionService(repository.SQLHealthcheckEventPartitionRepo{DB: db},
+		cfg.Events.PremakeMonths)
+	go partitionService.Start(ctx)
+	go watchdogService.Start(ctx)
+
+	if cfg.Heartbeat.Enabled {
+		heartbeatService := service.NewHeartbeatService(watchdogService, cfg.Heartbeat)
+		go heartbeatService.Start(ctx)
+	}
+
+	if cfg.Retention.Enabled {
+		retentionService := service.NewRetentionService(healthcheckEventRepo, healthcheckEventRollupRepo,
+			partitionService, cfg.Retention)
+		go retentionService.Start(ctx)
+	}
 
 	go func() {
 		err := server.Start(":8080")
@@ -60,10 +220,14 @@ func main() {
 	s := <-sig
 	logrus.Infof("got signal %s, shutting down", s)
 
-	ctx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+	cancel()
+	<-eventsFlushed
+	<-spansExported
+
+	shutdownCtx, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelFunc()
 
-	if err := server.Shutdown(ctx); err != nil {
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		logrus.Errorf("failed to shutdown gracefully: %s", err.Error())
 	}
 }
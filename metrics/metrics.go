@@ -0,0 +1,355 @@
+// Package metrics implements the subset of Prometheus metric types used by the service and writes them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default histogram buckets in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the service metrics are registered to.
+var Default = NewRegistry()
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics, in the order they are registered.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Write writes all the metrics of the registry in the text exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+// desc describes a metric family.
+type desc struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.metricType)
+}
+
+// key joins the label values and the extra labels to be used as a map key, the key of the label values
+// alone is a prefix of it.
+func (d desc) key(labelValues []string, extra []string) string {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff") + "\xfe" + strings.Join(extra, "\xff")
+}
+
+// labelPairs flattens the extra labels of a series into name, value pairs sorted by name.
+func labelPairs(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	pairs := make([]string, 0, 2*len(labels))
+	for _, name := range sortStrings(names) {
+		pairs = append(pairs, name, labels[name])
+	}
+	return pairs
+}
+
+type sample struct {
+	labelValues []string
+	extra       []string
+	value       float64
+}
+
+// valueVec is a gauge or counter family partitioned by label values.
+type valueVec struct {
+	desc
+	mu      sync.Mutex
+	samples map[string]*sample
+}
+
+func newValueVec(registry *Registry, metricType, name, help string, labels []string) *valueVec {
+	v := &valueVec{
+		desc:    desc{name: name, help: help, metricType: metricType, labels: labels},
+		samples: make(map[string]*sample),
+	}
+	registry.register(v)
+
+	return v
+}
+
+func (v *valueVec) update(labelValues, extra []string, f func(value float64) float64) {
+	key := v.key(labelValues, extra)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: append([]string{}, labelValues...), extra: extra}
+		v.samples[key] = s
+	}
+	s.value = f(s.value)
+}
+
+// Delete removes the series with the given label values, whatever their extra labels are.
+func (v *valueVec) Delete(labelValues ...string) {
+	prefix := v.key(labelValues, nil)
+
+	v.mu.Lock()
+	for key := range v.samples {
+		if strings.HasPrefix(key, prefix) {
+			delete(v.samples, key)
+		}
+	}
+	v.mu.Unlock()
+}
+
+func (v *valueVec) write(w *bufio.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.writeHeader(w)
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	for _, key := range sortStrings(keys) {
+		s := v.samples[key]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, formatLabels(v.labels, s.labelValues, s.extra...), formatValue(s.value))
+	}
+}
+
+// GaugeVec is a gauge partitioned by label values.
+type GaugeVec struct {
+	*valueVec
+	extra []string
+}
+
+func NewGaugeVec(registry *Registry, name, help string, labels ...string) GaugeVec {
+	return GaugeVec{valueVec: newValueVec(registry, "gauge", name, help, labels)}
+}
+
+// With returns the gauge whose series have the extra labels besides the labels of the gauge, the series
+// with other extra labels are distinct ones.
+func (g GaugeVec) With(extra map[string]string) GaugeVec {
+	g.extra = labelPairs(extra)
+	return g
+}
+
+func (g GaugeVec) Set(value float64, labelValues ...string) {
+	g.update(labelValues, g.extra, func(float64) float64 { return value })
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct {
+	*valueVec
+	extra []string
+}
+
+func NewCounterVec(registry *Registry, name, help string, labels ...string) CounterVec {
+	return CounterVec{valueVec: newValueVec(registry, "counter", name, help, labels)}
+}
+
+// With returns the counter whose series have the extra labels, like GaugeVec.With.
+func (c CounterVec) With(extra map[string]string) CounterVec {
+	c.extra = labelPairs(extra)
+	return c
+}
+
+func (c CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: counter %s can't decrease", c.name))
+	}
+	c.update(labelValues, c.extra, func(value float64) float64 { return value + delta })
+}
+
+// GaugeFunc is a gauge without labels whose value is computed when it is written.
+type GaugeFunc struct {
+	desc
+	f func() float64
+}
+
+func NewGaugeFunc(registry *Registry, name, help string, f func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, metricType: "gauge"}, f: f}
+	registry.register(g)
+
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatValue(g.f()))
+}
+
+type histogramSample struct {
+	labelValues []string
+	extra       []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+type histogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	samples map[string]*histogramSample
+}
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct {
+	*histogramVec
+	extra []string
+}
+
+func NewHistogramVec(registry *Registry, name, help string, buckets []float64, labels ...string) HistogramVec {
+	h := &histogramVec{
+		desc:    desc{name: name, help: help, metricType: "histogram", labels: labels},
+		buckets: append([]float64{}, buckets...),
+		samples: make(map[string]*histogramSample),
+	}
+	sort.Float64s(h.buckets)
+	registry.register(h)
+
+	return HistogramVec{histogramVec: h}
+}
+
+// With returns the histogram whose series have the extra labels, like GaugeVec.With.
+func (h HistogramVec) With(extra map[string]string) HistogramVec {
+	h.extra = labelPairs(extra)
+	return h
+}
+
+func (h HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues, h.extra)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.samples[key]
+	if !ok {
+		s = &histogramSample{labelValues: append([]string{}, labelValues...), extra: h.extra,
+			counts: make([]uint64, len(h.buckets))}
+		h.samples[key] = s
+	}
+	for i, upperBound := range h.buckets {
+		if value <= upperBound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Delete removes the series with the given label values, whatever their extra labels are.
+func (h *histogramVec) Delete(labelValues ...string) {
+	prefix := h.key(labelValues, nil)
+
+	h.mu.Lock()
+	for key := range h.samples {
+		if strings.HasPrefix(key, prefix) {
+			delete(h.samples, key)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func (h *histogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w)
+	keys := make([]string, 0, len(h.samples))
+	for key := range h.samples {
+		keys = append(keys, key)
+	}
+	for _, key := range sortStrings(keys) {
+		s := h.samples[key]
+		for i, upperBound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				formatLabels(h.labels, s.labelValues, append(append([]string{}, s.extra...), "le", formatValue(upperBound))...), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+			formatLabels(h.labels, s.labelValues, append(append([]string{}, s.extra...), "le", "+Inf")...), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, s.labelValues, s.extra...), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, s.labelValues, s.extra...), s.count)
+	}
+}
+
+func sortStrings(keys []string) []string {
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], escapeLabelValue(extra[i+1])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
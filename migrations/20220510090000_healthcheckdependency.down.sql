@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS healthcheck_dependencies;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS state;
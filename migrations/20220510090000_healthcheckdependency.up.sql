@@ -0,0 +1,9 @@
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS state VARCHAR (16) NOT NULL DEFAULT 'up';
+UPDATE healthcheck_events SET state = 'down' WHERE status NOT SIMILAR TO '(2|3)[0-9]{2}%';
+
+CREATE TABLE IF NOT EXISTS healthcheck_dependencies(
+    healthcheck_id BIGINT NOT NULL REFERENCES healthchecks (id) ON DELETE CASCADE,
+    depends_on_id BIGINT NOT NULL REFERENCES healthchecks (id) ON DELETE CASCADE,
+    PRIMARY KEY (healthcheck_id, depends_on_id),
+    CHECK (healthcheck_id <> depends_on_id)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS composite_members;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS composite_threshold;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS composite_min_up;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS composite_rule;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS kind;
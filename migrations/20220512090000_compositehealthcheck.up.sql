@@ -0,0 +1,12 @@
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS kind VARCHAR (16) NOT NULL DEFAULT 'http';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS composite_rule VARCHAR (16) NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS composite_min_up INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS composite_threshold DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS composite_members(
+    composite_id BIGINT NOT NULL REFERENCES healthchecks (id) ON DELETE CASCADE,
+    member_id BIGINT NOT NULL REFERENCES healthchecks (id) ON DELETE CASCADE,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+    PRIMARY KEY (composite_id, member_id),
+    CHECK (composite_id <> member_id)
+);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS healthcheck_events_healthcheck_id_created_at_idx;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS transfer_ms;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS ttfb_ms;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS tls_ms;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS connect_ms;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS dns_ms;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS total_ms;
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS status_code;
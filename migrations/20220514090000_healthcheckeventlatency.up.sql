@@ -0,0 +1,9 @@
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS status_code INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS total_ms DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS dns_ms DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS connect_ms DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS tls_ms DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS ttfb_ms DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS transfer_ms DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS healthcheck_events_healthcheck_id_created_at_idx ON healthcheck_events (healthcheck_id, created_at);
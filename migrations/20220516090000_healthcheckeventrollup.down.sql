@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS healthcheck_events_created_at_idx;
+DROP TABLE IF EXISTS healthcheck_event_rollups;
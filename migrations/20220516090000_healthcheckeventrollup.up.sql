@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS healthcheck_event_rollups(
+    healthcheck_id BIGINT NOT NULL REFERENCES healthchecks (id) ON DELETE CASCADE,
+    granularity VARCHAR (8) NOT NULL, /* hour or day */
+    bucket timestamp NOT NULL,
+    up_count INTEGER NOT NULL DEFAULT 0,
+    down_count INTEGER NOT NULL DEFAULT 0,
+    unreachable_count INTEGER NOT NULL DEFAULT 0,
+    up_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    down_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    incidents INTEGER NOT NULL DEFAULT 0,
+    latency_count INTEGER NOT NULL DEFAULT 0,
+    p50_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    p90_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    p95_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    p99_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (healthcheck_id, granularity, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS healthcheck_events_created_at_idx ON healthcheck_events (created_at);
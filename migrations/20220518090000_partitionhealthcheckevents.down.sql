@@ -0,0 +1,32 @@
+ALTER TABLE healthcheck_events RENAME TO healthcheck_events_partitioned;
+ALTER INDEX healthcheck_events_pkey RENAME TO healthcheck_events_partitioned_pkey;
+ALTER INDEX healthcheck_events_healthcheck_id_created_at_idx RENAME TO healthcheck_events_partitioned_healthcheck_id_created_at_idx;
+ALTER INDEX healthcheck_events_created_at_idx RENAME TO healthcheck_events_partitioned_created_at_idx;
+
+CREATE TABLE healthcheck_events(
+    id bigserial PRIMARY KEY,
+    healthcheck_id BIGINT DEFAULT 1,
+    status TEXT NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now(),
+    state VARCHAR (16) NOT NULL DEFAULT 'up',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    total_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    dns_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    connect_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    tls_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ttfb_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    transfer_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    CONSTRAINT fk_category FOREIGN KEY (healthcheck_id) REFERENCES healthchecks (id) ON DELETE SET DEFAULT
+);
+
+CREATE INDEX IF NOT EXISTS healthcheck_events_healthcheck_id_created_at_idx ON healthcheck_events (healthcheck_id, created_at);
+CREATE INDEX IF NOT EXISTS healthcheck_events_created_at_idx ON healthcheck_events (created_at);
+
+INSERT INTO healthcheck_events SELECT id, healthcheck_id, status, created_at, state, status_code,
+    total_ms, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms
+FROM healthcheck_events_partitioned;
+
+SELECT setval(pg_get_serial_sequence('healthcheck_events', 'id'), COALESCE(MAX(id), 0) + 1, false)
+FROM healthcheck_events;
+
+DROP TABLE healthcheck_events_partitioned;
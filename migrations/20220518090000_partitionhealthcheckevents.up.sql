@@ -0,0 +1,50 @@
+ALTER TABLE healthcheck_events RENAME TO healthcheck_events_old;
+ALTER INDEX healthcheck_events_pkey RENAME TO healthcheck_events_old_pkey;
+ALTER INDEX healthcheck_events_healthcheck_id_created_at_idx RENAME TO healthcheck_events_old_healthcheck_id_created_at_idx;
+ALTER INDEX healthcheck_events_created_at_idx RENAME TO healthcheck_events_old_created_at_idx;
+
+CREATE TABLE healthcheck_events(
+    id bigserial,
+    healthcheck_id BIGINT DEFAULT 1,
+    status TEXT NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now(),
+    state VARCHAR (16) NOT NULL DEFAULT 'up',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    total_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    dns_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    connect_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    tls_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    ttfb_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    transfer_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (id, created_at),
+    CONSTRAINT fk_category FOREIGN KEY (healthcheck_id) REFERENCES healthchecks (id) ON DELETE SET DEFAULT
+) PARTITION BY RANGE (created_at);
+
+CREATE INDEX IF NOT EXISTS healthcheck_events_healthcheck_id_created_at_idx ON healthcheck_events (healthcheck_id, created_at);
+CREATE INDEX IF NOT EXISTS healthcheck_events_created_at_idx ON healthcheck_events (created_at);
+
+/* monthly partitions from the oldest event up to two months ahead, the application creates the later ones */
+DO $$
+DECLARE
+    month timestamp;
+BEGIN
+    FOR month IN SELECT generate_series(
+        date_trunc('month', LEAST((SELECT MIN(created_at) FROM healthcheck_events_old), now()::timestamp)),
+        date_trunc('month', now()::timestamp) + interval '2 month',
+        interval '1 month')
+    LOOP
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF healthcheck_events FOR VALUES FROM (%L) TO (%L)',
+            'healthcheck_events_' || to_char(month, 'YYYY_MM'), month, month + interval '1 month');
+    END LOOP;
+END $$;
+
+INSERT INTO healthcheck_events (id, healthcheck_id, status, created_at, state, status_code,
+    total_ms, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms)
+SELECT id, healthcheck_id, status, created_at, state, status_code,
+    total_ms, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms
+FROM healthcheck_events_old;
+
+SELECT setval(pg_get_serial_sequence('healthcheck_events', 'id'), COALESCE(MAX(id), 0) + 1, false)
+FROM healthcheck_events;
+
+DROP TABLE healthcheck_events_old;
@@ -0,0 +1 @@
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS trace_id;
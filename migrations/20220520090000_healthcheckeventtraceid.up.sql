@@ -0,0 +1 @@
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS trace_id VARCHAR (32) NOT NULL DEFAULT '';
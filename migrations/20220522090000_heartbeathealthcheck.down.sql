@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS healthchecks_ping_token_idx;
+
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS ping_started_at;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS last_ping_at;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS grace_seconds;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS ping_token;
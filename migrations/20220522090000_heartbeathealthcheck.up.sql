@@ -0,0 +1,6 @@
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS ping_token VARCHAR (64) NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS grace_seconds INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS last_ping_at timestamp;
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS ping_started_at timestamp;
+
+CREATE UNIQUE INDEX IF NOT EXISTS healthchecks_ping_token_idx ON healthchecks (ping_token) WHERE ping_token <> '';
@@ -0,0 +1,3 @@
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS active_hours;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS timezone;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS cron_expression;
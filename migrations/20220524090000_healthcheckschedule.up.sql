@@ -0,0 +1,3 @@
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS cron_expression TEXT NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS timezone VARCHAR (64) NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS active_hours jsonb NOT NULL DEFAULT '[]';
@@ -0,0 +1 @@
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS version;
@@ -0,0 +1 @@
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS healthchecks_labels_idx;
+DROP INDEX IF EXISTS healthchecks_name_idx;
+
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS labels;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS owner;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS description;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS name;
@@ -0,0 +1,7 @@
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS name VARCHAR (255) NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS owner VARCHAR (255) NOT NULL DEFAULT '';
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS labels jsonb NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS healthchecks_name_idx ON healthchecks (name);
+CREATE INDEX IF NOT EXISTS healthchecks_labels_idx ON healthchecks USING GIN (labels);
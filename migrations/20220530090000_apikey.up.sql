@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_keys(
+    id bigserial PRIMARY KEY,
+    name VARCHAR (255) NOT NULL,
+    prefix VARCHAR (16) NOT NULL,
+    hash CHAR (64) NOT NULL UNIQUE,
+    role VARCHAR (16) NOT NULL,
+    expires_at timestamp,
+    last_used_at timestamp,
+    created_at timestamp NOT NULL DEFAULT now()
+);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS healthchecks_project_id_idx;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS project_id;
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS project_id;
+
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS organizations;
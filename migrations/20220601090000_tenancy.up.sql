@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS organizations(
+    id bigserial PRIMARY KEY,
+    name VARCHAR (255) NOT NULL,
+    max_healthchecks INTEGER NOT NULL DEFAULT 0, /* 0 is unlimited */
+    min_interval_seconds INTEGER NOT NULL DEFAULT 0,
+    created_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS projects(
+    id bigserial PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name VARCHAR (255) NOT NULL,
+    webhook_url TEXT NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL DEFAULT now()
+);
+
+/* the existing healthchecks are moved to the default project, the existing API keys stay unscoped */
+INSERT INTO organizations (id, name) VALUES (1, 'default') ON CONFLICT DO NOTHING;
+INSERT INTO projects (id, organization_id, name) VALUES (1, 1, 'default') ON CONFLICT DO NOTHING;
+SELECT setval('organizations_id_seq', (SELECT MAX(id) FROM organizations));
+SELECT setval('projects_id_seq', (SELECT MAX(id) FROM projects));
+
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS project_id INTEGER NOT NULL DEFAULT 1
+    REFERENCES projects (id) ON DELETE CASCADE;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS project_id INTEGER REFERENCES projects (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS healthchecks_project_id_idx ON healthchecks (project_id);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS audit_log(
+    id bigserial PRIMARY KEY,
+    actor VARCHAR (255) NOT NULL,
+    project_id INTEGER, /* NULL for the changes which aren't in a project, e.g. of organizations */
+    action VARCHAR (64) NOT NULL,
+    resource_type VARCHAR (64) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    source_ip VARCHAR (64) NOT NULL,
+    before jsonb,
+    after jsonb,
+    changes jsonb,
+    created_at timestamp NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_resource_idx ON audit_log (resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor);
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+
+/* the log is append-only, the entries can't be changed or deleted */
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
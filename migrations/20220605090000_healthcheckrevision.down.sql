@@ -0,0 +1,3 @@
+ALTER TABLE healthcheck_events DROP COLUMN IF EXISTS revision;
+
+DROP TABLE IF EXISTS healthcheck_revisions;
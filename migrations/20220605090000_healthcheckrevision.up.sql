@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS healthcheck_revisions(
+    healthcheck_id BIGINT NOT NULL REFERENCES healthchecks (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL, /* the version of the healthcheck the definition was saved with */
+    definition jsonb NOT NULL,
+    created_at timestamp NOT NULL DEFAULT now(),
+    PRIMARY KEY (healthcheck_id, revision)
+);
+
+/* the current definitions are the first known revisions of the existing healthchecks */
+INSERT INTO healthcheck_revisions (healthcheck_id, revision, definition)
+SELECT id, version, jsonb_build_object(
+    'name', name,
+    'description', description,
+    'owner', owner,
+    'labels', labels,
+    'kind', kind,
+    'intervalSeconds', interval_seconds,
+    'url', url,
+    'httpMethod', http_method,
+    'headers', COALESCE(NULLIF(headers_json, '')::jsonb, '{}'),
+    'body', COALESCE(body, ''),
+    'compositeRule', composite_rule,
+    'compositeMinUp', composite_min_up,
+    'compositeThreshold', composite_threshold,
+    'members', (SELECT COALESCE(jsonb_agg(jsonb_build_object('id', member_id, 'weight', weight)), '[]')
+        FROM composite_members WHERE composite_id = healthchecks.id),
+    'graceSeconds', grace_seconds,
+    'cronExpression', cron_expression,
+    'timezone', timezone,
+    'activeHours', active_hours)
+FROM healthchecks
+ON CONFLICT DO NOTHING;
+
+/* NULL for the events recorded before the revisions were kept */
+ALTER TABLE healthcheck_events ADD COLUMN IF NOT EXISTS revision INTEGER;
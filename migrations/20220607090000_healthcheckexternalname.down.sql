@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS healthchecks_external_name_idx;
+
+ALTER TABLE healthchecks DROP COLUMN IF EXISTS external_name;
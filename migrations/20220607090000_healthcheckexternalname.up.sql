@@ -0,0 +1,5 @@
+/* the stable name a healthcheck is declared with in a manifest, empty for the healthchecks created otherwise */
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS external_name VARCHAR (255) NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS healthchecks_external_name_idx ON healthchecks (project_id, external_name)
+    WHERE external_name <> '';
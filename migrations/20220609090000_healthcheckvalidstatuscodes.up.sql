@@ -0,0 +1,2 @@
+/* empty means any 2xx or 3xx status code is valid */
+ALTER TABLE healthchecks ADD COLUMN IF NOT EXISTS valid_status_codes jsonb NOT NULL DEFAULT '[]';
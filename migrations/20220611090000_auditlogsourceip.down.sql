@@ -0,0 +1 @@
+ALTER TABLE audit_log ALTER COLUMN source_ip TYPE VARCHAR (64) USING left(source_ip, 64);
@@ -0,0 +1,2 @@
+/* the client ips taken from forwarded headers may be longer than 64 characters */
+ALTER TABLE audit_log ALTER COLUMN source_ip TYPE TEXT;
@@ -0,0 +1,34 @@
+// Package migrations embeds the database migrations, they are applied using golang-migrate.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// LatestVersion returns the version of the last migration, the version the database is expected to be at.
+func LatestVersion() (uint, error) {
+	names, err := fs.Glob(files, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint64
+	for _, name := range names {
+		version, err := strconv.ParseUint(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid migration name %s: %w", name, err)
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return uint(latest), nil
+}
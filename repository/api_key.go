@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// APIKey is a static key of an API client, only the sha256 hash of the key is stored. The key can only
+// access the healthchecks of its project if ProjectID is set.
+type APIKey struct {
+	ID        int    `json:"id"`
+	ProjectID *int   `json:"projectId,omitempty"`
+	Name      string `json:"name"`
+	// Prefix is the start of the key, it identifies the key without revealing it.
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Role       string     `json:"role"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type APIKeyRepo interface {
+	// InProject returns the repo limited to the keys of the project, it isn't limited if projectID is zero.
+	// FindByHash isn't limited.
+	InProject(projectID int) APIKeyRepo
+	Save(ctx context.Context, key *APIKey) error
+	FindAll(ctx context.Context) ([]APIKey, error)
+	FindByHash(ctx context.Context, hash string) (APIKey, error)
+	// Delete deletes the key and returns it.
+	Delete(ctx context.Context, id int) (APIKey, error)
+	UpdateLastUsedAt(ctx context.Context, id int, lastUsedAt time.Time) error
+}
+
+var _ APIKeyRepo = SQLAPIKeyRepo{}
+
+type SQLAPIKeyRepo struct {
+	DB *gorm.DB
+	// ProjectID limits the repo to the keys of the project if it isn't zero.
+	ProjectID int
+}
+
+func (c SQLAPIKeyRepo) InProject(projectID int) APIKeyRepo {
+	c.ProjectID = projectID
+	return c
+}
+
+// db returns the database limited to the project of the repo.
+func (c SQLAPIKeyRepo) db(ctx context.Context) *gorm.DB {
+	if c.ProjectID == 0 {
+		return conn(ctx, c.DB)
+	}
+	return conn(ctx, c.DB).Where("project_id = ?", c.ProjectID)
+}
+
+func (c SQLAPIKeyRepo) Save(ctx context.Context, key *APIKey) error {
+	if c.ProjectID != 0 {
+		projectID := c.ProjectID
+		key.ProjectID = &projectID
+	}
+	return conn(ctx, c.DB).Save(key).Error
+}
+
+func (c SQLAPIKeyRepo) FindAll(ctx context.Context) ([]APIKey, error) {
+	result := []APIKey{}
+	err := c.db(ctx).Order("id").Find(&result).Error
+
+	return result, err
+}
+
+func (c SQLAPIKeyRepo) FindByHash(ctx context.Context, hash string) (APIKey, error) {
+	key := APIKey{}
+	query := conn(ctx, c.DB).Where("hash = ?", hash).Find(&key)
+
+	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
+		return key, ErrRecordNotFound
+	}
+	if query.Error != nil {
+		return key, query.Error
+	}
+
+	return key, nil
+}
+
+func (c SQLAPIKeyRepo) Delete(ctx context.Context, id int) (APIKey, error) {
+	key := APIKey{}
+	query := c.db(ctx).Clauses(clause.Returning{}).Where("id = ?", id).Delete(&key)
+
+	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
+		return key, ErrRecordNotFound
+	}
+	if query.Error != nil {
+		return key, query.Error
+	}
+
+	return key, nil
+}
+
+func (c SQLAPIKeyRepo) UpdateLastUsedAt(ctx context.Context, id int, lastUsedAt time.Time) error {
+	return conn(ctx, c.DB).Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", lastUsedAt).Error
+}
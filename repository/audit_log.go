@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditEntry is a change made through the API, Before and After are the states of the changed resource
+// and Changes are the fields which differ between them. Before is nil for created resources, After for
+// deleted ones.
+type AuditEntry struct {
+	ID           int       `json:"id"`
+	Actor        string    `json:"actor"`
+	ProjectID    *int      `json:"projectId,omitempty"`
+	Action       string    `json:"action"`
+	ResourceType string    `json:"resourceType"`
+	ResourceID   int       `json:"resourceId"`
+	SourceIP     string    `json:"sourceIp"`
+	Before       AuditData `json:"before,omitempty"`
+	After        AuditData `json:"after,omitempty"`
+	Changes      AuditData `json:"changes,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+// AuditData is a json document stored as a jsonb column, nil is stored as NULL.
+type AuditData []byte
+
+func (d AuditData) Value() (driver.Value, error) {
+	if d == nil {
+		return nil, nil
+	}
+	return string(d), nil
+}
+
+func (d *AuditData) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case []byte:
+		*d = append(AuditData{}, value...)
+		return nil
+	case string:
+		*d = AuditData(value)
+		return nil
+	default:
+		return fmt.Errorf("can't scan %T into audit data", value)
+	}
+}
+
+func (d AuditData) MarshalJSON() ([]byte, error) {
+	if d == nil {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+// AuditFilter filters the audit log, zero fields are ignored.
+// Cursor is the ID of the last entry of the previous page.
+type AuditFilter struct {
+	Actor        string
+	ProjectID    int
+	Action       string
+	ResourceType string
+	ResourceID   int
+	From         time.Time
+	To           time.Time
+	Cursor       int
+	Limit        int
+	Ascending    bool
+}
+
+// AuditLogRepo appends to the audit log, the entries can't be changed or deleted.
+type AuditLogRepo interface {
+	Create(ctx context.Context, entry *AuditEntry) error
+	Find(ctx context.Context, filter AuditFilter) ([]AuditEntry, error)
+}
+
+var _ AuditLogRepo = SQLAuditLogRepo{}
+
+type SQLAuditLogRepo struct {
+	DB *gorm.DB
+}
+
+func (c SQLAuditLogRepo) Create(ctx context.Context, entry *AuditEntry) error {
+	return conn(ctx, c.DB).Create(entry).Error
+}
+
+func (c SQLAuditLogRepo) Find(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	query := conn(ctx, c.DB)
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.ProjectID != 0 {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != 0 {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.Ascending {
+		if filter.Cursor != 0 {
+			query = query.Where("id > ?", filter.Cursor)
+		}
+		query = query.Order("id")
+	} else {
+		if filter.Cursor != 0 {
+			query = query.Where("id < ?", filter.Cursor)
+		}
+		query = query.Order("id DESC")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := []AuditEntry{}
+	err := query.Find(&result).Error
+
+	return result, err
+}
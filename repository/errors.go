@@ -4,3 +4,9 @@ import "errors"
 
 // ErrRecordNotFound indicates the record was not found in repo.
 var ErrRecordNotFound = errors.New("record not found")
+
+// ErrVersionConflict indicates the record was changed since the version being updated was read.
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrDuplicateExternalName indicates another healthcheck of the project has the external name.
+var ErrDuplicateExternalName = errors.New("duplicate external name")
@@ -1,35 +1,296 @@
 package repository
 
 import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Healthcheck kinds.
+const (
+	KindHTTP = "http"
+	// KindComposite healthchecks compute their state from the state of their members.
+	KindComposite = "composite"
+	// KindHeartbeat healthchecks are passive, they are down if no ping arrives within the interval and grace.
+	KindHeartbeat = "heartbeat"
+)
+
+// Composite rules.
+const (
+	CompositeRuleAll      = "all"
+	CompositeRuleAny      = "any"
+	CompositeRuleAtLeast  = "atLeast"
+	CompositeRuleWeighted = "weighted"
 )
 
 type Healthcheck struct {
-	ID              int    `json:"id"`
-	IntervalSeconds int    `json:"intervalSeconds"`
-	Url             string `json:"url"`
-	HttpMethod      string `json:"httpMethod"`
-	HeadersJson     string `json:"headers"`
-	Body            string `json:"body"`
+	ID        int `json:"id"`
+	ProjectID int `json:"projectId"`
+	// ExternalName identifies the healthcheck in the manifests, it is unique in the project if not empty.
+	ExternalName    string  `json:"externalName,omitempty"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	Owner           string  `json:"owner"`
+	Labels          Labels  `json:"labels"`
+	Kind            string  `json:"kind"`
+	IntervalSeconds int     `json:"intervalSeconds"`
+	Url             string  `json:"url"`
+	HttpMethod      string  `json:"httpMethod"`
+	Headers         Headers `json:"headers" gorm:"column:headers_json"`
+	Body            string  `json:"body"`
+	// ValidStatusCodes are the status codes a http healthcheck is up with, any 2xx or 3xx one if empty.
+	ValidStatusCodes   StatusCodes       `json:"validStatusCodes,omitempty"`
+	CompositeRule      string            `json:"compositeRule,omitempty"`
+	CompositeMinUp     int               `json:"compositeMinUp,omitempty"`
+	CompositeThreshold float64           `json:"compositeThreshold,omitempty"`
+	Members            []CompositeMember `json:"members,omitempty" gorm:"foreignKey:CompositeID"`
+	PingToken          string            `json:"pingToken,omitempty"`
+	GraceSeconds       int               `json:"graceSeconds,omitempty"`
+	LastPingAt         *time.Time        `json:"lastPingAt,omitempty"`
+	// PingStartedAt is the time of the start ping of the running job, nil if no job is running.
+	PingStartedAt *time.Time `json:"pingStartedAt,omitempty"`
+	// CronExpression replaces IntervalSeconds when set, the optional first field is the seconds.
+	CronExpression string `json:"cronExpression,omitempty"`
+	// Timezone is the IANA timezone of the cron expression and the active hours, the local one if empty.
+	Timezone string `json:"timezone,omitempty"`
+	// ActiveHours limits the runs to weekly windows, the healthcheck runs all the time if empty.
+	ActiveHours ActiveHoursList `json:"activeHours,omitempty"`
+	// Version is incremented by every update, it is used for optimistic concurrency.
+	Version int `json:"version" gorm:"default:1"`
+	// NextRunAt is the time of the next run of a started healthcheck, it is computed by the scheduler.
+	NextRunAt *time.Time `json:"nextRunAt,omitempty" gorm:"-"`
+	// Running is true if the healthcheck is started, it is set by the handlers.
+	Running bool `json:"running" gorm:"-"`
+}
+
+// Headers are the request headers of a http healthcheck, they are stored as a json object.
+type Headers map[string]string
+
+func (h Headers) Value() (driver.Value, error) {
+	if h == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(h)
+	return string(value), err
+}
+
+func (h *Headers) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, h)
+	case string:
+		return json.Unmarshal([]byte(value), h)
+	default:
+		return fmt.Errorf("can't scan %T into headers", value)
+	}
+}
+
+// Labels are free-form key/value pairs used to select healthchecks, they are stored as a jsonb object.
+type Labels map[string]string
+
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	value, err := json.Marshal(l)
+	return string(value), err
+}
+
+func (l *Labels) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, l)
+	case string:
+		return json.Unmarshal([]byte(value), l)
+	default:
+		return fmt.Errorf("can't scan %T into labels", value)
+	}
+}
+
+// StatusCodes are http status codes, they are stored as a jsonb array.
+type StatusCodes []int
+
+func (s StatusCodes) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	value, err := json.Marshal(s)
+	return string(value), err
+}
+
+func (s *StatusCodes) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, s)
+	case string:
+		return json.Unmarshal([]byte(value), s)
+	default:
+		return fmt.Errorf("can't scan %T into status codes", value)
+	}
+}
+
+// ActiveHours is a weekly window, e.g. Monday to Friday from 09:00 to 17:00. The window spans midnight if
+// To isn't after From.
+type ActiveHours struct {
+	// Days are the weekdays the window starts on, e.g. "mon", every day if empty.
+	Days []string `json:"days,omitempty"`
+	// From and To are times of the day in the HH:MM format, To is exclusive.
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ActiveHoursList is stored as a jsonb column.
+type ActiveHoursList []ActiveHours
+
+func (a ActiveHoursList) Value() (driver.Value, error) {
+	if a == nil {
+		return "[]", nil
+	}
+	value, err := json.Marshal(a)
+	return string(value), err
+}
+
+func (a *ActiveHoursList) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, a)
+	case string:
+		return json.Unmarshal([]byte(value), a)
+	default:
+		return fmt.Errorf("can't scan %T into active hours", value)
+	}
+}
+
+// CompositeMember is a healthcheck whose state is used to compute the state of a composite healthcheck.
+type CompositeMember struct {
+	CompositeID int     `json:"-"`
+	MemberID    int     `json:"id"`
+	Weight      float64 `json:"weight"`
+}
+
+// Label selector operators.
+const (
+	LabelEquals       = "="
+	LabelNotEquals    = "!="
+	LabelIn           = "in"
+	LabelNotIn        = "notin"
+	LabelExists       = "exists"
+	LabelDoesNotExist = "!exists"
+)
+
+// LabelRequirement is a condition on a label, Values is empty for the exists operators.
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	Values   []string
+}
+
+// externalNameIndex is the unique index of the external names of the healthchecks of a project.
+const externalNameIndex = "healthchecks_external_name_idx"
+
+// uniqueViolation is the postgres error code of unique constraint violations.
+const uniqueViolation = "23505"
+
+// Healthcheck sort fields.
+const (
+	SortByID    = "id"
+	SortByName  = "name"
+	SortByOwner = "owner"
+	SortByUrl   = "url"
+	SortByKind  = "kind"
+)
+
+// HealthcheckFilter filters the healthchecks, zero fields are ignored.
+// Running filters by RunningIDs, the ids of the started healthchecks, if set.
+type HealthcheckFilter struct {
+	IDs        []int
+	Labels     []LabelRequirement
+	Url        string
+	Owner      string
+	States     []string
+	Running    *bool
+	RunningIDs []int
+	Sort       string
+	Descending bool
+	Offset     int
+	Limit      int
 }
 
 type HealthcheckRepo interface {
-	Delete(id int) error
-	Save(healthcheck *Healthcheck) error
-	FindOne(id int) (Healthcheck, error)
-	FindAll() ([]Healthcheck, error)
+	// InProject returns the repo limited to the healthchecks of the project, it isn't limited if projectID
+	// is zero. The healthchecks saved by a limited repo are created in its project.
+	InProject(projectID int) HealthcheckRepo
+	// Delete deletes the healthcheck if its version is still the given one, it returns ErrVersionConflict
+	// if the healthcheck was modified or deleted.
+	Delete(ctx context.Context, id, version int) error
+	// Save saves the healthcheck and keeps its definition as the revision of its version. It returns
+	// ErrDuplicateExternalName if another healthcheck of the project has its external name, as do the updates.
+	Save(ctx context.Context, healthcheck *Healthcheck) error
+	// Update replaces the healthcheck and its members if its version is still the stored one, the version
+	// is incremented and the new definition is kept as its revision. The ping times are kept.
+	Update(ctx context.Context, healthcheck *Healthcheck) error
+	// UpdateAll updates the healthchecks like Update in a single transaction, none of them is updated if
+	// one of the versions isn't the stored one.
+	UpdateAll(ctx context.Context, healthchecks []*Healthcheck) error
+	// DeleteAll deletes the healthchecks in a single statement and returns the ids of the deleted ones.
+	DeleteAll(ctx context.Context, ids []int) ([]int, error)
+	FindOne(ctx context.Context, id int) (Healthcheck, error)
+	FindAll(ctx context.Context) ([]Healthcheck, error)
+	// Find returns a page of the healthchecks matching the filter and the total number of matching ones.
+	Find(ctx context.Context, filter HealthcheckFilter) ([]Healthcheck, int64, error)
+	FindByPingToken(ctx context.Context, token string) (Healthcheck, error)
+	UpdatePingTimes(ctx context.Context, id int, lastPingAt, pingStartedAt *time.Time) error
 }
 
 var _ HealthcheckRepo = SQLHealthcheckRepo{}
 
 type SQLHealthcheckRepo struct {
 	DB *gorm.DB
+	// ProjectID limits the repo to the healthchecks of the project if it isn't zero.
+	ProjectID int
+}
+
+func (c SQLHealthcheckRepo) InProject(projectID int) HealthcheckRepo {
+	c.ProjectID = projectID
+	return c
 }
 
-func (c SQLHealthcheckRepo) FindOne(id int) (Healthcheck, error) {
+// db returns the database limited to the project of the repo.
+func (c SQLHealthcheckRepo) db(ctx context.Context) *gorm.DB {
+	return inProject(conn(ctx, c.DB), c.ProjectID)
+}
+
+// inProject limits the healthchecks of the query to the project if projectID isn't zero.
+func inProject(query *gorm.DB, projectID int) *gorm.DB {
+	if projectID == 0 {
+		return query
+	}
+	return query.Where("healthchecks.project_id = ?", projectID)
+}
+
+func (c SQLHealthcheckRepo) FindOne(ctx context.Context, id int) (Healthcheck, error) {
 	healthcheck := Healthcheck{}
-	query := c.DB.Where("id = ?", id).Find(&healthcheck)
+	query := c.db(ctx).Preload("Members").Where("id = ?", id).Find(&healthcheck)
 
 	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
 		return healthcheck, ErrRecordNotFound
@@ -41,26 +302,231 @@ func (c SQLHealthcheckRepo) FindOne(id int) (Healthcheck, error) {
 	return healthcheck, nil
 }
 
-func (c SQLHealthcheckRepo) Delete(id int) error {
-	query := c.DB.Where("id = ?", id).Delete(&Healthcheck{})
-
-	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
-		return ErrRecordNotFound
-	}
+func (c SQLHealthcheckRepo) Delete(ctx context.Context, id, version int) error {
+	query := c.db(ctx).Where("id = ? AND version = ?", id, version).Delete(&Healthcheck{})
 	if query.Error != nil {
 		return query.Error
 	}
+	if query.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
 
 	return nil
 }
 
-func (c SQLHealthcheckRepo) Save(healthcheck *Healthcheck) error {
-	return c.DB.Save(healthcheck).Error
+func (c SQLHealthcheckRepo) Save(ctx context.Context, healthcheck *Healthcheck) error {
+	if c.ProjectID != 0 {
+		healthcheck.ProjectID = c.ProjectID
+	}
+	return conn(ctx, c.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(healthcheck).Error; err != nil {
+			return externalNameError(err, *healthcheck)
+		}
+		return saveRevision(tx, *healthcheck)
+	})
+}
+
+func (c SQLHealthcheckRepo) Update(ctx context.Context, healthcheck *Healthcheck) error {
+	return conn(ctx, c.DB).Transaction(func(tx *gorm.DB) error {
+		return update(tx, healthcheck, c.ProjectID)
+	})
+}
+
+func (c SQLHealthcheckRepo) UpdateAll(ctx context.Context, healthchecks []*Healthcheck) error {
+	versions := make([]int, len(healthchecks))
+	for i, healthcheck := range healthchecks {
+		versions[i] = healthcheck.Version
+	}
+
+	err := conn(ctx, c.DB).Transaction(func(tx *gorm.DB) error {
+		for _, healthcheck := range healthchecks {
+			if err := update(tx, healthcheck, c.ProjectID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// the transaction is rolled back, so are the versions.
+		for i, healthcheck := range healthchecks {
+			healthcheck.Version = versions[i]
+		}
+	}
+	return err
+}
+
+// update updates the healthcheck and its members in the transaction, a healthcheck outside of the project
+// is a version conflict unless projectID is zero.
+func update(tx *gorm.DB, healthcheck *Healthcheck, projectID int) error {
+	version := healthcheck.Version
+	healthcheck.Version++
+	query := inProject(tx.Model(healthcheck), projectID).
+		Where("version = ?", version).
+		Select("*").
+		Omit("id", "Members", "LastPingAt", "PingStartedAt").
+		Updates(healthcheck)
+	if query.Error != nil {
+		healthcheck.Version = version
+		return externalNameError(query.Error, *healthcheck)
+	}
+	if query.RowsAffected == 0 {
+		healthcheck.Version = version
+		return ErrVersionConflict
+	}
+
+	if err := tx.Where("composite_id = ?", healthcheck.ID).Delete(&CompositeMember{}).Error; err != nil {
+		return err
+	}
+	if len(healthcheck.Members) > 0 {
+		for i := range healthcheck.Members {
+			healthcheck.Members[i].CompositeID = healthcheck.ID
+		}
+		if err := tx.Create(&healthcheck.Members).Error; err != nil {
+			return err
+		}
+	}
+	return saveRevision(tx, *healthcheck)
+}
+
+// externalNameError returns ErrDuplicateExternalName if the error violates the uniqueness of the external
+// names, otherwise the error.
+func externalNameError(err error, healthcheck Healthcheck) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == externalNameIndex {
+		return fmt.Errorf("%w: %q", ErrDuplicateExternalName, healthcheck.ExternalName)
+	}
+	return err
 }
 
-func (c SQLHealthcheckRepo) FindAll() ([]Healthcheck, error) {
+func (c SQLHealthcheckRepo) DeleteAll(ctx context.Context, ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return []int{}, nil
+	}
+
+	var deleted []Healthcheck
+	err := c.db(ctx).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("id IN ?", ids).
+		Delete(&deleted).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]int, 0, len(deleted))
+	for _, healthcheck := range deleted {
+		result = append(result, healthcheck.ID)
+	}
+	return result, nil
+}
+
+func (c SQLHealthcheckRepo) FindAll(ctx context.Context) ([]Healthcheck, error) {
 	var result []Healthcheck
-	err := c.DB.Find(&result).Error
+	err := c.db(ctx).Preload("Members").Find(&result).Error
 
 	return result, err
 }
+
+func (c SQLHealthcheckRepo) Find(ctx context.Context, filter HealthcheckFilter) ([]Healthcheck, int64, error) {
+	query := c.db(ctx).Model(&Healthcheck{})
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	for _, requirement := range filter.Labels {
+		query = whereLabel(query, requirement)
+	}
+	if filter.Url != "" {
+		query = query.Where("url ILIKE ?", "%"+escapeLike(filter.Url)+"%")
+	}
+	if filter.Owner != "" {
+		query = query.Where("owner = ?", filter.Owner)
+	}
+	if len(filter.States) > 0 {
+		query = query.Where(`(SELECT state FROM healthcheck_events
+			WHERE healthcheck_events.healthcheck_id = healthchecks.id
+			ORDER BY created_at DESC, id DESC LIMIT 1) IN ?`, filter.States)
+	}
+	if filter.Running != nil {
+		// there's no healthcheck 0, it keeps the list non-empty as gorm renders empty lists as (NULL).
+		if *filter.Running {
+			query = query.Where("id IN ?", append([]int{0}, filter.RunningIDs...))
+		} else {
+			query = query.Where("id NOT IN ?", append([]int{0}, filter.RunningIDs...))
+		}
+	}
+
+	// the count and the page share the conditions.
+	query = query.Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	sort := filter.Sort
+	if sort == "" {
+		sort = SortByID
+	}
+	order := clause.OrderByColumn{Column: clause.Column{Name: sort}, Desc: filter.Descending}
+	query = query.Order(order)
+	if sort != SortByID {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: SortByID}, Desc: filter.Descending})
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := []Healthcheck{}
+	err := query.Preload("Members").Find(&result).Error
+
+	return result, total, err
+}
+
+// whereLabel adds the condition of a label requirement, the missing labels don't match = and in but match
+// != and notin.
+func whereLabel(query *gorm.DB, requirement LabelRequirement) *gorm.DB {
+	switch requirement.Operator {
+	case LabelEquals:
+		// @> uses the gin index of the labels.
+		label, _ := json.Marshal(map[string]string{requirement.Key: requirement.Values[0]})
+		return query.Where("labels @> ?", string(label))
+	case LabelNotEquals:
+		return query.Where("(labels ->> ? IS NULL OR labels ->> ? <> ?)", requirement.Key, requirement.Key,
+			requirement.Values[0])
+	case LabelIn:
+		return query.Where("labels ->> ? IN ?", requirement.Key, requirement.Values)
+	case LabelNotIn:
+		return query.Where("(labels ->> ? IS NULL OR labels ->> ? NOT IN ?)", requirement.Key, requirement.Key,
+			requirement.Values)
+	case LabelExists:
+		return query.Where("labels ->> ? IS NOT NULL", requirement.Key)
+	default:
+		return query.Where("labels ->> ? IS NULL", requirement.Key)
+	}
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func (c SQLHealthcheckRepo) FindByPingToken(ctx context.Context, token string) (Healthcheck, error) {
+	healthcheck := Healthcheck{}
+	query := conn(ctx, c.DB).Where("ping_token = ? AND kind = ?", token, KindHeartbeat).Find(&healthcheck)
+
+	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
+		return healthcheck, ErrRecordNotFound
+	}
+	if query.Error != nil {
+		return healthcheck, query.Error
+	}
+
+	return healthcheck, nil
+}
+
+func (c SQLHealthcheckRepo) UpdatePingTimes(ctx context.Context, id int, lastPingAt, pingStartedAt *time.Time) error {
+	return conn(ctx, c.DB).Model(&Healthcheck{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_ping_at":    lastPingAt,
+		"ping_started_at": pingStartedAt,
+	}).Error
+}
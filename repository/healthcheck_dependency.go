@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// HealthcheckDependency is an edge of the dependency graph, HealthcheckID depends on DependsOnID.
+type HealthcheckDependency struct {
+	HealthcheckID int `json:"healthcheckId"`
+	DependsOnID   int `json:"dependsOnId"`
+}
+
+type HealthcheckDependencyRepo interface {
+	Save(ctx context.Context, healthcheckID int, dependsOn []int) error
+	FindParents(ctx context.Context, healthcheckID int) ([]int, error)
+	FindChildren(ctx context.Context, healthcheckID int) ([]int, error)
+	FindAll(ctx context.Context) ([]HealthcheckDependency, error)
+}
+
+var _ HealthcheckDependencyRepo = SQLHealthcheckDependencyRepo{}
+
+type SQLHealthcheckDependencyRepo struct {
+	DB *gorm.DB
+}
+
+// Save replaces the dependencies of the given healthcheck.
+func (c SQLHealthcheckDependencyRepo) Save(ctx context.Context, healthcheckID int, dependsOn []int) error {
+	return conn(ctx, c.DB).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("healthcheck_id = ?", healthcheckID).Delete(&HealthcheckDependency{}).Error; err != nil {
+			return err
+		}
+		if len(dependsOn) == 0 {
+			return nil
+		}
+
+		dependencies := make([]HealthcheckDependency, 0, len(dependsOn))
+		for _, parentID := range dependsOn {
+			dependencies = append(dependencies, HealthcheckDependency{
+				HealthcheckID: healthcheckID,
+				DependsOnID:   parentID,
+			})
+		}
+
+		return tx.Create(&dependencies).Error
+	})
+}
+
+func (c SQLHealthcheckDependencyRepo) FindParents(ctx context.Context, healthcheckID int) ([]int, error) {
+	var result []int
+	err := conn(ctx, c.DB).Model(&HealthcheckDependency{}).
+		Where("healthcheck_id = ?", healthcheckID).
+		Order("depends_on_id").
+		Pluck("depends_on_id", &result).Error
+
+	return result, err
+}
+
+func (c SQLHealthcheckDependencyRepo) FindChildren(ctx context.Context, healthcheckID int) ([]int, error) {
+	var result []int
+	err := conn(ctx, c.DB).Model(&HealthcheckDependency{}).
+		Where("depends_on_id = ?", healthcheckID).
+		Order("healthcheck_id").
+		Pluck("healthcheck_id", &result).Error
+
+	return result, err
+}
+
+func (c SQLHealthcheckDependencyRepo) FindAll(ctx context.Context) ([]HealthcheckDependency, error) {
+	var result []HealthcheckDependency
+	err := conn(ctx, c.DB).Find(&result).Error
+
+	return result, err
+}
@@ -1,37 +1,132 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Healthcheck event states.
+const (
+	StateUp   = "up"
+	StateDown = "down"
+	// StateUnreachable indicates the healthcheck failed while one of its dependencies was down.
+	StateUnreachable = "unreachable"
+)
+
 type HealthcheckEvent struct {
-	ID            int       `json:"id"`
-	HealthcheckID int       `json:"-"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"createdAt"`
+	ID            int     `json:"id"`
+	HealthcheckID int     `json:"healthcheckId"`
+	Status        string  `json:"status"`
+	State         string  `json:"state"`
+	StatusCode    int     `json:"statusCode"`
+	Latency       Latency `json:"latency" gorm:"embedded"`
+	TraceID       string  `json:"traceId,omitempty"`
+	// Revision is the revision of the healthcheck definition the event was produced by, zero if unknown.
+	Revision  int       `json:"revision,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Latency is the duration of a healthcheck request and its phases in milliseconds.
+type Latency struct {
+	TotalMs    float64 `json:"totalMs" gorm:"column:total_ms"`
+	DNSMs      float64 `json:"dnsMs" gorm:"column:dns_ms"`
+	ConnectMs  float64 `json:"connectMs" gorm:"column:connect_ms"`
+	TLSMs      float64 `json:"tlsMs" gorm:"column:tls_ms"`
+	TTFBMs     float64 `json:"ttfbMs" gorm:"column:ttfb_ms"`
+	TransferMs float64 `json:"transferMs" gorm:"column:transfer_ms"`
+}
+
+// LatencyPercentiles are the percentiles of the total latency in milliseconds.
+type LatencyPercentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// TimeRange is the half open range [From, To).
+type TimeRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// UptimeBucket is the uptime of a healthcheck in a time bucket (e.g. an hour).
+type UptimeBucket struct {
+	Bucket      time.Time `json:"bucket"`
+	UpSeconds   float64   `json:"upSeconds"`
+	DownSeconds float64   `json:"downSeconds"`
+	Incidents   int       `json:"incidents"`
+}
+
+// EventFilter filters the events, zero fields are ignored.
+// Cursor is the ID of the last event of the previous page, ProjectID limits the events to the healthchecks
+// of the project.
+type EventFilter struct {
+	HealthcheckID int
+	ProjectID     int
+	From          time.Time
+	To            time.Time
+	States        []string
+	Cursor        int
+	Limit         int
+	Ascending     bool
 }
 
 type HealthcheckEventRepo interface {
-	Create(healthcheckEvent *HealthcheckEvent) error
-	FindLast() (HealthcheckEvent, error)
+	Create(ctx context.Context, healthcheckEvent *HealthcheckEvent) error
+	// CreateBatch inserts the events using multi-row inserts.
+	CreateBatch(ctx context.Context, healthcheckEvents []HealthcheckEvent) error
+	FindLast(ctx context.Context, healthcheckID int) (HealthcheckEvent, error)
+	Find(ctx context.Context, filter EventFilter) ([]HealthcheckEvent, error)
+	// FindStateSince returns the time of the first event of the current run of the given state.
+	FindStateSince(ctx context.Context, healthcheckID int, state string) (time.Time, error)
+	// FindOldest returns the time of the oldest event of all healthchecks.
+	FindOldest(ctx context.Context) (time.Time, error)
+	// DeleteBetween deletes at most limit events in [from, to) and returns the number of deleted events.
+	DeleteBetween(ctx context.Context, from, to time.Time, limit int) (int64, error)
+	// Uptime returns the uptime of the healthcheck in [from, to) grouped by granularity (hour or day),
+	// the excluded ranges must not overlap. The range ends now if to is in the future.
+	Uptime(ctx context.Context, healthcheckID int, from, to time.Time, granularity string,
+		excluded []TimeRange) ([]UptimeBucket, error)
+	// LatencyPercentiles returns the latency percentiles of the events in [from, to) which got a response.
+	LatencyPercentiles(ctx context.Context, healthcheckID int, from, to time.Time) (LatencyPercentiles, error)
+	// Discard drops the events of the healthcheck which are created but not written yet.
+	Discard(healthcheckID int)
 }
 
+// createBatchSize is the number of rows of each insert statement of CreateBatch.
+const createBatchSize = 500
+
 var _ HealthcheckEventRepo = SQLHealthcheckEventRepo{}
 
 type SQLHealthcheckEventRepo struct {
 	DB *gorm.DB
 }
 
-func (c SQLHealthcheckEventRepo) Create(event *HealthcheckEvent) error {
-	return c.DB.Save(event).Error
+func (c SQLHealthcheckEventRepo) Create(ctx context.Context, event *HealthcheckEvent) error {
+	return conn(ctx, c.DB).Save(event).Error
 }
 
-func (c SQLHealthcheckEventRepo) FindLast() (HealthcheckEvent, error) {
+func (c SQLHealthcheckEventRepo) CreateBatch(ctx context.Context, events []HealthcheckEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return conn(ctx, c.DB).CreateInBatches(events, createBatchSize).Error
+}
+
+// Discard does nothing, the events are written when they are created.
+func (c SQLHealthcheckEventRepo) Discard(int) {}
+
+func (c SQLHealthcheckEventRepo) FindLast(ctx context.Context, healthcheckID int) (HealthcheckEvent, error) {
 	event := HealthcheckEvent{}
-	query := c.DB.Last(&event)
+	query := conn(ctx, c.DB).Where("healthcheck_id = ?", healthcheckID).Last(&event)
 	if query.Error != nil {
 		if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
 			return event, ErrRecordNotFound
@@ -41,3 +136,167 @@ func (c SQLHealthcheckEventRepo) FindLast() (HealthcheckEvent, error) {
 
 	return event, nil
 }
+
+func (c SQLHealthcheckEventRepo) Find(ctx context.Context, filter EventFilter) ([]HealthcheckEvent, error) {
+	query := conn(ctx, c.DB)
+	if filter.HealthcheckID != 0 {
+		query = query.Where("healthcheck_id = ?", filter.HealthcheckID)
+	}
+	if filter.ProjectID != 0 {
+		query = query.Where("healthcheck_id IN (SELECT id FROM healthchecks WHERE project_id = ?)", filter.ProjectID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if len(filter.States) > 0 {
+		query = query.Where("state IN ?", filter.States)
+	}
+	if filter.Ascending {
+		if filter.Cursor != 0 {
+			query = query.Where("id > ?", filter.Cursor)
+		}
+		query = query.Order("id")
+	} else {
+		if filter.Cursor != 0 {
+			query = query.Where("id < ?", filter.Cursor)
+		}
+		query = query.Order("id DESC")
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	result := []HealthcheckEvent{}
+	err := query.Find(&result).Error
+
+	return result, err
+}
+
+func (c SQLHealthcheckEventRepo) FindStateSince(ctx context.Context, healthcheckID int,
+	state string) (time.Time, error) {
+	var since sql.NullTime
+	err := conn(ctx, c.DB).Raw(`
+SELECT MIN(created_at) FROM healthcheck_events
+WHERE healthcheck_id = @id AND id > COALESCE(
+	(SELECT MAX(id) FROM healthcheck_events WHERE healthcheck_id = @id AND state <> @state), 0)`,
+		sql.Named("id", healthcheckID), sql.Named("state", state)).Row().Scan(&since)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !since.Valid {
+		return time.Time{}, ErrRecordNotFound
+	}
+
+	return since.Time, nil
+}
+
+func (c SQLHealthcheckEventRepo) FindOldest(ctx context.Context) (time.Time, error) {
+	var oldest sql.NullTime
+	if err := conn(ctx, c.DB).Raw("SELECT MIN(created_at) FROM healthcheck_events").Row().Scan(&oldest); err != nil {
+		return time.Time{}, err
+	}
+	if !oldest.Valid {
+		return time.Time{}, ErrRecordNotFound
+	}
+
+	return oldest.Time, nil
+}
+
+func (c SQLHealthcheckEventRepo) DeleteBetween(ctx context.Context, from, to time.Time, limit int) (int64, error) {
+	query := conn(ctx, c.DB).Exec(`
+DELETE FROM healthcheck_events WHERE created_at >= @from AND created_at < @to AND id IN (
+	SELECT id FROM healthcheck_events WHERE created_at >= @from AND created_at < @to LIMIT @limit)`,
+		sql.Named("from", from), sql.Named("to", to), sql.Named("limit", limit))
+
+	return query.RowsAffected, query.Error
+}
+
+// uptimeQuery splits the events into segments lasting until the next event. The state at from is taken
+// from the last event before it. A segment spanning several buckets is split at their boundaries, its
+// incident is counted in the bucket it starts in.
+const uptimeQuery = `
+WITH events AS (
+	(SELECT created_at, state FROM healthcheck_events
+	WHERE healthcheck_id = @id AND created_at < @from
+	ORDER BY created_at DESC LIMIT 1)
+	UNION ALL
+	SELECT created_at, state FROM healthcheck_events
+	WHERE healthcheck_id = @id AND created_at >= @from AND created_at < @to
+), segments AS (
+	SELECT GREATEST(created_at, @from::timestamp) AS start_at,
+		LEAD(created_at, 1, @to::timestamp) OVER (ORDER BY created_at) AS end_at,
+		state,
+		LAG(state) OVER (ORDER BY created_at) AS previous_state
+	FROM events
+), buckets AS (
+	SELECT bucket, bucket + ('1 ' || @granularity)::interval AS bucket_end
+	FROM generate_series(date_trunc(@granularity, @from::timestamp), @to::timestamp,
+		('1 ' || @granularity)::interval) AS bucket
+), pieces AS (
+	SELECT bucket, GREATEST(segments.start_at, bucket) AS start_at,
+		LEAST(segments.end_at, bucket_end) AS end_at,
+		state, previous_state, segments.start_at >= bucket AS starts_segment
+	FROM segments JOIN buckets ON segments.start_at < bucket_end AND segments.end_at > bucket
+), durations AS (
+	SELECT bucket, state, previous_state, starts_segment, %s AS seconds FROM pieces
+)
+SELECT bucket,
+	COALESCE(SUM(seconds) FILTER (WHERE state = 'up'), 0) AS up_seconds,
+	COALESCE(SUM(seconds) FILTER (WHERE state <> 'up'), 0) AS down_seconds,
+	COUNT(*) FILTER (WHERE state <> 'up' AND starts_segment AND seconds > 0
+		AND (previous_state IS NULL OR previous_state = 'up')) AS incidents
+FROM durations
+GROUP BY bucket
+ORDER BY bucket`
+
+func (c SQLHealthcheckEventRepo) Uptime(ctx context.Context, healthcheckID int, from, to time.Time,
+	granularity string, excluded []TimeRange) ([]UptimeBucket, error) {
+	// the future has no uptime yet.
+	if now := time.Now(); to.After(now) {
+		to = now
+	}
+	if !from.Before(to) {
+		return nil, nil
+	}
+
+	args := []interface{}{
+		sql.Named("id", healthcheckID),
+		sql.Named("from", from),
+		sql.Named("to", to),
+		sql.Named("granularity", granularity),
+	}
+
+	// the duration of a segment minus its overlap with every excluded range.
+	seconds := []string{"EXTRACT(EPOCH FROM end_at - start_at)"}
+	for i, r := range excluded {
+		seconds = append(seconds, fmt.Sprintf(
+			"GREATEST(0, EXTRACT(EPOCH FROM LEAST(end_at, @exclude_to_%d) - GREATEST(start_at, @exclude_from_%d)))", i, i))
+		args = append(args, sql.Named(fmt.Sprintf("exclude_from_%d", i), r.From),
+			sql.Named(fmt.Sprintf("exclude_to_%d", i), r.To))
+	}
+
+	var result []UptimeBucket
+	err := conn(ctx, c.DB).Raw(fmt.Sprintf(uptimeQuery, strings.Join(seconds, " - ")), args...).
+		Scan(&result).Error
+
+	return result, err
+}
+
+func (c SQLHealthcheckEventRepo) LatencyPercentiles(ctx context.Context, healthcheckID int, from,
+	to time.Time) (LatencyPercentiles, error) {
+	var result LatencyPercentiles
+	err := conn(ctx, c.DB).Raw(`
+SELECT COUNT(*) AS count,
+	COALESCE(percentile_cont(0.50) WITHIN GROUP (ORDER BY total_ms), 0) AS p50,
+	COALESCE(percentile_cont(0.90) WITHIN GROUP (ORDER BY total_ms), 0) AS p90,
+	COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY total_ms), 0) AS p95,
+	COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY total_ms), 0) AS p99
+FROM healthcheck_events
+WHERE healthcheck_id = ? AND created_at >= ? AND created_at < ? AND status_code > 0`,
+		healthcheckID, from, to).Scan(&result).Error
+
+	return result, err
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBufferedBatches limits how many failed batches are kept for retry before events are dropped.
+const maxBufferedBatches = 10
+
+// foreignKeyViolation is the postgres error code of foreign key constraint violations.
+const foreignKeyViolation = "23503"
+
+// BufferedHealthcheckEventRepo buffers the created events and writes them in batches, either when the
+// buffer is full or every flush interval. The buffered events are visible to FindLast and FindStateSince,
+// the other reads see them after they are flushed.
+type BufferedHealthcheckEventRepo struct {
+	HealthcheckEventRepo
+
+	size          int
+	flushInterval time.Duration
+
+	mu       sync.Mutex
+	buffer   []HealthcheckEvent
+	flushing []HealthcheckEvent
+	full     chan struct{}
+	flushMu  sync.Mutex
+}
+
+var _ HealthcheckEventRepo = &BufferedHealthcheckEventRepo{}
+
+func NewBufferedHealthcheckEventRepo(repo HealthcheckEventRepo, size int,
+	flushInterval time.Duration) *BufferedHealthcheckEventRepo {
+	return &BufferedHealthcheckEventRepo{
+		HealthcheckEventRepo: repo,
+		size:                 size,
+		flushInterval:        flushInterval,
+		full:                 make(chan struct{}, 1),
+	}
+}
+
+// Start flushes the buffer periodically until the context is done, then flushes the remaining events.
+func (c *BufferedHealthcheckEventRepo) Start(ctx context.Context) {
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := c.Flush(); err != nil {
+				logrus.Errorf("failed to flush healthcheck events, err: %s", err)
+			}
+			return
+		case <-ticker.C:
+		case <-c.full:
+		}
+
+		if err := c.Flush(); err != nil {
+			logrus.Errorf("failed to flush healthcheck events, err: %s", err)
+		}
+	}
+}
+
+func (c *BufferedHealthcheckEventRepo) Create(ctx context.Context, event *HealthcheckEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	c.mu.Lock()
+	c.buffer = append(c.buffer, *event)
+	full := len(c.buffer) >= c.size
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Flush writes the buffered events. On failure the events are put back to be retried by the next flush,
+// unless the buffer has grown too large. The events of deleted healthchecks are dropped.
+func (c *BufferedHealthcheckEventRepo) Flush() error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	c.flushing, c.buffer = c.buffer, nil
+	events := c.flushing
+	c.mu.Unlock()
+
+	err := c.HealthcheckEventRepo.CreateBatch(context.Background(), events)
+	if isForeignKeyViolation(err) {
+		// a healthcheck is deleted, the events are written one by one to drop only its events.
+		events, err = c.createEach(events)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushing = nil
+	if err != nil {
+		if len(events)+len(c.buffer) <= maxBufferedBatches*c.size {
+			c.buffer = append(events, c.buffer...)
+		} else {
+			logrus.Errorf("dropping %d healthcheck events, the buffer is full", len(events))
+		}
+	}
+
+	return err
+}
+
+// createEach writes the events one at a time and drops the ones of deleted healthchecks. It stops at the
+// first other error and returns the events which aren't written.
+func (c *BufferedHealthcheckEventRepo) createEach(events []HealthcheckEvent) ([]HealthcheckEvent, error) {
+	for i := range events {
+		err := c.HealthcheckEventRepo.CreateBatch(context.Background(), events[i:i+1])
+		if isForeignKeyViolation(err) {
+			logrus.Warnf("dropping event of healthcheck %d, the healthcheck is deleted", events[i].HealthcheckID)
+			continue
+		}
+		if err != nil {
+			return events[i:], err
+		}
+	}
+	return nil, nil
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation
+}
+
+// Discard drops the buffered events of the healthcheck.
+func (c *BufferedHealthcheckEventRepo) Discard(healthcheckID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.buffer[:0]
+	for _, event := range c.buffer {
+		if event.HealthcheckID != healthcheckID {
+			kept = append(kept, event)
+		}
+	}
+	c.buffer = kept
+}
+
+func (c *BufferedHealthcheckEventRepo) FindLast(ctx context.Context, healthcheckID int) (HealthcheckEvent, error) {
+	c.mu.Lock()
+	event, ok := findLastEvent(c.buffer, healthcheckID)
+	if !ok {
+		event, ok = findLastEvent(c.flushing, healthcheckID)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		return event, nil
+	}
+	return c.HealthcheckEventRepo.FindLast(ctx, healthcheckID)
+}
+
+// FindStateSince finds the start of the run in the buffered events, the run continues in the written
+// events if all the buffered events of the healthcheck have the state.
+func (c *BufferedHealthcheckEventRepo) FindStateSince(ctx context.Context, healthcheckID int,
+	state string) (time.Time, error) {
+	c.mu.Lock()
+	pending := make([]HealthcheckEvent, 0, len(c.flushing)+len(c.buffer))
+	pending = append(append(pending, c.flushing...), c.buffer...)
+	c.mu.Unlock()
+
+	var since time.Time
+	for i := len(pending) - 1; i >= 0; i-- {
+		if pending[i].HealthcheckID != healthcheckID {
+			continue
+		}
+		if pending[i].State != state {
+			if since.IsZero() {
+				return since, ErrRecordNotFound
+			}
+			return since, nil
+		}
+		since = pending[i].CreatedAt
+	}
+
+	written, err := c.HealthcheckEventRepo.FindStateSince(ctx, healthcheckID, state)
+	if errors.Is(err, ErrRecordNotFound) && !since.IsZero() {
+		return since, nil
+	}
+	return written, err
+}
+
+func findLastEvent(events []HealthcheckEvent, healthcheckID int) (HealthcheckEvent, bool) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].HealthcheckID == healthcheckID {
+			return events[i], true
+		}
+	}
+	return HealthcheckEvent{}, false
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventPartition is a monthly partition of the healthcheck_events table holding the events in [From, To).
+type EventPartition struct {
+	Name string
+	From time.Time
+	To   time.Time
+}
+
+type HealthcheckEventPartitionRepo interface {
+	// Create creates the partition of the month containing the given time if it doesn't exist.
+	Create(month time.Time) error
+	FindAll() ([]EventPartition, error)
+	Drop(name string) error
+}
+
+var _ HealthcheckEventPartitionRepo = SQLHealthcheckEventPartitionRepo{}
+
+type SQLHealthcheckEventPartitionRepo struct {
+	DB *gorm.DB
+}
+
+const eventPartitionPrefix = "healthcheck_events_"
+
+func (c SQLHealthcheckEventPartitionRepo) Create(month time.Time) error {
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	name := eventPartitionPrefix + from.Format("2006_01")
+
+	return c.DB.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF healthcheck_events FOR VALUES FROM ('%s') TO ('%s')",
+		name, from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)).Error
+}
+
+func (c SQLHealthcheckEventPartitionRepo) FindAll() ([]EventPartition, error) {
+	var names []string
+	err := c.DB.Raw(`
+SELECT child.relname FROM pg_inherits
+JOIN pg_class parent ON pg_inherits.inhparent = parent.oid
+JOIN pg_class child ON pg_inherits.inhrelid = child.oid
+WHERE parent.relname = 'healthcheck_events'
+ORDER BY child.relname`).Scan(&names).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]EventPartition, 0, len(names))
+	for _, name := range names {
+		from, err := time.Parse("2006_01", strings.TrimPrefix(name, eventPartitionPrefix))
+		if err != nil {
+			// not a monthly partition created by us.
+			continue
+		}
+		result = append(result, EventPartition{Name: name, From: from, To: from.AddDate(0, 1, 0)})
+	}
+
+	return result, nil
+}
+
+func (c SQLHealthcheckEventPartitionRepo) Drop(name string) error {
+	return c.DB.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", name)).Error
+}
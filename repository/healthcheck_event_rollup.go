@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rollup granularities.
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// HealthcheckEventRollup aggregates the events of a healthcheck in a bucket (an hour or a day).
+// The latency percentiles of daily rollups are approximated by the weighted average of the hourly ones.
+type HealthcheckEventRollup struct {
+	HealthcheckID    int       `json:"healthcheckId"`
+	Granularity      string    `json:"granularity"`
+	Bucket           time.Time `json:"bucket"`
+	UpCount          int       `json:"upCount"`
+	DownCount        int       `json:"downCount"`
+	UnreachableCount int       `json:"unreachableCount"`
+	UpSeconds        float64   `json:"upSeconds"`
+	DownSeconds      float64   `json:"downSeconds"`
+	Incidents        int       `json:"incidents"`
+	LatencyCount     int       `json:"latencyCount"`
+	P50Ms            float64   `json:"p50Ms" gorm:"column:p50_ms"`
+	P90Ms            float64   `json:"p90Ms" gorm:"column:p90_ms"`
+	P95Ms            float64   `json:"p95Ms" gorm:"column:p95_ms"`
+	P99Ms            float64   `json:"p99Ms" gorm:"column:p99_ms"`
+}
+
+type HealthcheckEventRollupRepo interface {
+	// RollupHours aggregates the raw events in [from, to) into hourly rollups, existing rollups are kept.
+	RollupHours(ctx context.Context, from, to time.Time) error
+	// RollupDays aggregates the hourly rollups in [from, to) into daily rollups.
+	RollupDays(ctx context.Context, from, to time.Time) error
+	DeleteHours(ctx context.Context, before time.Time) error
+	Find(ctx context.Context, healthcheckID int, granularity string, from, to time.Time) ([]HealthcheckEventRollup, error)
+	// FindWatermark returns the end of the last rolled up day of the healthcheck, the events before it
+	// must be read from rollups.
+	FindWatermark(ctx context.Context, healthcheckID int) (time.Time, error)
+}
+
+var _ HealthcheckEventRollupRepo = SQLHealthcheckEventRollupRepo{}
+
+type SQLHealthcheckEventRollupRepo struct {
+	DB *gorm.DB
+}
+
+func (c SQLHealthcheckEventRollupRepo) RollupHours(ctx context.Context, from, to time.Time) error {
+	return conn(ctx, c.DB).Exec(`
+INSERT INTO healthcheck_event_rollups (healthcheck_id, granularity, bucket, up_count, down_count,
+	unreachable_count, up_seconds, down_seconds, incidents, latency_count, p50_ms, p90_ms, p95_ms, p99_ms)
+SELECT healthcheck_id, 'hour', date_trunc('hour', created_at) AS bucket,
+	COUNT(*) FILTER (WHERE state = 'up'),
+	COUNT(*) FILTER (WHERE state = 'down'),
+	COUNT(*) FILTER (WHERE state = 'unreachable'),
+	COALESCE(SUM(seconds) FILTER (WHERE state = 'up'), 0),
+	COALESCE(SUM(seconds) FILTER (WHERE state <> 'up'), 0),
+	COUNT(*) FILTER (WHERE state <> 'up' AND (previous_state IS NULL OR previous_state = 'up')),
+	COUNT(*) FILTER (WHERE status_code > 0),
+	COALESCE(percentile_cont(0.50) WITHIN GROUP (ORDER BY total_ms) FILTER (WHERE status_code > 0), 0),
+	COALESCE(percentile_cont(0.90) WITHIN GROUP (ORDER BY total_ms) FILTER (WHERE status_code > 0), 0),
+	COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY total_ms) FILTER (WHERE status_code > 0), 0),
+	COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY total_ms) FILTER (WHERE status_code > 0), 0)
+FROM (
+	SELECT healthcheck_id, created_at, state, status_code, total_ms,
+		EXTRACT(EPOCH FROM LEAD(created_at, 1, @to) OVER w - created_at) AS seconds,
+		LAG(state) OVER w AS previous_state
+	FROM healthcheck_events
+	WHERE created_at >= @from AND created_at < @to AND healthcheck_id IN (SELECT id FROM healthchecks)
+	WINDOW w AS (PARTITION BY healthcheck_id ORDER BY created_at)
+) segments
+GROUP BY healthcheck_id, bucket
+ON CONFLICT (healthcheck_id, granularity, bucket) DO NOTHING`,
+		sql.Named("from", from), sql.Named("to", to)).Error
+}
+
+func (c SQLHealthcheckEventRollupRepo) RollupDays(ctx context.Context, from, to time.Time) error {
+	return conn(ctx, c.DB).Exec(`
+INSERT INTO healthcheck_event_rollups (healthcheck_id, granularity, bucket, up_count, down_count,
+	unreachable_count, up_seconds, down_seconds, incidents, latency_count, p50_ms, p90_ms, p95_ms, p99_ms)
+SELECT healthcheck_id, 'day', date_trunc('day', bucket) AS day,
+	SUM(up_count), SUM(down_count), SUM(unreachable_count), SUM(up_seconds), SUM(down_seconds),
+	SUM(incidents), SUM(latency_count),
+	COALESCE(SUM(p50_ms * latency_count) / NULLIF(SUM(latency_count), 0), 0),
+	COALESCE(SUM(p90_ms * latency_count) / NULLIF(SUM(latency_count), 0), 0),
+	COALESCE(SUM(p95_ms * latency_count) / NULLIF(SUM(latency_count), 0), 0),
+	COALESCE(SUM(p99_ms * latency_count) / NULLIF(SUM(latency_count), 0), 0)
+FROM healthcheck_event_rollups
+WHERE granularity = 'hour' AND bucket >= @from AND bucket < @to
+GROUP BY healthcheck_id, day
+ON CONFLICT (healthcheck_id, granularity, bucket) DO UPDATE SET
+	up_count = EXCLUDED.up_count,
+	down_count = EXCLUDED.down_count,
+	unreachable_count = EXCLUDED.unreachable_count,
+	up_seconds = EXCLUDED.up_seconds,
+	down_seconds = EXCLUDED.down_seconds,
+	incidents = EXCLUDED.incidents,
+	latency_count = EXCLUDED.latency_count,
+	p50_ms = EXCLUDED.p50_ms,
+	p90_ms = EXCLUDED.p90_ms,
+	p95_ms = EXCLUDED.p95_ms,
+	p99_ms = EXCLUDED.p99_ms`,
+		sql.Named("from", from), sql.Named("to", to)).Error
+}
+
+func (c SQLHealthcheckEventRollupRepo) DeleteHours(ctx context.Context, before time.Time) error {
+	return conn(ctx, c.DB).Where("granularity = ? AND bucket < ?", GranularityHour, before).
+		Delete(&HealthcheckEventRollup{}).Error
+}
+
+func (c SQLHealthcheckEventRollupRepo) Find(ctx context.Context, healthcheckID int, granularity string,
+	from, to time.Time) ([]HealthcheckEventRollup, error) {
+	result := []HealthcheckEventRollup{}
+	err := conn(ctx, c.DB).Where("healthcheck_id = ? AND granularity = ? AND bucket >= ? AND bucket < ?",
+		healthcheckID, granularity, from, to).
+		Order("bucket").
+		Find(&result).Error
+
+	return result, err
+}
+
+func (c SQLHealthcheckEventRollupRepo) FindWatermark(ctx context.Context, healthcheckID int) (time.Time, error) {
+	var watermark sql.NullTime
+	err := conn(ctx, c.DB).Raw(`
+SELECT MAX(bucket) + interval '1 day' FROM healthcheck_event_rollups
+WHERE healthcheck_id = ? AND granularity = 'day'`, healthcheckID).Row().Scan(&watermark)
+
+	return watermark.Time, err
+}
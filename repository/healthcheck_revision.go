@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HealthcheckRevision is the definition a healthcheck had at one of its versions, Revision is the version.
+// A revision is kept every time the healthcheck is created or updated.
+type HealthcheckRevision struct {
+	HealthcheckID int                   `json:"healthcheckId" gorm:"primaryKey;autoIncrement:false"`
+	Revision      int                   `json:"revision" gorm:"primaryKey;autoIncrement:false"`
+	Definition    HealthcheckDefinition `json:"definition"`
+	CreatedAt     time.Time             `json:"createdAt"`
+}
+
+// HealthcheckDefinition is the configuration of a healthcheck, the fields set by the scheduler, the pings
+// and the concurrency control aren't part of it. It is stored as a jsonb column.
+type HealthcheckDefinition struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Owner              string            `json:"owner"`
+	Labels             Labels            `json:"labels"`
+	Kind               string            `json:"kind"`
+	IntervalSeconds    int               `json:"intervalSeconds"`
+	Url                string            `json:"url"`
+	HttpMethod         string            `json:"httpMethod"`
+	Headers            Headers           `json:"headers"`
+	Body               string            `json:"body"`
+	ValidStatusCodes   StatusCodes       `json:"validStatusCodes,omitempty"`
+	CompositeRule      string            `json:"compositeRule,omitempty"`
+	CompositeMinUp     int               `json:"compositeMinUp,omitempty"`
+	CompositeThreshold float64           `json:"compositeThreshold,omitempty"`
+	Members            []CompositeMember `json:"members,omitempty"`
+	GraceSeconds       int               `json:"graceSeconds,omitempty"`
+	CronExpression     string            `json:"cronExpression,omitempty"`
+	Timezone           string            `json:"timezone,omitempty"`
+	ActiveHours        ActiveHoursList   `json:"activeHours,omitempty"`
+}
+
+// NewHealthcheckDefinition returns the definition of the healthcheck.
+func NewHealthcheckDefinition(healthcheck Healthcheck) HealthcheckDefinition {
+	return HealthcheckDefinition{
+		Name:               healthcheck.Name,
+		Description:        healthcheck.Description,
+		Owner:              healthcheck.Owner,
+		Labels:             healthcheck.Labels,
+		Kind:               healthcheck.Kind,
+		IntervalSeconds:    healthcheck.IntervalSeconds,
+		Url:                healthcheck.Url,
+		HttpMethod:         healthcheck.HttpMethod,
+		Headers:            healthcheck.Headers,
+		Body:               healthcheck.Body,
+		ValidStatusCodes:   healthcheck.ValidStatusCodes,
+		CompositeRule:      healthcheck.CompositeRule,
+		CompositeMinUp:     healthcheck.CompositeMinUp,
+		CompositeThreshold: healthcheck.CompositeThreshold,
+		Members:            healthcheck.Members,
+		GraceSeconds:       healthcheck.GraceSeconds,
+		CronExpression:     healthcheck.CronExpression,
+		Timezone:           healthcheck.Timezone,
+		ActiveHours:        healthcheck.ActiveHours,
+	}
+}
+
+// ApplyTo returns the healthcheck with its definition replaced by this one.
+func (d HealthcheckDefinition) ApplyTo(healthcheck Healthcheck) Healthcheck {
+	healthcheck.Name = d.Name
+	healthcheck.Description = d.Description
+	healthcheck.Owner = d.Owner
+	healthcheck.Labels = d.Labels
+	healthcheck.Kind = d.Kind
+	healthcheck.IntervalSeconds = d.IntervalSeconds
+	healthcheck.Url = d.Url
+	healthcheck.HttpMethod = d.HttpMethod
+	healthcheck.Headers = d.Headers
+	healthcheck.Body = d.Body
+	healthcheck.ValidStatusCodes = d.ValidStatusCodes
+	healthcheck.CompositeRule = d.CompositeRule
+	healthcheck.CompositeMinUp = d.CompositeMinUp
+	healthcheck.CompositeThreshold = d.CompositeThreshold
+	healthcheck.Members = d.Members
+	healthcheck.GraceSeconds = d.GraceSeconds
+	healthcheck.CronExpression = d.CronExpression
+	healthcheck.Timezone = d.Timezone
+	healthcheck.ActiveHours = d.ActiveHours
+	return healthcheck
+}
+
+func (d HealthcheckDefinition) Value() (driver.Value, error) {
+	value, err := json.Marshal(d)
+	return string(value), err
+}
+
+func (d *HealthcheckDefinition) Scan(value interface{}) error {
+	switch value := value.(type) {
+	case []byte:
+		return json.Unmarshal(value, d)
+	case string:
+		return json.Unmarshal([]byte(value), d)
+	default:
+		return fmt.Errorf("can't scan %T into healthcheck definition", value)
+	}
+}
+
+type HealthcheckRevisionRepo interface {
+	// FindAll returns the revisions of the healthcheck, the latest first.
+	FindAll(ctx context.Context, healthcheckID int) ([]HealthcheckRevision, error)
+	FindOne(ctx context.Context, healthcheckID, revision int) (HealthcheckRevision, error)
+}
+
+var _ HealthcheckRevisionRepo = SQLHealthcheckRevisionRepo{}
+
+type SQLHealthcheckRevisionRepo struct {
+	DB *gorm.DB
+}
+
+func (c SQLHealthcheckRevisionRepo) FindAll(ctx context.Context, healthcheckID int) ([]HealthcheckRevision, error) {
+	result := []HealthcheckRevision{}
+	err := conn(ctx, c.DB).Where("healthcheck_id = ?", healthcheckID).Order("revision DESC").Find(&result).Error
+
+	return result, err
+}
+
+func (c SQLHealthcheckRevisionRepo) FindOne(ctx context.Context, healthcheckID,
+	revision int) (HealthcheckRevision, error) {
+	result := HealthcheckRevision{}
+	query := conn(ctx, c.DB).Where("healthcheck_id = ? AND revision = ?", healthcheckID, revision).Find(&result)
+
+	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
+		return result, ErrRecordNotFound
+	}
+	if query.Error != nil {
+		return result, query.Error
+	}
+
+	return result, nil
+}
+
+// saveRevision keeps the definition of the saved healthcheck in the transaction, a revision which is
+// already kept isn't replaced.
+func saveRevision(tx *gorm.DB, healthcheck Healthcheck) error {
+	revision := HealthcheckRevision{
+		HealthcheckID: healthcheck.ID,
+		Revision:      healthcheck.Version,
+		Definition:    NewHealthcheckDefinition(healthcheck),
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revision).Error
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultProjectID is the project of the healthchecks created without one, it's created by the migrations
+// with its organization.
+const DefaultProjectID = 1
+
+// Organization owns projects, its quotas limit the healthchecks of all of its projects. Zero quotas are
+// unlimited.
+type Organization struct {
+	ID                 int       `json:"id"`
+	Name               string    `json:"name"`
+	MaxHealthchecks    int       `json:"maxHealthchecks"`
+	MinIntervalSeconds int       `json:"minIntervalSeconds"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+type OrganizationRepo interface {
+	Save(ctx context.Context, organization *Organization) error
+	FindOne(ctx context.Context, id int) (Organization, error)
+	FindAll(ctx context.Context) ([]Organization, error)
+	// CountHealthchecks returns the number of healthchecks in the projects of the organization.
+	CountHealthchecks(ctx context.Context, id int) (int64, error)
+	// Lock locks the organization until the transaction of the context ends, so the transactions which
+	// count and create its healthchecks run one after another.
+	Lock(ctx context.Context, id int) error
+}
+
+var _ OrganizationRepo = SQLOrganizationRepo{}
+
+type SQLOrganizationRepo struct {
+	DB *gorm.DB
+}
+
+func (c SQLOrganizationRepo) Save(ctx context.Context, organization *Organization) error {
+	return conn(ctx, c.DB).Save(organization).Error
+}
+
+func (c SQLOrganizationRepo) FindOne(ctx context.Context, id int) (Organization, error) {
+	organization := Organization{}
+	query := conn(ctx, c.DB).Where("id = ?", id).Find(&organization)
+
+	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
+		return organization, ErrRecordNotFound
+	}
+	if query.Error != nil {
+		return organization, query.Error
+	}
+
+	return organization, nil
+}
+
+func (c SQLOrganizationRepo) FindAll(ctx context.Context) ([]Organization, error) {
+	result := []Organization{}
+	err := conn(ctx, c.DB).Order("id").Find(&result).Error
+
+	return result, err
+}
+
+func (c SQLOrganizationRepo) CountHealthchecks(ctx context.Context, id int) (int64, error) {
+	var count int64
+	err := conn(ctx, c.DB).Model(&Healthcheck{}).
+		Joins("JOIN projects ON projects.id = healthchecks.project_id").
+		Where("projects.organization_id = ?", id).
+		Count(&count).Error
+
+	return count, err
+}
+
+func (c SQLOrganizationRepo) Lock(ctx context.Context, id int) error {
+	return conn(ctx, c.DB).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Find(&Organization{}).Error
+}
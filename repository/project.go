@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Project owns healthchecks and API keys, WebhookURL replaces the configured webhook for the alerts of its
+// healthchecks when set.
+type Project struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organizationId"`
+	Name           string    `json:"name"`
+	WebhookURL     string    `json:"webhookUrl"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+type ProjectRepo interface {
+	Save(ctx context.Context, project *Project) error
+	FindOne(ctx context.Context, id int) (Project, error)
+	// FindAll returns the projects, only the given one if projectID isn't zero.
+	FindAll(ctx context.Context, projectID int) ([]Project, error)
+}
+
+var _ ProjectRepo = SQLProjectRepo{}
+
+type SQLProjectRepo struct {
+	DB *gorm.DB
+}
+
+func (c SQLProjectRepo) Save(ctx context.Context, project *Project) error {
+	return conn(ctx, c.DB).Save(project).Error
+}
+
+func (c SQLProjectRepo) FindOne(ctx context.Context, id int) (Project, error) {
+	project := Project{}
+	query := conn(ctx, c.DB).Where("id = ?", id).Find(&project)
+
+	if errors.Is(query.Error, gorm.ErrRecordNotFound) || query.RowsAffected == 0 {
+		return project, ErrRecordNotFound
+	}
+	if query.Error != nil {
+		return project, query.Error
+	}
+
+	return project, nil
+}
+
+func (c SQLProjectRepo) FindAll(ctx context.Context, projectID int) ([]Project, error) {
+	query := conn(ctx, c.DB)
+	if projectID != 0 {
+		query = query.Where("id = ?", projectID)
+	}
+
+	result := []Project{}
+	err := query.Order("id").Find(&result).Error
+
+	return result, err
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// SchemaRepo reads the state of the database and its schema.
+type SchemaRepo interface {
+	Ping(ctx context.Context) error
+	// FindVersion returns the migration version recorded by golang-migrate, dirty means the last
+	// migration failed halfway.
+	FindVersion(ctx context.Context) (version uint, dirty bool, err error)
+}
+
+var _ SchemaRepo = SQLSchemaRepo{}
+
+type SQLSchemaRepo struct {
+	DB *gorm.DB
+}
+
+func (c SQLSchemaRepo) Ping(ctx context.Context) error {
+	db, err := c.DB.DB()
+	if err != nil {
+		return err
+	}
+	return db.PingContext(ctx)
+}
+
+func (c SQLSchemaRepo) FindVersion(ctx context.Context) (uint, bool, error) {
+	var (
+		version uint
+		dirty   bool
+	)
+	err := conn(ctx, c.DB).Raw("SELECT version, dirty FROM schema_migrations LIMIT 1").Row().Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, ErrRecordNotFound
+	}
+
+	return version, dirty, err
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Transactor runs functions in a database transaction, the repos run the queries of a context holding
+// the transaction in it.
+type Transactor interface {
+	// Transaction runs fn in a transaction which is committed if fn returns nil and rolled back otherwise.
+	// fn joins the transaction of ctx if there is one.
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+var _ Transactor = SQLTransactor{}
+
+type SQLTransactor struct {
+	DB *gorm.DB
+}
+
+type txKey struct{}
+
+func (c SQLTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+	return conn(ctx, c.DB).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// conn returns the transaction of the context, or the database if there is none, running the queries
+// with the context.
+func conn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
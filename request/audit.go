@@ -0,0 +1,17 @@
+package request
+
+import "time"
+
+// ListAudit filters and paginates the audit log, e.g. resourceType=healthcheck&resourceId=42&action=delete.
+type ListAudit struct {
+	Actor        string    `query:"actor" validate:"max=255"`
+	ProjectID    int       `query:"projectId" validate:"gte=0"`
+	Action       string    `query:"action" validate:"omitempty,oneof=create update delete start stop"`
+	ResourceType string    `query:"resourceType" validate:"omitempty,oneof=healthcheck apikey organization project"`
+	ResourceID   int       `query:"resourceId" validate:"gte=0"`
+	From         time.Time `query:"from"`
+	To           time.Time `query:"to"`
+	Cursor       int       `query:"cursor" validate:"gte=0"`
+	Limit        int       `query:"limit" validate:"gte=0,lte=500"`
+	Order        string    `query:"order" validate:"omitempty,oneof=asc desc"`
+}
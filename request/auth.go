@@ -0,0 +1,16 @@
+package request
+
+import "time"
+
+// CreateAPIKey creates an API key with the role, it doesn't expire if ExpiresAt is nil. The key is limited
+// to the project if ProjectID is set.
+type CreateAPIKey struct {
+	Name      string     `json:"name" validate:"required,max=255"`
+	Role      string     `json:"role" validate:"required,oneof=viewer editor admin"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	ProjectID int        `json:"projectId" validate:"gte=0"`
+}
+
+type DeleteAPIKey struct {
+	ID int `param:"id" validate:"required,gt=0"`
+}
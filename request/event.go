@@ -0,0 +1,18 @@
+package request
+
+import "time"
+
+type ListEvents struct {
+	ID        int       `param:"id"`
+	ProjectID int       `query:"projectId" validate:"gte=0"`
+	From      time.Time `query:"from"`
+	To        time.Time `query:"to"`
+	State     []string  `query:"state" validate:"dive,oneof=up down unreachable"`
+	Cursor    int       `query:"cursor" validate:"gte=0"`
+	Limit     int       `query:"limit" validate:"gte=0,lte=500"`
+	Order     string    `query:"order" validate:"omitempty,oneof=asc desc"`
+}
+
+type GetHealthcheckStatus struct {
+	ID int `param:"id" validate:"required,gt=0"`
+}
@@ -1,11 +1,94 @@
 package request
 
+import (
+	"encoding/json"
+
+	"github.com/go-playground/validator"
+)
+
 type CreateHealthcheck struct {
-	IntervalSeconds int               `json:"IntervalSeconds"`
-	Url             string            `json:"url"`
-	HttpMethod      string            `json:"httpMethod"`
-	Headers         map[string]string `json:"headers"`
-	Body            string            `json:"body"`
+	ProjectID          int               `json:"projectId" validate:"gte=0"`
+	ExternalName       string            `json:"externalName" validate:"omitempty,labelkey"`
+	Name               string            `json:"name" validate:"max=255"`
+	Description        string            `json:"description" validate:"max=2048"`
+	Owner              string            `json:"owner" validate:"max=255"`
+	Labels             map[string]string `json:"labels" validate:"max=64,dive,keys,labelkey,endkeys,labelvalue"`
+	Kind               string            `json:"kind" validate:"omitempty,oneof=http composite heartbeat"`
+	IntervalSeconds    int               `json:"IntervalSeconds" validate:"gte=0,lte=604800"`
+	Url                string            `json:"url" validate:"omitempty,max=2048,httpurl"`
+	HttpMethod         string            `json:"httpMethod" validate:"omitempty,oneof=GET HEAD POST PUT PATCH DELETE OPTIONS"`
+	Headers            map[string]string `json:"headers" validate:"max=50,dive,keys,headername,endkeys,max=4096,headervalue"`
+	Body               string            `json:"body" validate:"max=65536"`
+	ValidStatusCodes   []int             `json:"validStatusCodes" validate:"max=50,dive,gte=100,lte=599"`
+	DependsOn          []int             `json:"dependsOn" validate:"max=100,dive,gt=0"`
+	CompositeRule      string            `json:"compositeRule" validate:"omitempty,oneof=all any atLeast weighted"`
+	CompositeMinUp     int               `json:"compositeMinUp" validate:"gte=0"`
+	CompositeThreshold float64           `json:"compositeThreshold" validate:"gte=0,lte=1"`
+	Members            []CompositeMember `json:"members" validate:"max=100,dive"`
+	GraceSeconds       int               `json:"graceSeconds" validate:"gte=0,lte=604800"`
+	CronExpression     string            `json:"cronExpression" validate:"max=100"`
+	Timezone           string            `json:"timezone" validate:"max=64"`
+	ActiveHours        []ActiveHours     `json:"activeHours" validate:"max=20,dive"`
+}
+
+// CompositeMember is a member of a composite healthcheck, Weight defaults to 1.
+type CompositeMember struct {
+	ID     int     `json:"id" validate:"required,gt=0"`
+	Weight float64 `json:"weight" validate:"gte=0"`
+}
+
+// ActiveHours is a weekly window the healthcheck runs in, e.g. {"days": ["mon", "fri"], "from": "09:00",
+// "to": "17:00"}. Days defaults to every day.
+type ActiveHours struct {
+	Days []string `json:"days" validate:"max=7,dive,weekday"`
+	From string   `json:"from" validate:"required,timeofday"`
+	To   string   `json:"to" validate:"required,timeofday"`
+}
+
+// validateCreateHealthcheck checks the fields which depend on the kind of the healthcheck.
+func validateCreateHealthcheck(sl validator.StructLevel) {
+	req := sl.Current().Interface().(CreateHealthcheck)
+	validateKind(sl, req, len(req.Members))
+}
+
+// validateKind checks the fields of the healthcheck which depend on its kind, members is the number of its
+// members.
+func validateKind(sl validator.StructLevel, req CreateHealthcheck, members int) {
+	switch req.Kind {
+	case "", "http":
+		if req.Url == "" {
+			sl.ReportError(req.Url, "url", "Url", "required_for", "http")
+		}
+	case "composite":
+		if req.CompositeRule == "" {
+			sl.ReportError(req.CompositeRule, "compositeRule", "CompositeRule", "required_for", "composite")
+		}
+		if members == 0 {
+			sl.ReportError(req.Members, "members", "Members", "required_for", "composite")
+		}
+	case "heartbeat":
+		if req.CronExpression != "" {
+			sl.ReportError(req.CronExpression, "cronExpression", "CronExpression", "excluded_for", "heartbeat")
+		}
+		if len(req.ActiveHours) > 0 {
+			sl.ReportError(req.ActiveHours, "activeHours", "ActiveHours", "excluded_for", "heartbeat")
+		}
+	}
+
+	if req.CronExpression == "" && req.IntervalSeconds == 0 {
+		sl.ReportError(req.IntervalSeconds, "IntervalSeconds", "IntervalSeconds", "required_without",
+			"cronExpression")
+	}
+}
+
+type GetHealthcheck struct {
+	ID int `param:"id" validate:"required,gt=0"`
+}
+
+// UpdateHealthcheck replaces a healthcheck, the body is the same as the one of CreateHealthcheck.
+type UpdateHealthcheck struct {
+	ID int `param:"id" json:"-" validate:"required,gt=0"`
+	CreateHealthcheck
 }
 
 type DeleteHealthcheck struct {
@@ -15,3 +98,66 @@ type DeleteHealthcheck struct {
 type ToggleHealthcheck struct {
 	ID int `param:"id" validate:"required,gt=0"`
 }
+
+type GetHealthcheckDependencies struct {
+	ID int `param:"id" validate:"required,gt=0"`
+}
+
+// RunHealthcheck runs a healthcheck once, its event is recorded and alerts are sent if Record is true.
+type RunHealthcheck struct {
+	ID     int  `param:"id" validate:"required,gt=0"`
+	Record bool `query:"record"`
+}
+
+type ListHealthcheckRevisions struct {
+	ID int `param:"id" validate:"required,gt=0"`
+}
+
+// RestoreHealthcheckRevision replaces the definition of a healthcheck with one of its revisions.
+type RestoreHealthcheckRevision struct {
+	ID       int `param:"id" validate:"required,gt=0"`
+	Revision int `param:"rev" validate:"required,gt=0"`
+}
+
+// PatchHealthcheck updates some fields of a healthcheck, the body is a json merge patch of the
+// CreateHealthcheck fields and is decoded by the handler.
+type PatchHealthcheck struct {
+	ID int `param:"id" validate:"required,gt=0"`
+}
+
+// ListHealthchecks filters, sorts and paginates the healthchecks. Selector is a label selector, e.g.
+// "env=prod,team in (payments,auth)", and Url matches a substring of the url.
+type ListHealthchecks struct {
+	ProjectID int      `query:"projectId" validate:"gte=0"`
+	Selector  string   `query:"selector" validate:"max=1024"`
+	Url       string   `query:"url" validate:"max=2048"`
+	Owner     string   `query:"owner" validate:"max=255"`
+	State     []string `query:"state" validate:"dive,oneof=up down unreachable"`
+	Running   string   `query:"running" validate:"omitempty,oneof=true false"`
+	Sort      string   `query:"sort" validate:"omitempty,oneof=id name owner url kind"`
+	Order     string   `query:"order" validate:"omitempty,oneof=asc desc"`
+	Offset    int      `query:"offset" validate:"gte=0"`
+	Limit     int      `query:"limit" validate:"gte=0,lte=500"`
+}
+
+// BulkHealthchecks selects the healthchecks of a bulk operation by a label selector, by ids or by both, in
+// which case the healthchecks must match both.
+type BulkHealthchecks struct {
+	Selector string `json:"selector" validate:"max=1024"`
+	IDs      []int  `json:"ids" validate:"max=1000,dive,gt=0"`
+}
+
+// BulkUpdateHealthchecks applies Patch, a json merge patch of the CreateHealthcheck fields, to the selected
+// healthchecks.
+type BulkUpdateHealthchecks struct {
+	BulkHealthchecks
+	Patch map[string]json.RawMessage `json:"patch" validate:"required,min=1"`
+}
+
+func validateBulkHealthchecks(sl validator.StructLevel) {
+	req := sl.Current().Interface().(BulkHealthchecks)
+
+	if req.Selector == "" && len(req.IDs) == 0 {
+		sl.ReportError(req.Selector, "selector", "Selector", "required_without", "ids")
+	}
+}
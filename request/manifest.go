@@ -0,0 +1,153 @@
+package request
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"github.com/mitchellh/mapstructure"
+)
+
+// Manifest formats.
+const (
+	ManifestYAML = "yaml"
+	ManifestJSON = "json"
+)
+
+// Manifest declares the healthchecks of a project, e.g. in a YAML file kept in git. The healthchecks are
+// identified by their external names, the ones of the project with an external name which aren't declared
+// are deleted when the manifest is applied. The healthchecks without an external name are left alone.
+type Manifest struct {
+	ProjectID    int                   `json:"projectId" validate:"gte=0"`
+	Healthchecks []ManifestHealthcheck `json:"healthchecks" validate:"max=1000,dive"`
+}
+
+// ManifestHealthcheck is a healthcheck declared by a manifest. Its dependencies and members reference
+// healthchecks by id or by the external name of a declared healthcheck, so the manifest can reference the
+// healthchecks it creates and be applied to another environment.
+type ManifestHealthcheck struct {
+	ManifestDefinition `json:",squash"`
+	DependsOn          []ManifestReference `json:"dependsOn" validate:"max=100,dive,required,labelkey"`
+	Members            []ManifestMember    `json:"members" validate:"max=100,dive"`
+}
+
+// ManifestDefinition is the definition of a declared healthcheck, the dependencies and members of the
+// ManifestHealthcheck replace its own.
+type ManifestDefinition CreateHealthcheck
+
+// ManifestReference references a healthcheck by its id, or by its external name if it isn't a number.
+type ManifestReference string
+
+// ID returns the id the reference is, false if it's an external name.
+func (r ManifestReference) ID() (int, bool) {
+	id, err := strconv.Atoi(string(r))
+	return id, err == nil && id > 0
+}
+
+// ManifestMember is a member of a declared composite healthcheck, Weight defaults to 1.
+type ManifestMember struct {
+	ID     ManifestReference `json:"id" validate:"required,labelkey"`
+	Weight float64           `json:"weight" validate:"gte=0"`
+}
+
+// manifestReferences are the references of a declared healthcheck, they're decoded apart from its
+// definition which has fields of the same names.
+type manifestReferences struct {
+	DependsOn []ManifestReference `json:"dependsOn"`
+	Members   []ManifestMember    `json:"members"`
+}
+
+// ParseManifest parses a manifest in the format, the fields are named like in the json requests.
+func ParseManifest(data []byte, format string) (Manifest, error) {
+	var parser koanf.Parser = yaml.Parser()
+	if format == ManifestJSON {
+		parser = json.Parser()
+	}
+
+	var manifest Manifest
+	k := koanf.New(".")
+	if err := k.Load(rawbytes.Provider(data), parser); err != nil {
+		return manifest, fmt.Errorf("invalid %s manifest: %w", format, err)
+	}
+	if err := decodeManifest(k.Raw(), &manifest); err != nil {
+		return manifest, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// decodeManifest decodes the parsed manifest, or a part of it, into the result like koanf does.
+func decodeManifest(input interface{}, result interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			decodeManifestHealthcheck),
+		TagName:          "json",
+		Result:           result,
+		WeaklyTypedInput: true,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(input)
+}
+
+// decodeManifestHealthcheck decodes a declared healthcheck, its references are decoded apart from its
+// definition.
+func decodeManifestHealthcheck(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	fields, ok := data.(map[string]interface{})
+	if !ok || to != reflect.TypeOf(ManifestHealthcheck{}) {
+		return data, nil
+	}
+
+	definition := make(map[string]interface{}, len(fields))
+	references := make(map[string]interface{})
+	for key, value := range fields {
+		if strings.EqualFold(key, "dependsOn") || strings.EqualFold(key, "members") {
+			references[key] = value
+		} else {
+			definition[key] = value
+		}
+	}
+
+	var healthcheck ManifestHealthcheck
+	if err := decodeManifest(definition, &healthcheck.ManifestDefinition); err != nil {
+		return nil, err
+	}
+	var refs manifestReferences
+	if err := decodeManifest(references, &refs); err != nil {
+		return nil, err
+	}
+	healthcheck.DependsOn, healthcheck.Members = refs.DependsOn, refs.Members
+	return healthcheck, nil
+}
+
+// validateManifest checks every healthcheck has a distinct external name.
+func validateManifest(sl validator.StructLevel) {
+	req := sl.Current().Interface().(Manifest)
+
+	declared := make(map[string]bool, len(req.Healthchecks))
+	for i, healthcheck := range req.Healthchecks {
+		field := fmt.Sprintf("healthchecks[%d].externalName", i)
+		if healthcheck.ExternalName == "" {
+			sl.ReportError(healthcheck.ExternalName, field, "ExternalName", "required", "")
+			continue
+		}
+		if declared[healthcheck.ExternalName] {
+			sl.ReportError(healthcheck.ExternalName, field, "ExternalName", "unique", "")
+		}
+		declared[healthcheck.ExternalName] = true
+	}
+}
+
+// validateManifestHealthcheck checks the fields which depend on the kind of a declared healthcheck.
+func validateManifestHealthcheck(sl validator.StructLevel) {
+	req := sl.Current().Interface().(ManifestHealthcheck)
+	validateKind(sl, CreateHealthcheck(req.ManifestDefinition), len(req.Members))
+}
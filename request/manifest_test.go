@@ -0,0 +1,152 @@
+package request
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	want := Manifest{
+		ProjectID: 2,
+		Healthchecks: []ManifestHealthcheck{{
+			ManifestDefinition: ManifestDefinition{
+				ExternalName:     "payments-api",
+				Name:             "Payments API",
+				Labels:           map[string]string{"team": "payments", "app.kubernetes.io/name": "api"},
+				IntervalSeconds:  30,
+				Url:              "https://payments.example.com/health",
+				HttpMethod:       "GET",
+				Headers:          map[string]string{"Accept": "application/json"},
+				ValidStatusCodes: []int{200, 204},
+				ActiveHours:      []ActiveHours{{Days: []string{"mon", "fri"}, From: "09:00", To: "17:00"}},
+			},
+			DependsOn: []ManifestReference{"3", "payments-db"},
+		}, {
+			ManifestDefinition: ManifestDefinition{
+				ExternalName:    "payments",
+				Kind:            "composite",
+				IntervalSeconds: 60,
+				CompositeRule:   "all",
+			},
+			Members: []ManifestMember{{ID: "payments-api", Weight: 2}, {ID: "4"}},
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		valid  bool
+	}{
+		{"yaml", `
+projectId: 2
+healthchecks:
+  - externalName: payments-api
+    name: Payments API
+    labels:
+      team: payments
+      app.kubernetes.io/name: api
+    IntervalSeconds: 30
+    url: https://payments.example.com/health
+    httpMethod: GET
+    headers:
+      Accept: application/json
+    validStatusCodes: [200, 204]
+    dependsOn: [3, payments-db]
+    activeHours:
+      - days: [mon, fri]
+        from: "09:00"
+        to: "17:00"
+  - externalName: payments
+    kind: composite
+    IntervalSeconds: 60
+    compositeRule: all
+    members:
+      - id: payments-api
+        weight: 2
+      - id: 4
+`, ManifestYAML, true},
+		{"json", `{
+  "projectId": 2,
+  "healthchecks": [{
+    "externalName": "payments-api",
+    "name": "Payments API",
+    "labels": {"team": "payments", "app.kubernetes.io/name": "api"},
+    "IntervalSeconds": 30,
+    "url": "https://payments.example.com/health",
+    "httpMethod": "GET",
+    "headers": {"Accept": "application/json"},
+    "validStatusCodes": [200, 204],
+    "dependsOn": [3, "payments-db"],
+    "activeHours": [{"days": ["mon", "fri"], "from": "09:00", "to": "17:00"}]
+  }, {
+    "externalName": "payments",
+    "kind": "composite",
+    "IntervalSeconds": 60,
+    "compositeRule": "all",
+    "members": [{"id": "payments-api", "weight": 2}, {"id": 4}]
+  }]
+}`, ManifestJSON, true},
+		{"invalid yaml", "healthchecks: [", ManifestYAML, false},
+		{"invalid json", `{"healthchecks": [}`, ManifestJSON, false},
+		{"healthchecks not a list", "healthchecks: payments-api", ManifestYAML, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseManifest([]byte(test.data), test.format)
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("parsed %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("manifest is %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestValidateManifestExternalNames(t *testing.T) {
+	manifest := Manifest{Healthchecks: []ManifestHealthcheck{
+		{ManifestDefinition: ManifestDefinition{ExternalName: "api", Url: "https://api.example.com",
+			IntervalSeconds: 60}},
+		{ManifestDefinition: ManifestDefinition{ExternalName: "api", Url: "https://api.example.com/v2",
+			IntervalSeconds: 60}},
+	}}
+
+	err := NewValidator().Validate(&manifest)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error is %v, want a validation error", err)
+	}
+	want := "invalid fields: healthchecks[1].externalName (unique)"
+	if err.Error() != want {
+		t.Errorf("error is %q, want %q", err.Error(), want)
+	}
+}
+
+func TestManifestReferenceID(t *testing.T) {
+	tests := []struct {
+		reference ManifestReference
+		id        int
+		ok        bool
+	}{
+		{"3", 3, true},
+		{"payments-db", 0, false},
+		{"0", 0, false},
+		{"-1", 0, false},
+	}
+	for _, test := range tests {
+		t.Run(string(test.reference), func(t *testing.T) {
+			id, ok := test.reference.ID()
+			if ok != test.ok || (ok && id != test.id) {
+				t.Errorf("id is %d, %t, want %d, %t", id, ok, test.id, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package request
+
+type Ping struct {
+	Token string `param:"token" validate:"required"`
+}
+
+type PingExitCode struct {
+	Token    string `param:"token" validate:"required"`
+	ExitCode int    `param:"exitCode" validate:"gte=0,lte=255"`
+}
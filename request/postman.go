@@ -0,0 +1,268 @@
+package request
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// ImportPostman imports the requests of a Postman collection as http healthcheck, nothing is saved by a
+// dry run. The variables override the ones of the environment, which override the collection ones.
+type ImportPostman struct {
+	DryRun          bool                `query:"dryRun"`
+	ProjectID       int                 `json:"projectId" validate:"gte=0"`
+	Collection      PostmanCollection   `json:"collection"`
+	Environment     *PostmanEnvironment `json:"environment"`
+	Variables       map[string]string   `json:"variables" validate:"max=1000"`
+	IntervalSeconds int                 `json:"intervalSeconds" validate:"gte=0,lte=604800"`
+	Labels          map[string]string   `json:"labels" validate:"max=64,dive,keys,labelkey,endkeys,labelvalue"`
+}
+
+// PostmanCollection is a collection of the v2.0 or v2.1 format, only the parts which are imported are
+// decoded.
+type PostmanCollection struct {
+	Info     PostmanInfo       `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Variable []PostmanVariable `json:"variable"`
+	Auth     *PostmanAuth      `json:"auth"`
+	Event    []PostmanEvent    `json:"event"`
+}
+
+type PostmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+// PostmanItem is either a request or a folder of items.
+type PostmanItem struct {
+	Name        string             `json:"name"`
+	Description PostmanDescription `json:"description"`
+	Item        []PostmanItem      `json:"item"`
+	Request     *PostmanRequest    `json:"request"`
+	Auth        *PostmanAuth       `json:"auth"`
+	Event       []PostmanEvent     `json:"event"`
+}
+
+type PostmanRequest struct {
+	Method      string             `json:"method"`
+	URL         PostmanURL         `json:"url"`
+	Header      PostmanHeaders     `json:"header"`
+	Body        *PostmanBody       `json:"body"`
+	Auth        *PostmanAuth       `json:"auth"`
+	Description PostmanDescription `json:"description"`
+}
+
+// UnmarshalJSON decodes the request, which may be just its url.
+func (r *PostmanRequest) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*r = PostmanRequest{URL: PostmanURL{Raw: raw}}
+		return nil
+	}
+	type request PostmanRequest
+	return json.Unmarshal(data, (*request)(r))
+}
+
+// PostmanURL is the url of a request, Variable are the values of its path variables such as :id.
+type PostmanURL struct {
+	Raw      string            `json:"raw"`
+	Protocol string            `json:"protocol"`
+	Host     postmanStrings    `json:"host"`
+	Port     string            `json:"port"`
+	Path     postmanStrings    `json:"path"`
+	Query    []PostmanVariable `json:"query"`
+	Variable []PostmanVariable `json:"variable"`
+}
+
+// UnmarshalJSON decodes the url, which may be a string.
+func (u *PostmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*u = PostmanURL{Raw: raw}
+		return nil
+	}
+	type postmanURL PostmanURL
+	return json.Unmarshal(data, (*postmanURL)(u))
+}
+
+// String returns the raw url, or the url built from its parts if it has no raw one.
+func (u PostmanURL) String() string {
+	result := u.Raw
+	if result == "" {
+		result = strings.Join(u.Host, ".")
+		if u.Protocol != "" {
+			result = u.Protocol + "://" + result
+		}
+		if u.Port != "" {
+			result += ":" + u.Port
+		}
+		if len(u.Path) > 0 {
+			result += "/" + strings.Join(u.Path, "/")
+		}
+		var query []string
+		for _, param := range u.Query {
+			if !param.Disabled {
+				query = append(query, param.Key+"="+string(param.Value))
+			}
+		}
+		if len(query) > 0 {
+			result += "?" + strings.Join(query, "&")
+		}
+	}
+
+	// the path variables are the segments starting with a colon.
+	for _, variable := range u.Variable {
+		if variable.Key != "" {
+			result = regexp.MustCompile(`/:`+regexp.QuoteMeta(variable.Key)+`\b`).
+				ReplaceAllLiteralString(result, "/"+string(variable.Value))
+		}
+	}
+	return result
+}
+
+// PostmanHeaders are the headers of a request, they may be a string of "Name: value" lines.
+type PostmanHeaders []PostmanVariable
+
+func (h *PostmanHeaders) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		*h = nil
+		for _, line := range strings.Split(raw, "\n") {
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) == 2 {
+				*h = append(*h, PostmanVariable{Key: strings.TrimSpace(parts[0]),
+					Value: postmanValue(strings.TrimSpace(parts[1]))})
+			}
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]PostmanVariable)(h))
+}
+
+// PostmanVariable is a variable, a header or a parameter. The collections disable them by Disabled and
+// the environments by Enabled.
+type PostmanVariable struct {
+	Key      string       `json:"key"`
+	Value    postmanValue `json:"value"`
+	Disabled bool         `json:"disabled"`
+	Enabled  *bool        `json:"enabled"`
+}
+
+// Active reports whether the variable is enabled.
+func (v PostmanVariable) Active() bool {
+	return !v.Disabled && (v.Enabled == nil || *v.Enabled)
+}
+
+type PostmanBody struct {
+	Mode       string            `json:"mode"`
+	Raw        string            `json:"raw"`
+	URLEncoded []PostmanVariable `json:"urlencoded"`
+	GraphQL    *PostmanGraphQL   `json:"graphql"`
+	Disabled   bool              `json:"disabled"`
+	Options    struct {
+		Raw struct {
+			Language string `json:"language"`
+		} `json:"raw"`
+	} `json:"options"`
+}
+
+type PostmanGraphQL struct {
+	Query     string `json:"query"`
+	Variables string `json:"variables"`
+}
+
+// PostmanAuth is the authentication of requests, the parameters are a list of key/values in v2.1 and an
+// object in v2.0.
+type PostmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer postmanAuthParams `json:"bearer"`
+	Basic  postmanAuthParams `json:"basic"`
+	APIKey postmanAuthParams `json:"apikey"`
+}
+
+type postmanAuthParams map[string]string
+
+func (p *postmanAuthParams) UnmarshalJSON(data []byte) error {
+	var list []PostmanVariable
+	if err := json.Unmarshal(data, &list); err == nil {
+		*p = make(postmanAuthParams, len(list))
+		for _, param := range list {
+			(*p)[param.Key] = string(param.Value)
+		}
+		return nil
+	}
+	var params map[string]postmanValue
+	if err := json.Unmarshal(data, &params); err != nil {
+		return err
+	}
+	*p = make(postmanAuthParams, len(params))
+	for key, value := range params {
+		(*p)[key] = string(value)
+	}
+	return nil
+}
+
+// PostmanEvent is a script run before (prerequest) or after (test) a request.
+type PostmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec postmanStrings `json:"exec"`
+	} `json:"script"`
+}
+
+// PostmanDescription is a description, which may be an object with its content.
+type PostmanDescription string
+
+func (d *PostmanDescription) UnmarshalJSON(data []byte) error {
+	var description struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &description); err == nil {
+		*d = PostmanDescription(description.Content)
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(d))
+}
+
+// PostmanEnvironment is an exported Postman environment.
+type PostmanEnvironment struct {
+	Name   string            `json:"name"`
+	Values []PostmanVariable `json:"values"`
+}
+
+// postmanStrings are strings which may be a single string.
+type postmanStrings []string
+
+func (s *postmanStrings) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = postmanStrings{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(s))
+}
+
+// postmanValue is the value of a variable, which may be a number or a boolean.
+type postmanValue string
+
+func (v *postmanValue) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case nil:
+		*v = ""
+	case string:
+		*v = postmanValue(value)
+	default:
+		*v = postmanValue(strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// SupportedPostmanSchema reports whether the collection has the v2.0 or the v2.1 format.
+func (req ImportPostman) SupportedPostmanSchema() bool {
+	schema := req.Collection.Info.Schema
+	return strings.Contains(schema, "/v2.0.") || strings.Contains(schema, "/v2.1.")
+}
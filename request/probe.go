@@ -0,0 +1,6 @@
+package request
+
+type Probe struct {
+	Target string `query:"target" validate:"required"`
+	Module string `query:"module"`
+}
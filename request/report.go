@@ -0,0 +1,49 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeRange is bound from a query param in the "from/to" form, both ends are RFC 3339 timestamps.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+func (t *TimeRange) UnmarshalParam(param string) error {
+	parts := strings.Split(param, "/")
+	if len(parts) != 2 {
+		return fmt.Errorf("time range %q is not in the from/to form", param)
+	}
+
+	from, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return err
+	}
+	to, err := time.Parse(time.RFC3339, parts[1])
+	if err != nil {
+		return err
+	}
+	if !to.After(from) {
+		return fmt.Errorf("time range %q ends before it starts", param)
+	}
+
+	t.From, t.To = from, to
+	return nil
+}
+
+type GetHealthcheckUptime struct {
+	ID          int         `param:"id" validate:"required,gt=0"`
+	From        time.Time   `query:"from"`
+	To          time.Time   `query:"to"`
+	Granularity string      `query:"granularity" validate:"omitempty,oneof=hour day"`
+	Exclude     []TimeRange `query:"exclude"`
+}
+
+type GetHealthcheckLatency struct {
+	ID   int       `param:"id" validate:"required,gt=0"`
+	From time.Time `query:"from"`
+	To   time.Time `query:"to"`
+}
@@ -0,0 +1,28 @@
+package request
+
+// CreateOrganization creates an organization, zero quotas are unlimited.
+type CreateOrganization struct {
+	Name               string `json:"name" validate:"required,max=255"`
+	MaxHealthchecks    int    `json:"maxHealthchecks" validate:"gte=0"`
+	MinIntervalSeconds int    `json:"minIntervalSeconds" validate:"gte=0,lte=604800"`
+}
+
+type UpdateOrganization struct {
+	ID int `param:"id" json:"-" validate:"required,gt=0"`
+	CreateOrganization
+}
+
+// CreateProject creates a project in the organization, its alerts are sent to WebhookURL if it's set.
+type CreateProject struct {
+	OrganizationID int    `json:"organizationId" validate:"required,gt=0"`
+	Name           string `json:"name" validate:"required,max=255"`
+	WebhookURL     string `json:"webhookUrl" validate:"omitempty,max=2048,httpurl"`
+}
+
+// UpdateProject replaces the name and the notification settings of the project, it can't be moved to
+// another organization.
+type UpdateProject struct {
+	ID         int    `param:"id" json:"-" validate:"required,gt=0"`
+	Name       string `json:"name" validate:"required,max=255"`
+	WebhookURL string `json:"webhookUrl" validate:"omitempty,max=2048,httpurl"`
+}
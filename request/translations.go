@@ -0,0 +1,89 @@
+package request
+
+import (
+	"reflect"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator"
+)
+
+// translations are the messages of the validation errors by locale and key, {0} is the field and {1} the
+// param of the failed rule. The keys are the tags, the length rules have a -string and -items variant for
+// strings and collections.
+var translations = map[string]map[string]string{
+	"en": {
+		"invalid":          "{0} is invalid",
+		"required":         "{0} is required",
+		"required_for":     "{0} is required for {1} healthchecks",
+		"excluded_for":     "{0} is not supported by {1} healthchecks",
+		"required_without": "{0} is required when {1} is not set",
+		"oneof":            "{0} must be one of [{1}]",
+		"min":              "{0} must be {1} or greater",
+		"min-string":       "{0} must be at least {1} characters long",
+		"min-items":        "{0} must contain at least {1} items",
+		"max":              "{0} must be {1} or less",
+		"max-string":       "{0} must be at most {1} characters long",
+		"max-items":        "{0} must contain at most {1} items",
+		"gt":               "{0} must be greater than {1}",
+		"gte":              "{0} must be {1} or greater",
+		"lt":               "{0} must be less than {1}",
+		"lte":              "{0} must be {1} or less",
+		"httpurl":          "{0} must be an absolute http or https URL",
+		"headername":       "{0} must be a valid HTTP header name",
+		"headervalue":      "{0} must not contain control characters such as line breaks",
+		"timeofday":        "{0} must be a time of day formatted as HH:MM",
+		"weekday":          "{0} must be a day of the week such as mon",
+		"labelkey": "{0} must be at most 63 letters, digits, '-', '_', '.' and '/' starting and ending with " +
+			"a letter or digit",
+		"labelvalue": "{0} must be at most 63 letters, digits, '-', '_' and '.' starting and ending with " +
+			"a letter or digit",
+		"unique": "{0} must be unique",
+	},
+	"fa": {
+		"invalid":          "{0} نامعتبر است",
+		"required":         "{0} الزامی است",
+		"required_for":     "{0} برای بررسی‌های {1} الزامی است",
+		"excluded_for":     "{0} در بررسی‌های {1} پشتیبانی نمی‌شود",
+		"required_without": "{0} در صورت تعیین نشدن {1} الزامی است",
+		"oneof":            "{0} باید یکی از [{1}] باشد",
+		"min":              "{0} باید حداقل {1} باشد",
+		"min-string":       "طول {0} باید حداقل {1} کاراکتر باشد",
+		"min-items":        "{0} باید حداقل {1} مورد داشته باشد",
+		"max":              "{0} باید حداکثر {1} باشد",
+		"max-string":       "طول {0} باید حداکثر {1} کاراکتر باشد",
+		"max-items":        "{0} باید حداکثر {1} مورد داشته باشد",
+		"gt":               "{0} باید بزرگ‌تر از {1} باشد",
+		"gte":              "{0} باید بزرگ‌تر یا مساوی {1} باشد",
+		"lt":               "{0} باید کوچک‌تر از {1} باشد",
+		"lte":              "{0} باید کوچک‌تر یا مساوی {1} باشد",
+		"httpurl":          "{0} باید یک آدرس کامل http یا https باشد",
+		"headername":       "{0} باید یک نام معتبر برای هدر HTTP باشد",
+		"headervalue":      "{0} نباید شامل کاراکترهای کنترلی مانند شکست خط باشد",
+		"timeofday":        "{0} باید زمانی از روز با قالب HH:MM باشد",
+		"weekday":          "{0} باید یک روز هفته مانند mon باشد",
+		"labelkey": "{0} باید حداکثر ۶۳ حرف، رقم، '-'، '_'، '.' و '/' باشد و با حرف یا رقم " +
+			"شروع و تمام شود",
+		"labelvalue": "{0} باید حداکثر ۶۳ حرف، رقم، '-'، '_' و '.' باشد و با حرف یا رقم " +
+			"شروع و تمام شود",
+		"unique": "{0} باید یکتا باشد",
+	},
+}
+
+// translate returns the message of a validation error of the field in the language of the translator.
+func translate(trans ut.Translator, field string, fe validator.FieldError) string {
+	key := fe.Tag()
+	if key == "min" || key == "max" {
+		switch fe.Kind() {
+		case reflect.String:
+			key += "-string"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			key += "-items"
+		}
+	}
+
+	message, err := trans.T(key, field, fe.Param())
+	if err != nil {
+		message, _ = trans.T("invalid", field)
+	}
+	return message
+}
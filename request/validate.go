@@ -1,24 +1,241 @@
 package request
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/fa"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator"
 	"github.com/labstack/echo/v4"
+	"golang.org/x/net/http/httpguts"
 )
 
+var (
+	labelKeyRegex   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]{0,61}[A-Za-z0-9])?$`)
+	labelValueRegex = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]{0,61}[A-Za-z0-9])?)?$`)
+)
+
+var weekdays = map[string]bool{"sun": true, "mon": true, "tue": true, "wed": true, "thu": true, "fri": true,
+	"sat": true}
+
 type Validator struct {
-	v *validator.Validate
+	v   *validator.Validate
+	uni *ut.UniversalTranslator
+}
+
+// ValidationError is returned by Validate when some fields of the request are invalid, it's responded as
+// field-level errors by the HTTPErrorHandler of the Validator.
+type ValidationError struct {
+	errs validator.ValidationErrors
+	typ  reflect.Type
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.errs))
+	for _, fe := range e.errs {
+		rule := fe.Tag()
+		if fe.Param() != "" {
+			rule += "=" + fe.Param()
+		}
+		fields = append(fields, fmt.Sprintf("%s (%s)", fieldPath(e.typ, fe), rule))
+	}
+	return "invalid fields: " + strings.Join(fields, ", ")
+}
+
+// FieldError is an invalid field of a request, Tag and Param are the failed rule and its parameter and
+// Message is the translated description of the error.
+type FieldError struct {
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the response body of the requests with invalid fields.
+type ValidationErrorResponse struct {
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
 }
 
 func NewValidator() *Validator {
-	return &Validator{v: validator.New()}
+	v := validator.New()
+
+	// the errors name the fields the way clients send them.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "param", "query"} {
+			name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+		return ""
+	})
+
+	validations := map[string]validator.Func{
+		"httpurl":     isHTTPURL,
+		"headername":  isHeaderName,
+		"headervalue": isHeaderValue,
+		"timeofday":   isTimeOfDay,
+		"weekday":     isWeekday,
+		"labelkey":    isLabelKey,
+		"labelvalue":  isLabelValue,
+	}
+	for tag, fn := range validations {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			panic(err)
+		}
+	}
+	v.RegisterStructValidation(validateCreateHealthcheck, CreateHealthcheck{})
+	v.RegisterStructValidation(validateBulkHealthchecks, BulkHealthchecks{})
+	v.RegisterStructValidation(validateManifest, Manifest{})
+	v.RegisterStructValidation(validateManifestHealthcheck, ManifestHealthcheck{})
+
+	uni := ut.New(en.New(), en.New(), fa.New())
+	for locale, messages := range translations {
+		trans, _ := uni.GetTranslator(locale)
+		for key, text := range messages {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return &Validator{v: v, uni: uni}
 }
 
 func (v *Validator) Validate(i interface{}) error {
 	if err := v.v.Struct(i); err != nil {
+		var errs validator.ValidationErrors
+		if errors.As(err, &errs) {
+			return &ValidationError{errs: errs, typ: reflect.TypeOf(i)}
+		}
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
 	return nil
 }
+
+// HTTPErrorHandler responds the validation errors with their fields, translated to the first supported
+// language of the Accept-Language header, and passes the other errors to next.
+func (v *Validator) HTTPErrorHandler(next echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			next(err, c)
+			return
+		}
+
+		trans, _ := v.uni.FindTranslator(acceptedLocales(c.Request().Header.Get("Accept-Language"))...)
+		response := ValidationErrorResponse{
+			Message: "bad request: validation failed",
+			Errors:  make([]FieldError, 0, len(validationErr.errs)),
+		}
+		for _, fe := range validationErr.errs {
+			field := fieldPath(validationErr.typ, fe)
+			response.Errors = append(response.Errors, FieldError{
+				Field:   field,
+				Tag:     fe.Tag(),
+				Param:   fe.Param(),
+				Message: translate(trans, field, fe),
+			})
+		}
+
+		c.Response().Header().Set("Content-Language", trans.Locale())
+		next(echo.NewHTTPError(http.StatusBadRequest, response), c)
+	}
+}
+
+// acceptedLocales returns the locales of an Accept-Language header in the order they are listed, the
+// quality values are ignored.
+func acceptedLocales(header string) []string {
+	var locales []string
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		tag = strings.ReplaceAll(tag, "-", "_")
+		locales = append(locales, tag)
+		if i := strings.IndexByte(tag, '_'); i > 0 {
+			locales = append(locales, tag[:i])
+		}
+	}
+	return locales
+}
+
+// fieldPath returns the path of the field in the request body, e.g. members[0].id, without the name of the
+// request and of its embedded structs.
+func fieldPath(typ reflect.Type, fe validator.FieldError) string {
+	names := strings.Split(fe.Namespace(), ".")[1:]
+	fields := strings.Split(fe.StructNamespace(), ".")[1:]
+	if len(names) != len(fields) {
+		return fe.Field()
+	}
+
+	path := make([]string, 0, len(names))
+	for i, name := range names {
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array ||
+			typ.Kind() == reflect.Map {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			path = append(path, names[i:]...)
+			break
+		}
+
+		fieldName := fields[i]
+		if j := strings.IndexByte(fieldName, '['); j >= 0 {
+			fieldName = fieldName[:j]
+		}
+		field, ok := typ.FieldByName(fieldName)
+		if !ok {
+			path = append(path, names[i:]...)
+			break
+		}
+		if !field.Anonymous {
+			path = append(path, name)
+		}
+		typ = field.Type
+	}
+
+	return strings.Join(path, ".")
+}
+
+func isHTTPURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func isHeaderName(fl validator.FieldLevel) bool {
+	return httpguts.ValidHeaderFieldName(fl.Field().String())
+}
+
+func isHeaderValue(fl validator.FieldLevel) bool {
+	return httpguts.ValidHeaderFieldValue(fl.Field().String())
+}
+
+func isTimeOfDay(fl validator.FieldLevel) bool {
+	_, err := time.Parse("15:04", fl.Field().String())
+	return err == nil
+}
+
+func isWeekday(fl validator.FieldLevel) bool {
+	return weekdays[strings.ToLower(fl.Field().String())]
+}
+
+func isLabelKey(fl validator.FieldLevel) bool {
+	return labelKeyRegex.MatchString(fl.Field().String())
+}
+
+func isLabelValue(fl validator.FieldLevel) bool {
+	return labelValueRegex.MatchString(fl.Field().String())
+}
@@ -0,0 +1,142 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// validHealthcheck returns a valid http healthcheck the tests make invalid.
+func validHealthcheck() CreateHealthcheck {
+	return CreateHealthcheck{Url: "https://example.com/health", IntervalSeconds: 60}
+}
+
+// validationResponse validates the request and returns the response of the error handler to a request
+// with the Accept-Language header.
+func validationResponse(t *testing.T, req interface{}, acceptLanguage string) (ValidationErrorResponse, string) {
+	v := NewValidator()
+	err := v.Validate(req)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error is %v, want a validation error", err)
+	}
+
+	httpRequest := httptest.NewRequest(http.MethodPost, "/", nil)
+	httpRequest.Header.Set("Accept-Language", acceptLanguage)
+	recorder := httptest.NewRecorder()
+	c := echo.New().NewContext(httpRequest, recorder)
+
+	var httpErr *echo.HTTPError
+	v.HTTPErrorHandler(func(err error, c echo.Context) {
+		errors.As(err, &httpErr)
+	})(err, c)
+	if httpErr == nil || httpErr.Code != http.StatusBadRequest {
+		t.Fatalf("error is %v, want a bad request", httpErr)
+	}
+	response, ok := httpErr.Message.(ValidationErrorResponse)
+	if !ok {
+		t.Fatalf("message is %T, want ValidationErrorResponse", httpErr.Message)
+	}
+	return response, recorder.Header().Get("Content-Language")
+}
+
+func TestValidationErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            func() interface{}
+		acceptLanguage string
+		want           FieldError
+		locale         string
+	}{
+		{"string length", func() interface{} {
+			req := validHealthcheck()
+			req.Name = strings.Repeat("a", 256)
+			return &req
+		}, "en", FieldError{Field: "name", Tag: "max", Param: "255",
+			Message: "name must be at most 255 characters long"}, "en"},
+		{"collection length", func() interface{} {
+			req := validHealthcheck()
+			req.ValidStatusCodes = make([]int, 51)
+			return &req
+		}, "en", FieldError{Field: "validStatusCodes", Tag: "max", Param: "50",
+			Message: "validStatusCodes must contain at most 50 items"}, "en"},
+		{"slice element field", func() interface{} {
+			req := validHealthcheck()
+			req.Kind, req.CompositeRule, req.Url = "composite", "all", ""
+			req.Members = []CompositeMember{{ID: 1}, {ID: 0}}
+			return &req
+		}, "en", FieldError{Field: "members[1].id", Tag: "required",
+			Message: "members[1].id is required"}, "en"},
+		{"map key", func() interface{} {
+			req := validHealthcheck()
+			req.Labels = map[string]string{"-team": "payments"}
+			return &req
+		}, "en", FieldError{Field: "labels[-team]", Tag: "labelkey",
+			Message: "labels[-team] must be at most 63 letters, digits, '-', '_', '.' and '/' starting and " +
+				"ending with a letter or digit"}, "en"},
+		{"map value", func() interface{} {
+			req := validHealthcheck()
+			req.Headers = map[string]string{"X-Trace": "a\nb"}
+			return &req
+		}, "en", FieldError{Field: "headers[X-Trace]", Tag: "headervalue",
+			Message: "headers[X-Trace] must not contain control characters such as line breaks"}, "en"},
+		{"struct level", func() interface{} {
+			req := validHealthcheck()
+			req.Url = ""
+			return &req
+		}, "en", FieldError{Field: "url", Tag: "required_for", Param: "http",
+			Message: "url is required for http healthchecks"}, "en"},
+		{"embedded struct", func() interface{} {
+			return &UpdateHealthcheck{ID: 1, CreateHealthcheck: CreateHealthcheck{Url: "ftp://example.com",
+				IntervalSeconds: 60}}
+		}, "en", FieldError{Field: "url", Tag: "httpurl",
+			Message: "url must be an absolute http or https URL"}, "en"},
+		{"manifest item", func() interface{} {
+			return &Manifest{Healthchecks: []ManifestHealthcheck{
+				{ManifestDefinition: ManifestDefinition(validHealthcheck())}}}
+		}, "en", FieldError{Field: "healthchecks[0].externalName", Tag: "required",
+			Message: "healthchecks[0].externalName is required"}, "en"},
+		{"persian", func() interface{} {
+			req := validHealthcheck()
+			req.ActiveHours = []ActiveHours{{From: "25:00", To: "17:00"}}
+			return &req
+		}, "fa-IR, en;q=0.8", FieldError{Field: "activeHours[0].from", Tag: "timeofday",
+			Message: "activeHours[0].from باید زمانی از روز با قالب HH:MM باشد"}, "fa"},
+		{"unsupported language", func() interface{} {
+			req := validHealthcheck()
+			req.HttpMethod = "TRACE"
+			return &req
+		}, "de-DE", FieldError{Field: "httpMethod", Tag: "oneof", Param: "GET HEAD POST PUT PATCH DELETE OPTIONS",
+			Message: "httpMethod must be one of [GET HEAD POST PUT PATCH DELETE OPTIONS]"}, "en"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, locale := validationResponse(t, test.req(), test.acceptLanguage)
+			if locale != test.locale {
+				t.Errorf("content language is %q, want %q", locale, test.locale)
+			}
+			if len(response.Errors) != 1 {
+				t.Fatalf("errors are %+v, want one", response.Errors)
+			}
+			if response.Errors[0] != test.want {
+				t.Errorf("error is %+v, want %+v", response.Errors[0], test.want)
+			}
+		})
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	req := validHealthcheck()
+	req.Name = strings.Repeat("a", 256)
+	req.Members = []CompositeMember{{ID: -1}}
+
+	err := NewValidator().Validate(&req)
+	want := "invalid fields: name (max=255), members[0].id (gt=0)"
+	if err == nil || err.Error() != want {
+		t.Errorf("error is %v, want %q", err, want)
+	}
+}
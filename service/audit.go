@@ -0,0 +1,180 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// Audited actions.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditStart  = "start"
+	AuditStop   = "stop"
+)
+
+// Audited resource types.
+const (
+	ResourceHealthcheck  = "healthcheck"
+	ResourceAPIKey       = "apikey"
+	ResourceOrganization = "organization"
+	ResourceProject      = "project"
+)
+
+// redacted replaces the secrets of the resources in the audit log.
+const redacted = "[redacted]"
+
+// secretHeaderParts are the parts of the names of the headers whose values are secrets, like Authorization,
+// Cookie and X-API-Key.
+var secretHeaderParts = []string{"auth", "cookie", "key", "password", "secret", "session", "token"}
+
+// AuditChange is a top level field of a resource changed by an audited action, From is null if the field
+// was added and To is null if it was removed.
+type AuditChange struct {
+	From json.RawMessage `json:"from"`
+	To   json.RawMessage `json:"to"`
+}
+
+// AuditService records the changes made through the API in the append-only audit log.
+type AuditService interface {
+	// Record appends the entry to the audit log with the states of the resource before and after the
+	// change, they are nil if the resource didn't exist.
+	Record(ctx context.Context, entry repository.AuditEntry, before, after interface{}) error
+	Find(ctx context.Context, filter repository.AuditFilter) ([]repository.AuditEntry, error)
+}
+
+type auditService struct {
+	auditLogRepo repository.AuditLogRepo
+}
+
+var _ AuditService = &auditService{}
+
+func NewAuditService(auditLogRepo repository.AuditLogRepo) AuditService {
+	return &auditService{
+		auditLogRepo: auditLogRepo,
+	}
+}
+
+func (as *auditService) Record(ctx context.Context, entry repository.AuditEntry, before, after interface{}) error {
+	var err error
+	if entry.Before, err = auditData(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditData(after); err != nil {
+		return err
+	}
+
+	// the whole resource is created or deleted if one of the states is nil.
+	if entry.Before != nil && entry.After != nil {
+		changes, err := auditChanges(entry.Before, entry.After)
+		if err != nil {
+			return err
+		}
+		if entry.Changes, err = json.Marshal(changes); err != nil {
+			return err
+		}
+	}
+
+	return as.auditLogRepo.Create(ctx, &entry)
+}
+
+func (as *auditService) Find(ctx context.Context, filter repository.AuditFilter) ([]repository.AuditEntry, error) {
+	return as.auditLogRepo.Find(ctx, filter)
+}
+
+// Diff returns the top level fields whose json differs between the states of a resource.
+func Diff(before, after interface{}) (map[string]AuditChange, error) {
+	from, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	return auditChanges(from, to)
+}
+
+// auditData marshals the state of a resource, nil pointers are nil.
+func auditData(value interface{}) (repository.AuditData, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil || string(data) == "null" {
+		return nil, err
+	}
+	return redactSecrets(data)
+}
+
+// redactSecrets replaces the values of the secret headers and the ping token of a json object, other
+// values are returned as they are.
+func redactSecrets(data []byte) ([]byte, error) {
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return data, nil
+	}
+
+	changed := false
+	if _, ok := object["pingToken"]; ok {
+		object["pingToken"], _ = json.Marshal(redacted)
+		changed = true
+	}
+	headers := map[string]string{}
+	if err := json.Unmarshal(object["headers"], &headers); err == nil {
+		for name := range headers {
+			if isSecretHeader(name) {
+				headers[name] = redacted
+				changed = true
+			}
+		}
+		var err error
+		if object["headers"], err = json.Marshal(headers); err != nil {
+			return nil, err
+		}
+	}
+	if !changed {
+		return data, nil
+	}
+	return json.Marshal(object)
+}
+
+func isSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range secretHeaderParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditChanges compares the top level fields of the json objects.
+func auditChanges(before, after []byte) (map[string]AuditChange, error) {
+	from := map[string]json.RawMessage{}
+	if err := json.Unmarshal(before, &from); err != nil {
+		return nil, err
+	}
+	to := map[string]json.RawMessage{}
+	if err := json.Unmarshal(after, &to); err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]AuditChange)
+	for field, value := range from {
+		if changed, ok := to[field]; !ok || !bytes.Equal(value, changed) {
+			changes[field] = AuditChange{From: value, To: changed}
+		}
+	}
+	for field, value := range to {
+		if _, ok := from[field]; !ok {
+			changes[field] = AuditChange{To: value}
+		}
+	}
+	return changes, nil
+}
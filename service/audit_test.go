@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// auditLog keeps the created entries.
+type auditLog struct {
+	repository.AuditLogRepo
+	entries []repository.AuditEntry
+}
+
+func (l *auditLog) Create(_ context.Context, entry *repository.AuditEntry) error {
+	l.entries = append(l.entries, *entry)
+	return nil
+}
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"authorization header", `{"headers":{"Accept":"application/json","Authorization":"Bearer abc"},"id":1}`,
+			`{"headers":{"Accept":"application/json","Authorization":"[redacted]"},"id":1}`},
+		{"api key header", `{"headers":{"X-API-Key":"abc","X-Trace":"1"}}`,
+			`{"headers":{"X-API-Key":"[redacted]","X-Trace":"1"}}`},
+		{"cookie headers", `{"headers":{"cookie":"session=abc","Set-Cookie":"id=1"}}`,
+			`{"headers":{"Set-Cookie":"[redacted]","cookie":"[redacted]"}}`},
+		{"ping token", `{"kind":"heartbeat","pingToken":"abc"}`, `{"kind":"heartbeat","pingToken":"[redacted]"}`},
+		{"no secrets", `{"name": "api", "headers": {"Accept": "application/json"}}`,
+			`{"name": "api", "headers": {"Accept": "application/json"}}`},
+		{"no headers", `{"headers":null,"name":"api"}`, `{"headers":null,"name":"api"}`},
+		{"not an object", `[1, 2]`, `[1, 2]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := redactSecrets([]byte(test.data))
+			if err != nil {
+				t.Fatalf("failed to redact: %s", err)
+			}
+			if string(got) != test.want {
+				t.Errorf("data is %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuditChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   map[string]AuditChange
+	}{
+		{"unchanged", `{"id":1,"url":"https://a.example.com"}`, `{"url":"https://a.example.com","id":1}`,
+			map[string]AuditChange{}},
+		{"changed field", `{"id":1,"url":"https://a.example.com"}`, `{"id":1,"url":"https://b.example.com"}`,
+			map[string]AuditChange{"url": {From: []byte(`"https://a.example.com"`),
+				To: []byte(`"https://b.example.com"`)}}},
+		{"nested change", `{"labels":{"team":"a","app":"api"}}`, `{"labels":{"team":"b","app":"api"}}`,
+			map[string]AuditChange{"labels": {From: []byte(`{"team":"a","app":"api"}`),
+				To: []byte(`{"team":"b","app":"api"}`)}}},
+		{"added field", `{"id":1}`, `{"id":1,"owner":"sre"}`,
+			map[string]AuditChange{"owner": {To: []byte(`"sre"`)}}},
+		{"removed field", `{"id":1,"owner":"sre"}`, `{"id":1}`,
+			map[string]AuditChange{"owner": {From: []byte(`"sre"`)}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := auditChanges([]byte(test.before), []byte(test.after))
+			if err != nil {
+				t.Fatalf("failed to compare: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("changes are %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAuditRecordRedactsChanges(t *testing.T) {
+	type resource struct {
+		Url       string            `json:"url"`
+		Headers   map[string]string `json:"headers"`
+		PingToken string            `json:"pingToken"`
+	}
+	before := resource{Url: "https://a.example.com", Headers: map[string]string{"Authorization": "old"},
+		PingToken: "old"}
+	after := resource{Url: "https://b.example.com", Headers: map[string]string{"Authorization": "new"},
+		PingToken: "new"}
+
+	log := &auditLog{}
+	if err := NewAuditService(log).Record(context.Background(), repository.AuditEntry{}, before, after); err != nil {
+		t.Fatalf("failed to record: %s", err)
+	}
+	if len(log.entries) != 1 {
+		t.Fatalf("entries are %+v, want one", log.entries)
+	}
+	entry := log.entries[0]
+
+	tests := []struct {
+		name string
+		got  repository.AuditData
+		want string
+	}{
+		{"before", entry.Before,
+			`{"headers":{"Authorization":"[redacted]"},"pingToken":"[redacted]","url":"https://a.example.com"}`},
+		{"after", entry.After,
+			`{"headers":{"Authorization":"[redacted]"},"pingToken":"[redacted]","url":"https://b.example.com"}`},
+		// the changed secrets aren't recorded, not even as changes.
+		{"changes", entry.Changes, `{"url":{"from":"https://a.example.com","to":"https://b.example.com"}}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if string(test.got) != test.want {
+				t.Errorf("%s is %s, want %s", test.name, test.got, test.want)
+			}
+		})
+	}
+}
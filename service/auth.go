@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/sirupsen/logrus"
+	"github.com/therealak12/api-health-check/config"
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// Roles, each role has the permissions of the previous ones.
+const (
+	// RoleViewer reads the healthchecks and their history.
+	RoleViewer = "viewer"
+	// RoleEditor changes, starts, stops and runs the healthchecks.
+	RoleEditor = "editor"
+	// RoleAdmin manages the API keys.
+	RoleAdmin = "admin"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// apiKeyPrefix starts the API keys, it tells them apart from JWTs.
+const apiKeyPrefix = "ahc_"
+
+// apiKeyPrefixLength is the length of the stored start of the keys.
+const apiKeyPrefixLength = len(apiKeyPrefix) + 8
+
+// lastUsedAtPrecision limits the updates of the last use time of the API keys to one per key and period.
+const lastUsedAtPrecision = time.Minute
+
+// ErrUnauthenticated indicates the credentials of a request are missing or invalid.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// Principal is the authenticated client of a request, APIKeyID is zero if it didn't use a stored API key.
+// The principal can only access its project if ProjectID isn't zero.
+type Principal struct {
+	Subject   string `json:"subject"`
+	Role      string `json:"role"`
+	APIKeyID  int    `json:"apiKeyId,omitempty"`
+	ProjectID int    `json:"projectId,omitempty"`
+}
+
+// Has reports whether the principal has the permissions of the role.
+func (p Principal) Has(role string) bool {
+	return roleLevels[role] > 0 && roleLevels[p.Role] >= roleLevels[role]
+}
+
+// ValidRole reports whether role is one of the roles.
+func ValidRole(role string) bool {
+	return roleLevels[role] > 0
+}
+
+// AuthService authenticates the API clients and manages their API keys.
+type AuthService interface {
+	// Authenticate returns the principal of an API key or a JWT, the errors wrap ErrUnauthenticated if the
+	// token is invalid.
+	Authenticate(ctx context.Context, token string) (Principal, error)
+	// CreateAPIKey creates an API key and returns it with the key, which can't be retrieved later. The key
+	// is limited to the project if projectID isn't zero.
+	CreateAPIKey(ctx context.Context, projectID int, name, role string,
+		expiresAt *time.Time) (repository.APIKey, string, error)
+	// ListAPIKeys and DeleteAPIKey act on the keys of the project, on all of them if projectID is zero.
+	ListAPIKeys(ctx context.Context, projectID int) ([]repository.APIKey, error)
+	DeleteAPIKey(ctx context.Context, projectID, id int) (repository.APIKey, error)
+}
+
+type authService struct {
+	apiKeyRepo repository.APIKeyRepo
+	authConfig config.Auth
+}
+
+var _ AuthService = &authService{}
+
+func NewAuthService(apiKeyRepo repository.APIKeyRepo, authConfig config.Auth) AuthService {
+	return &authService{
+		apiKeyRepo: apiKeyRepo,
+		authConfig: authConfig,
+	}
+}
+
+func (as *authService) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if as.authConfig.AdminKey != "" &&
+		subtle.ConstantTimeCompare([]byte(token), []byte(as.authConfig.AdminKey)) == 1 {
+		return Principal{Subject: "admin-key", Role: RoleAdmin}, nil
+	}
+	if strings.HasPrefix(token, apiKeyPrefix) {
+		return as.authenticateAPIKey(ctx, token)
+	}
+	return as.authenticateJWT(token)
+}
+
+func (as *authService) authenticateAPIKey(ctx context.Context, token string) (Principal, error) {
+	key, err := as.apiKeyRepo.FindByHash(ctx, hashAPIKey(token))
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return Principal{}, fmt.Errorf("%w: API key expired", ErrUnauthenticated)
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedAtPrecision {
+		if err := as.apiKeyRepo.UpdateLastUsedAt(ctx, key.ID, now); err != nil {
+			logrus.Errorf("failed to update last use of API key %d: %s", key.ID, err)
+		}
+	}
+
+	principal := Principal{Subject: fmt.Sprintf("api-key:%d", key.ID), Role: key.Role, APIKeyID: key.ID}
+	if key.ProjectID != nil {
+		principal.ProjectID = *key.ProjectID
+	}
+	return principal, nil
+}
+
+func (as *authService) authenticateJWT(token string) (Principal, error) {
+	jwtConfig := as.authConfig.JWT
+	if jwtConfig.Secret == "" {
+		return Principal{}, fmt.Errorf("%w: JWTs aren't accepted", ErrUnauthenticated)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+		}
+		return []byte(jwtConfig.Secret), nil
+	})
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %s", ErrUnauthenticated, err)
+	}
+
+	// tokens without an expiry would be valid forever.
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return Principal{}, fmt.Errorf("%w: missing expiry", ErrUnauthenticated)
+	}
+	if jwtConfig.Issuer != "" && !claims.VerifyIssuer(jwtConfig.Issuer, true) {
+		return Principal{}, fmt.Errorf("%w: invalid issuer", ErrUnauthenticated)
+	}
+	if jwtConfig.Audience != "" && !claims.VerifyAudience(jwtConfig.Audience, true) {
+		return Principal{}, fmt.Errorf("%w: invalid audience", ErrUnauthenticated)
+	}
+	role, _ := claims[jwtConfig.RoleClaim].(string)
+	if !ValidRole(role) {
+		return Principal{}, fmt.Errorf("%w: invalid role claim %q", ErrUnauthenticated, jwtConfig.RoleClaim)
+	}
+	subject, _ := claims["sub"].(string)
+	principal := Principal{Subject: subject, Role: role}
+	// tokens without the project claim aren't limited to a project.
+	if project, ok := claims[jwtConfig.ProjectClaim]; ok {
+		projectID, ok := project.(float64)
+		if !ok || projectID < 1 || projectID != float64(int(projectID)) {
+			return Principal{}, fmt.Errorf("%w: invalid project claim %q", ErrUnauthenticated, jwtConfig.ProjectClaim)
+		}
+		principal.ProjectID = int(projectID)
+	}
+
+	return principal, nil
+}
+
+func (as *authService) CreateAPIKey(ctx context.Context, projectID int, name, role string,
+	expiresAt *time.Time) (repository.APIKey, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return repository.APIKey{}, "", err
+	}
+	token := apiKeyPrefix + hex.EncodeToString(secret)
+
+	key := repository.APIKey{
+		Name:      name,
+		Prefix:    token[:apiKeyPrefixLength],
+		Hash:      hashAPIKey(token),
+		Role:      role,
+		ExpiresAt: expiresAt,
+	}
+	if err := as.apiKeyRepo.InProject(projectID).Save(ctx, &key); err != nil {
+		return key, "", err
+	}
+	return key, token, nil
+}
+
+func (as *authService) ListAPIKeys(ctx context.Context, projectID int) ([]repository.APIKey, error) {
+	return as.apiKeyRepo.InProject(projectID).FindAll(ctx)
+}
+
+func (as *authService) DeleteAPIKey(ctx context.Context, projectID, id int) (repository.APIKey, error) {
+	return as.apiKeyRepo.InProject(projectID).Delete(ctx, id)
+}
+
+// hashAPIKey hashes an API key for storing and looking it up, the keys are random so they don't need a
+// salt or a slow hash.
+func hashAPIKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// ErrInvalidComposite indicates the composite definition is not valid.
+var ErrInvalidComposite = errors.New("invalid composite healthcheck")
+
+type CompositeService interface {
+	Validate(ctx context.Context, healthcheck repository.Healthcheck) error
+	// ValidateGraph checks no composite is (transitively) a member of itself once the members of the
+	// healthchecks in members are replaced and the deleted healthchecks are removed.
+	ValidateGraph(ctx context.Context, members map[int][]int, deleted []int) error
+	// Evaluate computes the status and state of a composite healthcheck from the last events of its members.
+	Evaluate(ctx context.Context, healthcheck repository.Healthcheck) (string, string, error)
+}
+
+type compositeService struct {
+	healthcheckRepo      repository.HealthcheckRepo
+	healthcheckEventRepo repository.HealthcheckEventRepo
+}
+
+var _ CompositeService = &compositeService{}
+
+func NewCompositeService(healthcheckRepo repository.HealthcheckRepo,
+	healthcheckEventRepo repository.HealthcheckEventRepo) CompositeService {
+	return &compositeService{
+		healthcheckRepo:      healthcheckRepo,
+		healthcheckEventRepo: healthcheckEventRepo,
+	}
+}
+
+// Validate checks the rule of the composite, that its members exist in its project and that the composite
+// is not (transitively) a member of itself. The members with negative ids are planned to be created with
+// the composite, they aren't looked up.
+func (cs *compositeService) Validate(ctx context.Context, healthcheck repository.Healthcheck) error {
+	if len(healthcheck.Members) == 0 {
+		return fmt.Errorf("%w: no members", ErrInvalidComposite)
+	}
+
+	switch healthcheck.CompositeRule {
+	case repository.CompositeRuleAll, repository.CompositeRuleAny:
+	case repository.CompositeRuleAtLeast:
+		if healthcheck.CompositeMinUp < 1 || healthcheck.CompositeMinUp > len(healthcheck.Members) {
+			return fmt.Errorf("%w: compositeMinUp must be between 1 and the number of members", ErrInvalidComposite)
+		}
+	case repository.CompositeRuleWeighted:
+		if healthcheck.CompositeThreshold <= 0 || healthcheck.CompositeThreshold > 1 {
+			return fmt.Errorf("%w: compositeThreshold must be in (0, 1]", ErrInvalidComposite)
+		}
+	default:
+		return fmt.Errorf("%w: unknown rule %q", ErrInvalidComposite, healthcheck.CompositeRule)
+	}
+
+	seen := make(map[int]bool)
+	for _, member := range healthcheck.Members {
+		if member.Weight < 0 {
+			return fmt.Errorf("%w: negative weight for member %d", ErrInvalidComposite, member.MemberID)
+		}
+		if seen[member.MemberID] {
+			return fmt.Errorf("%w: duplicate member %d", ErrInvalidComposite, member.MemberID)
+		}
+		seen[member.MemberID] = true
+		if member.MemberID < 0 {
+			continue
+		}
+		if _, err := cs.healthcheckRepo.InProject(healthcheck.ProjectID).FindOne(ctx, member.MemberID); err != nil {
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				return fmt.Errorf("%w: member %d not found", ErrInvalidComposite, member.MemberID)
+			}
+			return err
+		}
+	}
+
+	if healthcheck.ID == 0 {
+		// a new composite can't be a member of any other composite yet, so it can't be part of a cycle.
+		return nil
+	}
+
+	all, err := cs.healthcheckRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	members := make(map[int][]int)
+	for _, h := range all {
+		for _, member := range h.Members {
+			members[h.ID] = append(members[h.ID], member.MemberID)
+		}
+	}
+	members[healthcheck.ID] = nil
+	for _, member := range healthcheck.Members {
+		members[healthcheck.ID] = append(members[healthcheck.ID], member.MemberID)
+	}
+
+	if cycle := findCycle(members, healthcheck.ID); cycle != nil {
+		return fmt.Errorf("%w: membership cycle %v", ErrInvalidComposite, cycle)
+	}
+
+	return nil
+}
+
+func (cs *compositeService) ValidateGraph(ctx context.Context, members map[int][]int, deleted []int) error {
+	isDeleted := make(map[int]bool, len(deleted))
+	for _, id := range deleted {
+		isDeleted[id] = true
+	}
+
+	all, err := cs.healthcheckRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	graph := make(map[int][]int)
+	for _, h := range all {
+		if _, replaced := members[h.ID]; replaced || isDeleted[h.ID] {
+			continue
+		}
+		for _, member := range h.Members {
+			if !isDeleted[member.MemberID] {
+				graph[h.ID] = append(graph[h.ID], member.MemberID)
+			}
+		}
+	}
+	ids := make([]int, 0, len(members))
+	for id, memberIDs := range members {
+		graph[id] = memberIDs
+		ids = append(ids, id)
+	}
+
+	// a cycle through a replaced healthcheck is found starting from it, the others were there before.
+	sort.Ints(ids)
+	for _, id := range ids {
+		if cycle := findCycle(graph, id); cycle != nil {
+			return fmt.Errorf("%w: membership cycle %v", ErrInvalidComposite, cycle)
+		}
+	}
+
+	return nil
+}
+
+func (cs *compositeService) Evaluate(ctx context.Context, healthcheck repository.Healthcheck) (string, string, error) {
+	up := 0
+	var upWeight, totalWeight float64
+	for _, member := range healthcheck.Members {
+		totalWeight += member.Weight
+
+		event, err := cs.healthcheckEventRepo.FindLast(ctx, member.MemberID)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if event.State == repository.StateUp {
+			up++
+			upWeight += member.Weight
+		}
+	}
+
+	var healthy bool
+	status := fmt.Sprintf("%d/%d members up", up, len(healthcheck.Members))
+	switch healthcheck.CompositeRule {
+	case repository.CompositeRuleAll:
+		healthy = up == len(healthcheck.Members)
+	case repository.CompositeRuleAny:
+		healthy = up > 0
+	case repository.CompositeRuleAtLeast:
+		healthy = up >= healthcheck.CompositeMinUp
+	case repository.CompositeRuleWeighted:
+		var score float64
+		if totalWeight > 0 {
+			score = upWeight / totalWeight
+		}
+		healthy = score >= healthcheck.CompositeThreshold
+		status = fmt.Sprintf("%s, score %.2f", status, score)
+	default:
+		return "", "", fmt.Errorf("%w: unknown rule %q", ErrInvalidComposite, healthcheck.CompositeRule)
+	}
+
+	if healthy {
+		return status, repository.StateUp, nil
+	}
+	return status, repository.StateDown, nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+var (
+	// ErrDependencyCycle indicates the requested dependencies would make the dependency graph cyclic.
+	ErrDependencyCycle = errors.New("dependency cycle detected")
+	// ErrDependencyNotFound indicates one of the requested dependencies does not exist.
+	ErrDependencyNotFound = errors.New("dependency not found")
+)
+
+// DependencyGraph is the part of the dependency graph reachable from a healthcheck, in both directions.
+type DependencyGraph struct {
+	HealthcheckID int                                `json:"healthcheckId"`
+	DependsOn     []int                              `json:"dependsOn"`
+	Dependents    []int                              `json:"dependents"`
+	Edges         []repository.HealthcheckDependency `json:"edges"`
+}
+
+type DependencyService interface {
+	Validate(ctx context.Context, projectID, healthcheckID int, dependsOn []int) error
+	// ValidateGraph checks the dependency graph has no cycle once the dependencies of the healthchecks in
+	// dependsOn are replaced and the deleted healthchecks are removed.
+	ValidateGraph(ctx context.Context, dependsOn map[int][]int, deleted []int) error
+	Save(ctx context.Context, healthcheckID int, dependsOn []int) error
+	Graph(ctx context.Context, healthcheckID int) (DependencyGraph, error)
+	// FindDownParent returns the first parent of the healthcheck which is not up, if any.
+	FindDownParent(ctx context.Context, healthcheckID int) (int, bool, error)
+}
+
+type dependencyService struct {
+	healthcheckRepo      repository.HealthcheckRepo
+	healthcheckEventRepo repository.HealthcheckEventRepo
+	dependencyRepo       repository.HealthcheckDependencyRepo
+}
+
+var _ DependencyService = &dependencyService{}
+
+func NewDependencyService(healthcheckRepo repository.HealthcheckRepo,
+	healthcheckEventRepo repository.HealthcheckEventRepo,
+	dependencyRepo repository.HealthcheckDependencyRepo) DependencyService {
+	return &dependencyService{
+		healthcheckRepo:      healthcheckRepo,
+		healthcheckEventRepo: healthcheckEventRepo,
+		dependencyRepo:       dependencyRepo,
+	}
+}
+
+// Validate checks the dependencies exist in the project and that adding them won't create a cycle.
+// healthcheckID is zero for healthchecks which are not saved yet, the dependencies with negative ids are
+// planned to be created with it and aren't looked up.
+func (ds *dependencyService) Validate(ctx context.Context, projectID, healthcheckID int, dependsOn []int) error {
+	for _, parentID := range dependsOn {
+		if parentID == healthcheckID {
+			return fmt.Errorf("%w: healthcheck %d depends on itself", ErrDependencyCycle, parentID)
+		}
+		if parentID < 0 {
+			continue
+		}
+		if _, err := ds.healthcheckRepo.InProject(projectID).FindOne(ctx, parentID); err != nil {
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				return fmt.Errorf("%w: healthcheck %d", ErrDependencyNotFound, parentID)
+			}
+			return err
+		}
+	}
+
+	if healthcheckID == 0 {
+		// a new healthcheck has no dependents, so it can't be part of a cycle.
+		return nil
+	}
+
+	edges, err := ds.dependencyRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[int][]int)
+	for _, edge := range edges {
+		if edge.HealthcheckID == healthcheckID {
+			continue
+		}
+		parents[edge.HealthcheckID] = append(parents[edge.HealthcheckID], edge.DependsOnID)
+	}
+	parents[healthcheckID] = dependsOn
+
+	if cycle := findCycle(parents, healthcheckID); cycle != nil {
+		return fmt.Errorf("%w: %v", ErrDependencyCycle, cycle)
+	}
+
+	return nil
+}
+
+func (ds *dependencyService) ValidateGraph(ctx context.Context, dependsOn map[int][]int, deleted []int) error {
+	isDeleted := make(map[int]bool, len(deleted))
+	for _, id := range deleted {
+		isDeleted[id] = true
+	}
+
+	edges, err := ds.dependencyRepo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	parents := make(map[int][]int)
+	for _, edge := range edges {
+		if _, replaced := dependsOn[edge.HealthcheckID]; replaced || isDeleted[edge.HealthcheckID] ||
+			isDeleted[edge.DependsOnID] {
+			continue
+		}
+		parents[edge.HealthcheckID] = append(parents[edge.HealthcheckID], edge.DependsOnID)
+	}
+	ids := make([]int, 0, len(dependsOn))
+	for id, parentIDs := range dependsOn {
+		parents[id] = parentIDs
+		ids = append(ids, id)
+	}
+
+	// a cycle through a replaced healthcheck is found starting from it, the others were there before.
+	sort.Ints(ids)
+	for _, id := range ids {
+		if cycle := findCycle(parents, id); cycle != nil {
+			return fmt.Errorf("%w: %v", ErrDependencyCycle, cycle)
+		}
+	}
+
+	return nil
+}
+
+// findCycle runs a depth first search from start and returns the first cycle found as a path.
+func findCycle(parents map[int][]int, start int) []int {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[int]int)
+	var path []int
+
+	var visit func(node int) []int
+	visit = func(node int) []int {
+		marks[node] = visiting
+		path = append(path, node)
+		for _, parent := range parents[node] {
+			switch marks[parent] {
+			case visiting:
+				for i, n := range path {
+					if n == parent {
+						return append(append([]int{}, path[i:]...), parent)
+					}
+				}
+			case unvisited:
+				if cycle := visit(parent); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		marks[node] = visited
+		return nil
+	}
+
+	return visit(start)
+}
+
+func (ds *dependencyService) Save(ctx context.Context, healthcheckID int, dependsOn []int) error {
+	return ds.dependencyRepo.Save(ctx, healthcheckID, dependsOn)
+}
+
+func (ds *dependencyService) Graph(ctx context.Context, healthcheckID int) (DependencyGraph, error) {
+	graph := DependencyGraph{HealthcheckID: healthcheckID, DependsOn: []int{}, Dependents: []int{}}
+
+	edges, err := ds.dependencyRepo.FindAll(ctx)
+	if err != nil {
+		return graph, err
+	}
+
+	parents := make(map[int][]repository.HealthcheckDependency)
+	children := make(map[int][]repository.HealthcheckDependency)
+	for _, edge := range edges {
+		parents[edge.HealthcheckID] = append(parents[edge.HealthcheckID], edge)
+		children[edge.DependsOnID] = append(children[edge.DependsOnID], edge)
+	}
+
+	for _, edge := range parents[healthcheckID] {
+		graph.DependsOn = append(graph.DependsOn, edge.DependsOnID)
+	}
+	for _, edge := range children[healthcheckID] {
+		graph.Dependents = append(graph.Dependents, edge.HealthcheckID)
+	}
+
+	graph.Edges = append(
+		collectEdges(healthcheckID, parents, func(e repository.HealthcheckDependency) int { return e.DependsOnID }),
+		collectEdges(healthcheckID, children, func(e repository.HealthcheckDependency) int { return e.HealthcheckID })...,
+	)
+
+	return graph, nil
+}
+
+// collectEdges walks the adjacency list from start and returns every edge it passes.
+func collectEdges(start int, adjacency map[int][]repository.HealthcheckDependency,
+	next func(repository.HealthcheckDependency) int) []repository.HealthcheckDependency {
+	result := []repository.HealthcheckDependency{}
+	seen := map[int]bool{start: true}
+	queue := []int{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, edge := range adjacency[node] {
+			result = append(result, edge)
+			if n := next(edge); !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+
+	return result
+}
+
+func (ds *dependencyService) FindDownParent(ctx context.Context, healthcheckID int) (int, bool, error) {
+	parentIDs, err := ds.dependencyRepo.FindParents(ctx, healthcheckID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, parentID := range parentIDs {
+		event, err := ds.healthcheckEventRepo.FindLast(ctx, parentID)
+		if errors.Is(err, repository.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, false, err
+		}
+		if event.State != repository.StateUp {
+			return parentID, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
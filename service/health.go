@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// checkOK is the result of the readiness checks which passed.
+const checkOK = "ok"
+
+// Readiness is the result of the readiness checks, each check is "ok" or the reason it failed. Warnings
+// are the problems of the checks which don't fail the readiness.
+type Readiness struct {
+	Ready    bool              `json:"ready"`
+	Checks   map[string]string `json:"checks"`
+	Warnings map[string]string `json:"warnings,omitempty"`
+}
+
+// HealthService checks the health of the service itself.
+type HealthService interface {
+	// Ready checks the database is reachable, its schema is at the expected version and the scheduler
+	// is running. Stalled healthchecks are warnings, another instance wouldn't run them either.
+	Ready(ctx context.Context) Readiness
+}
+
+type healthService struct {
+	schemaRepo      repository.SchemaRepo
+	watchdogService WatchdogService
+	schemaVersion   uint
+}
+
+var _ HealthService = &healthService{}
+
+// NewHealthService creates a HealthService, schemaVersion is the migration version the database is
+// expected to be at.
+func NewHealthService(schemaRepo repository.SchemaRepo,
+	watchdogService WatchdogService,
+	schemaVersion uint) HealthService {
+	return &healthService{
+		schemaRepo:      schemaRepo,
+		watchdogService: watchdogService,
+		schemaVersion:   schemaVersion,
+	}
+}
+
+func (hs *healthService) Ready(ctx context.Context) Readiness {
+	readiness := Readiness{
+		Ready: true,
+		Checks: map[string]string{
+			"database":   checkOK,
+			"migrations": checkOK,
+			"scheduler":  checkOK,
+		},
+	}
+	fail := func(check string, err error) {
+		readiness.Ready = false
+		readiness.Checks[check] = err.Error()
+	}
+
+	if err := hs.schemaRepo.Ping(ctx); err != nil {
+		fail("database", err)
+		fail("migrations", errors.New("database is unreachable"))
+	} else if err := hs.checkMigrations(ctx); err != nil {
+		fail("migrations", err)
+	}
+
+	if err := hs.watchdogService.Alive(); err != nil {
+		fail("scheduler", err)
+	} else if stalled := hs.watchdogService.Report().Stalled; len(stalled) > 0 {
+		readiness.Warnings = map[string]string{"scheduler": fmt.Sprintf("healthchecks %v stalled", stalled)}
+	}
+
+	return readiness
+}
+
+func (hs *healthService) checkMigrations(ctx context.Context) error {
+	version, dirty, err := hs.schemaRepo.FindVersion(ctx)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return errors.New("no migrations are applied")
+	}
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", version)
+	}
+	if version != hs.schemaVersion {
+		return fmt.Errorf("database is at version %d, expected %d", version, hs.schemaVersion)
+	}
+	return nil
+}
@@ -3,12 +3,12 @@ package service
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 	"github.com/therealak12/api-health-check/config"
+	"github.com/therealak12/api-health-check/tracing"
 	"net/http"
 	"sync"
 	"time"
@@ -39,16 +39,41 @@ func (c *cancelMap) Get(key int) (value context.CancelFunc, ok bool) {
 	return result, ok
 }
 
-func (c *cancelMap) Set(key int, value context.CancelFunc) {
+// SetIfAbsent sets the value if the key isn't set and reports whether it did.
+func (c *cancelMap) SetIfAbsent(key int, value context.CancelFunc) bool {
 	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.funcs[key]; ok {
+		return false
+	}
 	c.funcs[key] = value
-	c.Unlock()
+	return true
 }
 
-func (c *cancelMap) Delete(key int) {
+// Take deletes the key and returns its value.
+func (c *cancelMap) Take(key int) (value context.CancelFunc, ok bool) {
 	c.Lock()
+	defer c.Unlock()
+	value, ok = c.funcs[key]
 	delete(c.funcs, key)
-	c.Unlock()
+	return value, ok
+}
+
+func (c *cancelMap) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.funcs)
+}
+
+func (c *cancelMap) Keys() []int {
+	c.Lock()
+	defer c.Unlock()
+
+	keys := make([]int, 0, len(c.funcs))
+	for key := range c.funcs {
+		keys = append(keys, key)
+	}
+	return keys
 }
 
 var (
@@ -56,30 +81,58 @@ var (
 )
 
 type HealthcheckService interface {
-	StartHealthCheck(healthcheckID int) error
-	StoptHealthCheck(healthcheckID int) error
+	StartHealthCheck(ctx context.Context, healthcheckID int) error
+	StoptHealthCheck(ctx context.Context, healthcheckID int) error
+	// Ping records a ping of the heartbeat healthcheck with the given token.
+	Ping(ctx context.Context, token string, ping Ping) error
+	// Reschedule restarts a running healthcheck with its saved definition, previous is the definition it
+	// was started with. It does nothing if the healthcheck isn't running. If the saved definition can't
+	// be started, previous is started again and the error is returned.
+	Reschedule(ctx context.Context, previous repository.Healthcheck) error
+	// NextRun returns the time of the next run of a started healthcheck.
+	NextRun(healthcheckID int) (time.Time, bool)
+	// Running returns the ids of the started healthchecks.
+	Running() []int
+	// Forget stops a deleted healthcheck if it's running, drops its events which aren't written yet and
+	// deletes its metrics.
+	Forget(healthcheck repository.Healthcheck)
+	// Run runs the healthcheck once, which may be unsaved. Its event is recorded and alerts are sent
+	// only if record is true.
+	Run(ctx context.Context, healthcheck repository.Healthcheck, record bool) (RunResult, error)
 }
 
 type healthcheckService struct {
 	healthcheckRepo      repository.HealthcheckRepo
 	healthcheckEventRepo repository.HealthcheckEventRepo
+	dependencyService    DependencyService
+	compositeService     CompositeService
+	projectRepo          repository.ProjectRepo
 	webhookConfig        config.Webhook
+	tracer               *tracing.Tracer
 }
 
 var _ HealthcheckService = &healthcheckService{}
 
 func NewHealthcheckService(healthcheckRepo repository.HealthcheckRepo,
 	healthcheckEventRepo repository.HealthcheckEventRepo,
-	webhookConfig config.Webhook) HealthcheckService {
+	dependencyService DependencyService,
+	compositeService CompositeService,
+	projectRepo repository.ProjectRepo,
+	webhookConfig config.Webhook,
+	tracer *tracing.Tracer) HealthcheckService {
 	return &healthcheckService{
 		healthcheckRepo:      healthcheckRepo,
 		healthcheckEventRepo: healthcheckEventRepo,
+		dependencyService:    dependencyService,
+		compositeService:     compositeService,
+		projectRepo:          projectRepo,
 		webhookConfig:        webhookConfig,
+		tracer:               tracer,
 	}
 }
 
-func (hs *healthcheckService) StartHealthCheck(healthcheckID int) error {
-	healthcheck, err := hs.healthcheckRepo.FindOne(healthcheckID)
+func (hs *healthcheckService) StartHealthCheck(ctx context.Context, healthcheckID int) error {
+	healthcheck, err := hs.healthcheckRepo.FindOne(ctx, healthcheckID)
 	if err == repository.ErrRecordNotFound {
 		return echo.NewHTTPError(http.StatusNotFound)
 	}
@@ -87,77 +140,74 @@ func (hs *healthcheckService) StartHealthCheck(healthcheckID int) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck")
 	}
 
-	ticker := time.NewTicker(time.Duration(healthcheck.IntervalSeconds) * time.Second)
-
-	httpClient := http.Client{Timeout: healthcheckDefaultTimeout * time.Second}
+	return hs.start(healthcheck)
+}
 
-	req, err := http.NewRequest(
-		healthcheck.HttpMethod,
-		healthcheck.Url,
-		bytes.NewBuffer([]byte(healthcheck.Body)),
+// start starts the healthcheck with the given definition, it fails with a conflict if it's running.
+func (hs *healthcheckService) start(healthcheck repository.Healthcheck) error {
+	healthcheckID := healthcheck.ID
+	var (
+		err      error
+		check    checkFunc
+		schedule Schedule
 	)
-	if err != nil {
-		return err
-	}
-
-	var headers map[string]string
-	if err := json.Unmarshal([]byte(healthcheck.HeadersJson), &headers); err != nil {
-		return err
-	}
-	for key, value := range headers {
-		req.Header.Add(key, value)
-	}
-
-	checkAPIHealth := func() {
-		resp, err := httpClient.Do(req)
-		var status string
+	switch healthcheck.Kind {
+	case repository.KindComposite:
+		check = hs.newCompositeCheck(healthcheck)
+	case repository.KindHeartbeat:
+		check = hs.newHeartbeatCheck(healthcheck)
+		interval := time.Duration(healthcheck.IntervalSeconds) * time.Second
+		if interval > heartbeatCheckInterval {
+			interval = heartbeatCheckInterval
+		}
+		schedule = everySchedule(interval)
+	default:
+		check, err = hs.newHTTPCheck(healthcheck, 0)
 		if err != nil {
-			logrus.Warnf("failed to make healthcheck request, err: %s", err)
-			status = fmt.Sprintf("healthcheck failed, err: %s", err)
-		} else {
-			status = resp.Status
-			//goland:noinspection GoUnhandledErrorResult
-			defer resp.Body.Close()
-		}
-		healthcheckEvent := repository.HealthcheckEvent{
-			HealthcheckID: healthcheckID,
-			Status:        status,
-			CreatedAt:     time.Time{},
-		}
-		lastHealthcheckEvent, err := hs.healthcheckEventRepo.FindLast()
-		if err != nil && err != repository.ErrRecordNotFound {
-			logrus.Errorf("failed to get last healthcheck event, err: %s", err)
-			return
+			return err
 		}
-		if err == repository.ErrRecordNotFound {
-			if err := hs.healthcheckEventRepo.Create(&healthcheckEvent); err != nil {
-				logrus.Errorf("failed to create healthcheck event, err: %s", err)
-				return
-			}
-		} else {
-			if differs := hs.compareHealthcheckEvents(&lastHealthcheckEvent, &healthcheckEvent); differs {
-				if err := hs.sendHealthStatusAlert(&lastHealthcheckEvent, &healthcheckEvent); err != nil {
-					logrus.Errorf("failed to send healthcheck alert, err: %s", err)
-				}
-			}
-			if err := hs.healthcheckEventRepo.Create(&healthcheckEvent); err != nil {
-				logrus.Errorf("failed to create healthcheck event, err: %s", err)
-				return
-			}
+	}
+	if schedule == nil {
+		schedule, err = NewSchedule(healthcheck)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
 	}
 
+	// the healthcheck runs until it's stopped, not until the context of the caller is done.
 	ctx, cancelFunc := context.WithCancel(context.Background())
-	healthchecks.Set(healthcheck.ID, cancelFunc)
+	if !healthchecks.SetIfAbsent(healthcheck.ID, cancelFunc) {
+		cancelFunc()
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("the health check %d is already started", healthcheckID))
+	}
+	generation := schedules.Start(healthcheck.ID)
 	go func() {
+		next := schedule.Next(time.Now())
 		for {
+			schedules.Scheduled(healthcheckID, generation, next)
+			// a schedule with no next run waits until the healthcheck is stopped.
+			var (
+				timer *time.Timer
+				fired <-chan time.Time
+			)
+			if !next.IsZero() {
+				timer = time.NewTimer(time.Until(next))
+				fired = timer.C
+			}
+
 			select {
 			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
 				logrus.Debugf("stopping health check, id: %d", healthcheckID)
-				break
-			case <-ticker.C:
+				return
+			case <-fired:
 				logrus.Debugf("checking api health, id: %d", healthcheckID)
-				checkAPIHealth()
+				healthcheckSchedulerLag.With(userLabels(healthcheck)).Set(time.Since(next).Seconds(),
+					healthcheckLabels(healthcheck)...)
+				hs.runCheck(ctx, healthcheck, check)
+				next = schedule.Next(time.Now())
 			}
 		}
 	}()
@@ -165,16 +215,244 @@ func (hs *healthcheckService) StartHealthCheck(healthcheckID int) error {
 	return nil
 }
 
+func (hs *healthcheckService) NextRun(healthcheckID int) (time.Time, bool) {
+	s, ok := schedules.Get(healthcheckID)
+	if !ok || s.NextRunAt.IsZero() {
+		return time.Time{}, false
+	}
+	return s.NextRunAt, true
+}
+
+func (hs *healthcheckService) Running() []int {
+	return healthchecks.Keys()
+}
+
+// checkResult is the outcome of a single run of a healthcheck.
+type checkResult struct {
+	Status     string
+	State      string
+	StatusCode int
+	Latency    repository.Latency
+	CertExpiry time.Time
+	// Header and Body are the response of http healthchecks, the body is kept only for ad-hoc runs.
+	Header        http.Header
+	Body          []byte
+	BodyTruncated bool
+	Assertions    []AssertionResult
+}
+
+// AssertionResult is the outcome of one of the rules the state of a healthcheck is decided by.
+type AssertionResult struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// checkFunc runs a single check.
+// An error means the check couldn't be evaluated and no event should be recorded, errSkipRun means there
+// is nothing to record.
+type checkFunc func(ctx context.Context) (checkResult, error)
+
+var errSkipRun = errors.New("skip run")
+
+// newHTTPCheck creates the check of a http healthcheck, up to bodyLimit bytes of the response body are
+// kept in the result.
+func (hs *healthcheckService) newHTTPCheck(healthcheck repository.Healthcheck, bodyLimit int64) (checkFunc, error) {
+	httpClient := &http.Client{
+		Timeout:   healthcheckDefaultTimeout * time.Second,
+		Transport: &tracing.Transport{Tracer: hs.tracer},
+	}
+
+	// build the request once to report invalid healthchecks when starting them.
+	if _, err := newHealthcheckRequest(healthcheck); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) (checkResult, error) {
+		req, err := newHealthcheckRequest(healthcheck)
+		if err != nil {
+			return checkResult{}, err
+		}
+
+		result := probe(httpClient, req.WithContext(ctx), bodyLimit)
+		check := checkResult{
+			Status:        result.Status,
+			State:         repository.StateDown,
+			StatusCode:    result.StatusCode,
+			Latency:       result.Latency,
+			CertExpiry:    result.CertExpiry,
+			Header:        result.Header,
+			Body:          result.Body,
+			BodyTruncated: result.BodyTruncated,
+		}
+
+		requestAssertion := AssertionResult{Name: "request succeeded", Passed: result.Err == nil}
+		if result.Err != nil {
+			logrus.Warnf("failed to make healthcheck request, err: %s", result.Err)
+			check.Status = fmt.Sprintf("healthcheck failed, err: %s", result.Err)
+			requestAssertion.Message = result.Err.Error()
+		}
+		statusAssertion := AssertionResult{
+			Name:   "status code is 2xx or 3xx",
+			Passed: result.StatusCode >= http.StatusOK && result.StatusCode < http.StatusBadRequest,
+		}
+		if len(healthcheck.ValidStatusCodes) > 0 {
+			statusAssertion.Name = fmt.Sprintf("status code is one of %v", []int(healthcheck.ValidStatusCodes))
+			statusAssertion.Passed = validStatusCode(healthcheck.ValidStatusCodes, result.StatusCode)
+		}
+		if result.StatusCode != 0 {
+			statusAssertion.Message = fmt.Sprintf("got %d", result.StatusCode)
+		}
+		check.Assertions = []AssertionResult{requestAssertion, statusAssertion}
+
+		if requestAssertion.Passed && statusAssertion.Passed {
+			check.State = repository.StateUp
+		}
+		return check, nil
+	}, nil
+}
+
+func validStatusCode(validStatusCodes []int, statusCode int) bool {
+	for _, code := range validStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+func (hs *healthcheckService) newCompositeCheck(healthcheck repository.Healthcheck) checkFunc {
+	return func(ctx context.Context) (checkResult, error) {
+		status, state, err := hs.compositeService.Evaluate(ctx, healthcheck)
+		return checkResult{
+			Status: status,
+			State:  state,
+			Assertions: []AssertionResult{{
+				Name:    fmt.Sprintf("composite rule %s", healthcheck.CompositeRule),
+				Passed:  state == repository.StateUp,
+				Message: status,
+			}},
+		}, err
+	}
+}
+
+// runCheck runs the check and records its result, each run is traced. The run is canceled with the context
+// of the started healthcheck and the result of a canceled run is dropped, the healthcheck may be deleted.
+func (hs *healthcheckService) runCheck(ctx context.Context, healthcheck repository.Healthcheck, check checkFunc) {
+	healthcheckID := healthcheck.ID
+	ctx, span := hs.tracer.Start(ctx, "healthcheck.run", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttribute("healthcheck.id", healthcheckID)
+	span.SetAttribute("healthcheck.kind", healthcheck.Kind)
+
+	result, err := check(ctx)
+	if errors.Is(err, errSkipRun) {
+		return
+	}
+	if err != nil {
+		logrus.Errorf("failed to run healthcheck %d, err: %s", healthcheckID, err)
+		span.SetError(err)
+		return
+	}
+	if ctx.Err() != nil {
+		logrus.Debugf("dropping the result of stopped health check, id: %d", healthcheckID)
+		return
+	}
+	hs.record(ctx, healthcheck, result)
+}
+
+// record records the event of a check result and alerts if the status has changed. The trace id of the
+// span in the context is stored on the event.
+func (hs *healthcheckService) record(ctx context.Context, healthcheck repository.Healthcheck, result checkResult) {
+	healthcheckID := healthcheck.ID
+	span := tracing.SpanFromContext(ctx)
+	observeCheck(healthcheck, result)
+	status, state := result.Status, result.State
+	if state == repository.StateDown {
+		parentID, down, err := hs.dependencyService.FindDownParent(ctx, healthcheckID)
+		if err != nil {
+			logrus.Errorf("failed to check healthcheck dependencies, err: %s", err)
+		} else if down {
+			state = repository.StateUnreachable
+			status = fmt.Sprintf("unreachable due to dependency %d, %s", parentID, status)
+		}
+	}
+	span.SetAttribute("healthcheck.state", state)
+	healthcheckEvent := repository.HealthcheckEvent{
+		HealthcheckID: healthcheckID,
+		Status:        status,
+		State:         state,
+		StatusCode:    result.StatusCode,
+		Latency:       result.Latency,
+		Revision:      healthcheck.Version,
+		CreatedAt:     time.Time{},
+	}
+	if traceID := span.Context().TraceID; traceID.IsValid() {
+		healthcheckEvent.TraceID = traceID.String()
+	}
+	lastHealthcheckEvent, err := hs.healthcheckEventRepo.FindLast(ctx, healthcheckID)
+	if err != nil && err != repository.ErrRecordNotFound {
+		logrus.Errorf("failed to get last healthcheck event, err: %s", err)
+		return
+	}
+	// a run of a previous definition may finish after the healthcheck is rescheduled, it mustn't alert or
+	// record an event next to the runs of the current one.
+	if ctx.Err() != nil {
+		return
+	}
+	if err == repository.ErrRecordNotFound {
+		if err := hs.healthcheckEventRepo.Create(ctx, &healthcheckEvent); err != nil {
+			logrus.Errorf("failed to create healthcheck event, err: %s", err)
+			return
+		}
+	} else {
+		if differs := hs.compareHealthcheckEvents(&lastHealthcheckEvent, &healthcheckEvent); differs {
+			if err := hs.sendHealthStatusAlert(ctx, healthcheck, &lastHealthcheckEvent, &healthcheckEvent); err != nil {
+				logrus.Errorf("failed to send healthcheck alert, err: %s", err)
+				notificationFailures.Inc()
+			} else {
+				notificationsSent.Inc()
+			}
+		}
+		if err := hs.healthcheckEventRepo.Create(ctx, &healthcheckEvent); err != nil {
+			logrus.Errorf("failed to create healthcheck event, err: %s", err)
+			return
+		}
+	}
+}
+
+// compareHealthcheckEvents reports whether an alert should be sent for the new event.
+// Alerts of healthchecks which are unreachable due to a dependency are suppressed,
+// the dependency sends its own alert.
 func (hs *healthcheckService) compareHealthcheckEvents(lastHealthcheckEvent, healthcheckEvent *repository.HealthcheckEvent) bool {
+	if healthcheckEvent.State == repository.StateUnreachable {
+		return false
+	}
 	return lastHealthcheckEvent.Status != healthcheckEvent.Status
 }
 
-func (hs *healthcheckService) sendHealthStatusAlert(lastHealthcheckEvent, healthcheckEvent *repository.HealthcheckEvent) error {
-	httpClient := http.Client{Timeout: webhookDefaultTimeout * time.Second}
+// sendHealthStatusAlert sends the alert to the webhook of the project of the healthcheck, or to the
+// configured one if the project has none.
+func (hs *healthcheckService) sendHealthStatusAlert(ctx context.Context, healthcheck repository.Healthcheck,
+	lastHealthcheckEvent, healthcheckEvent *repository.HealthcheckEvent) error {
+	httpClient := http.Client{
+		Timeout:   webhookDefaultTimeout * time.Second,
+		Transport: &tracing.Transport{Tracer: hs.tracer},
+	}
+
+	url := hs.webhookConfig.Url
+	project, err := hs.projectRepo.FindOne(ctx, healthcheck.ProjectID)
+	if err != nil {
+		return err
+	}
+	if project.WebhookURL != "" {
+		url = project.WebhookURL
+	}
 
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
-		hs.webhookConfig.Url,
+		url,
 		bytes.NewBuffer([]byte(fmt.Sprintf(
 			`{"%s":"health status changed, was %s and is %s"}`,
 			hs.webhookConfig.MessageFieldName,
@@ -186,25 +464,63 @@ func (hs *healthcheckService) sendHealthStatusAlert(lastHealthcheckEvent, health
 		return err
 	}
 
-	_, err = httpClient.Do(req)
-	return err
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
 }
 
-func (hs *healthcheckService) StoptHealthCheck(healthcheckID int) error {
-	_, err := hs.healthcheckRepo.FindOne(healthcheckID)
+func (hs *healthcheckService) StoptHealthCheck(ctx context.Context, healthcheckID int) error {
+	healthcheck, err := hs.healthcheckRepo.FindOne(ctx, healthcheckID)
 	if err == repository.ErrRecordNotFound {
 		return echo.NewHTTPError(http.StatusNotFound)
 	}
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get healthcheck")
 	}
-	cancelFunc, ok := healthchecks.Get(healthcheckID)
+	if !hs.stop(healthcheck) {
+		return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("the health check %d is not started yet", healthcheckID))
+	}
+
+	return nil
+}
+
+// stop stops the healthcheck and reports whether it was running.
+func (hs *healthcheckService) stop(healthcheck repository.Healthcheck) bool {
+	cancelFunc, ok := healthchecks.Take(healthcheck.ID)
 	if !ok {
-		return errors.New(fmt.Sprintf("the health check %d is not started yet", healthcheckID))
+		return false
 	}
 
 	cancelFunc()
-	healthchecks.Delete(healthcheckID)
+	schedules.Delete(healthcheck.ID)
+	forgetHealthcheckMetrics(healthcheck)
 
-	return nil
+	return true
+}
+
+func (hs *healthcheckService) Forget(healthcheck repository.Healthcheck) {
+	hs.stop(healthcheck)
+	hs.healthcheckEventRepo.Discard(healthcheck.ID)
+	deleteHealthcheckMetrics(healthcheck)
+}
+
+func (hs *healthcheckService) Reschedule(ctx context.Context, previous repository.Healthcheck) error {
+	if !hs.stop(previous) {
+		return nil
+	}
+	err := hs.StartHealthCheck(ctx, previous.ID)
+	if err != nil {
+		if restartErr := hs.start(previous); restartErr != nil {
+			logrus.Errorf("failed to restart the previous definition of healthcheck %d: %s", previous.ID, restartErr)
+		}
+	}
+	return err
 }
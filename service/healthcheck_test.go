@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// eventRecorder keeps the created events of a healthcheck which has none yet, found is called when the last
+// event is looked up.
+type eventRecorder struct {
+	repository.HealthcheckEventRepo
+	events []repository.HealthcheckEvent
+	found  func()
+}
+
+func (r *eventRecorder) FindLast(context.Context, int) (repository.HealthcheckEvent, error) {
+	if r.found != nil {
+		r.found()
+	}
+	return repository.HealthcheckEvent{}, repository.ErrRecordNotFound
+}
+
+func (r *eventRecorder) Create(_ context.Context, healthcheckEvent *repository.HealthcheckEvent) error {
+	r.events = append(r.events, *healthcheckEvent)
+	return nil
+}
+
+func TestRunCheckDropsResultsOfStoppedHealthchecks(t *testing.T) {
+	tests := []struct {
+		name string
+		// stoppedAt is the step the healthcheck is stopped at.
+		stoppedAt string
+		events    int
+	}{
+		{"running", "", 1},
+		{"stopped during the run", "check", 0},
+		{"rescheduled while recording", "record", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			events := &eventRecorder{}
+			if test.stoppedAt == "record" {
+				events.found = cancelFunc
+			}
+			hs := &healthcheckService{healthcheckEventRepo: events}
+
+			healthcheck := repository.Healthcheck{ID: 1, Kind: repository.KindHTTP}
+			hs.runCheck(ctx, healthcheck, func(ctx context.Context) (checkResult, error) {
+				if test.stoppedAt == "check" {
+					cancelFunc()
+				}
+				return checkResult{Status: "200 OK", State: repository.StateUp}, nil
+			})
+			if len(events.events) != test.events {
+				t.Errorf("events are %+v, want %d", events.events, test.events)
+			}
+		})
+	}
+}
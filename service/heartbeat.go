@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/therealak12/api-health-check/config"
+)
+
+// HeartbeatService pings an external dead man's switch, e.g. healthchecks.io, while the healthchecks are
+// running. The external system alerts when the pings stop, so a hung or dead monitor is noticed.
+type HeartbeatService interface {
+	// Start pings every configured interval until the context is done.
+	Start(ctx context.Context)
+	// Beat pings the heartbeat url if the scheduler is verifiably executing healthchecks.
+	Beat(ctx context.Context) error
+}
+
+type heartbeatService struct {
+	watchdogService WatchdogService
+	heartbeatConfig config.Heartbeat
+	httpClient      *http.Client
+}
+
+var _ HeartbeatService = &heartbeatService{}
+
+func NewHeartbeatService(watchdogService WatchdogService, heartbeatConfig config.Heartbeat) HeartbeatService {
+	return &heartbeatService{
+		watchdogService: watchdogService,
+		heartbeatConfig: heartbeatConfig,
+		httpClient:      &http.Client{Timeout: heartbeatConfig.Timeout},
+	}
+}
+
+func (hs *heartbeatService) Start(ctx context.Context) {
+	ticker := time.NewTicker(hs.heartbeatConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := hs.Beat(ctx); err != nil {
+			logrus.Warnf("skipped heartbeat, err: %s", err)
+		}
+	}
+}
+
+// Beat pings only if the watchdog is running, no healthcheck has stalled and at least one is running,
+// each running healthcheck has then finished the runs that were due before the stall timeout.
+func (hs *heartbeatService) Beat(ctx context.Context) error {
+	if err := hs.watchdogService.Verify(); err != nil {
+		return err
+	}
+	if hs.watchdogService.Report().Running == 0 {
+		return errors.New("no healthcheck is running")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, hs.heartbeatConfig.Url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hs.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to ping heartbeat url: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("heartbeat url responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// ErrInvalidLabelSelector indicates a label selector can't be parsed.
+var ErrInvalidLabelSelector = errors.New("invalid label selector")
+
+var setRequirementRegex = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+// ParseLabelSelector parses a comma separated list of label requirements, all of them must match. The
+// requirements are key=value (or key==value), key!=value, key in (v1,v2), key notin (v1,v2), key for the
+// labels which exist and !key for the ones which don't.
+func ParseLabelSelector(selector string) ([]repository.LabelRequirement, error) {
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+
+	parts, err := splitSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := make([]repository.LabelRequirement, 0, len(parts))
+	for _, part := range parts {
+		requirement, err := parseLabelRequirement(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// splitSelector splits the selector on the commas which aren't in the value lists.
+func splitSelector(selector string) ([]string, error) {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, fmt.Errorf("%w: nested parentheses", ErrInvalidLabelSelector)
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidLabelSelector)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidLabelSelector)
+	}
+	return append(parts, selector[start:]), nil
+}
+
+func parseLabelRequirement(part string) (repository.LabelRequirement, error) {
+	if part == "" {
+		return repository.LabelRequirement{}, fmt.Errorf("%w: empty requirement", ErrInvalidLabelSelector)
+	}
+
+	var requirement repository.LabelRequirement
+	if match := setRequirementRegex.FindStringSubmatch(part); match != nil {
+		requirement = repository.LabelRequirement{Key: match[1], Operator: match[2]}
+		for _, value := range strings.Split(match[3], ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				return requirement, fmt.Errorf("%w: empty value in %q", ErrInvalidLabelSelector, part)
+			}
+			requirement.Values = append(requirement.Values, value)
+		}
+	} else if i := strings.Index(part, "!="); i >= 0 {
+		requirement = repository.LabelRequirement{
+			Key:      strings.TrimSpace(part[:i]),
+			Operator: repository.LabelNotEquals,
+			Values:   []string{strings.TrimSpace(part[i+2:])},
+		}
+	} else if i := strings.Index(part, "="); i >= 0 {
+		requirement = repository.LabelRequirement{
+			Key:      strings.TrimSpace(part[:i]),
+			Operator: repository.LabelEquals,
+			Values:   []string{strings.TrimSpace(strings.TrimPrefix(part[i+1:], "="))},
+		}
+	} else if strings.HasPrefix(part, "!") {
+		requirement = repository.LabelRequirement{
+			Key:      strings.TrimSpace(part[1:]),
+			Operator: repository.LabelDoesNotExist,
+		}
+	} else {
+		requirement = repository.LabelRequirement{Key: part, Operator: repository.LabelExists}
+	}
+
+	if !isSelectorToken(requirement.Key) {
+		return requirement, fmt.Errorf("%w: invalid key in %q", ErrInvalidLabelSelector, part)
+	}
+	for _, value := range requirement.Values {
+		// the values of = and != may be empty.
+		if value != "" && !isSelectorToken(value) {
+			return requirement, fmt.Errorf("%w: invalid value in %q", ErrInvalidLabelSelector, part)
+		}
+	}
+	return requirement, nil
+}
+
+// isSelectorToken reports whether s is a non-empty key or value without spaces and operators.
+func isSelectorToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t=!(),")
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		want     []repository.LabelRequirement
+		valid    bool
+	}{
+		{"empty", "  ", nil, true},
+		{"equals", "env=prod", []repository.LabelRequirement{
+			{Key: "env", Operator: repository.LabelEquals, Values: []string{"prod"}},
+		}, true},
+		{"double equals", "env == prod", []repository.LabelRequirement{
+			{Key: "env", Operator: repository.LabelEquals, Values: []string{"prod"}},
+		}, true},
+		{"empty value", "env=", []repository.LabelRequirement{
+			{Key: "env", Operator: repository.LabelEquals, Values: []string{""}},
+		}, true},
+		{"not equals", "env!=dev", []repository.LabelRequirement{
+			{Key: "env", Operator: repository.LabelNotEquals, Values: []string{"dev"}},
+		}, true},
+		{"in", "team in (payments, auth)", []repository.LabelRequirement{
+			{Key: "team", Operator: repository.LabelIn, Values: []string{"payments", "auth"}},
+		}, true},
+		{"not in", "team notin(search)", []repository.LabelRequirement{
+			{Key: "team", Operator: repository.LabelNotIn, Values: []string{"search"}},
+		}, true},
+		{"exists", "tier", []repository.LabelRequirement{
+			{Key: "tier", Operator: repository.LabelExists},
+		}, true},
+		{"does not exist", "!tier", []repository.LabelRequirement{
+			{Key: "tier", Operator: repository.LabelDoesNotExist},
+		}, true},
+		{"all of them", "env=prod,team in (payments,auth),!legacy", []repository.LabelRequirement{
+			{Key: "env", Operator: repository.LabelEquals, Values: []string{"prod"}},
+			{Key: "team", Operator: repository.LabelIn, Values: []string{"payments", "auth"}},
+			{Key: "legacy", Operator: repository.LabelDoesNotExist},
+		}, true},
+		{"empty requirement", "env=prod,,tier", nil, false},
+		{"trailing comma", "env=prod,", nil, false},
+		{"unbalanced parentheses", "team in (payments", nil, false},
+		{"closing parenthesis first", "team in )payments(", nil, false},
+		{"nested parentheses", "team in ((payments))", nil, false},
+		{"empty value in list", "team in (payments,)", nil, false},
+		{"empty key", "=prod", nil, false},
+		{"space in key", "my env=prod", nil, false},
+		{"operator in value", "env=prod=eu", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseLabelSelector(test.selector)
+			if !test.valid {
+				if !errors.Is(err, ErrInvalidLabelSelector) {
+					t.Fatalf("error is %v, want %v", err, ErrInvalidLabelSelector)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("requirements are %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
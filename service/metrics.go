@@ -0,0 +1,104 @@
+package service
+
+import (
+	"regexp"
+	"runtime"
+	"strconv"
+
+	"github.com/therealak12/api-health-check/metrics"
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// the metrics of healthchecks are labelled by the id and the url of the healthcheck, and by its labels.
+var (
+	healthcheckUp = metrics.NewGaugeVec(metrics.Default, "healthcheck_up",
+		"Whether the last run of the healthcheck was up.", "id", "url")
+	healthcheckLastLatency = metrics.NewGaugeVec(metrics.Default, "healthcheck_last_latency_seconds",
+		"The latency of the last run of the healthcheck.", "id", "url")
+	healthcheckLastStatusCode = metrics.NewGaugeVec(metrics.Default, "healthcheck_last_status_code",
+		"The response status code of the last run of the healthcheck.", "id", "url")
+	healthcheckCertExpiry = metrics.NewGaugeVec(metrics.Default, "healthcheck_cert_expiry_timestamp_seconds",
+		"The expiry time of the TLS certificate of the healthcheck url.", "id", "url")
+	healthcheckRuns = metrics.NewCounterVec(metrics.Default, "healthcheck_runs_total",
+		"The number of runs of the healthcheck.", "id", "url")
+	healthcheckFailures = metrics.NewCounterVec(metrics.Default, "healthcheck_failures_total",
+		"The number of runs of the healthcheck which were not up.", "id", "url")
+	healthcheckProbeDuration = metrics.NewHistogramVec(metrics.Default, "healthcheck_probe_duration_seconds",
+		"The duration of the healthcheck requests.", metrics.DefaultBuckets, "id", "url")
+	healthcheckSchedulerLag = metrics.NewGaugeVec(metrics.Default, "healthcheck_scheduler_lag_seconds",
+		"The delay between the time a run of the healthcheck was scheduled and the time it started.", "id", "url")
+	notificationsSent = metrics.NewCounterVec(metrics.Default, "healthcheck_notifications_total",
+		"The number of health status alerts sent.")
+	notificationFailures = metrics.NewCounterVec(metrics.Default, "healthcheck_notification_failures_total",
+		"The number of health status alerts which failed to be sent.")
+	_ = metrics.NewGaugeFunc(metrics.Default, "healthcheck_running",
+		"The number of running healthchecks, each one has a goroutine.",
+		func() float64 { return float64(healthchecks.Len()) })
+	_ = metrics.NewGaugeFunc(metrics.Default, "go_goroutines",
+		"The number of goroutines that currently exist.",
+		func() float64 { return float64(runtime.NumGoroutine()) })
+)
+
+func healthcheckLabels(healthcheck repository.Healthcheck) []string {
+	return []string{strconv.Itoa(healthcheck.ID), healthcheck.Url}
+}
+
+// invalidLabelNameChars are the characters of healthcheck label keys which aren't valid in metric label
+// names.
+var invalidLabelNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// userLabels returns the labels of the healthcheck as metric labels, the keys are prefixed with label_ and
+// their invalid characters are replaced with _.
+func userLabels(healthcheck repository.Healthcheck) map[string]string {
+	labels := make(map[string]string, len(healthcheck.Labels))
+	for key, value := range healthcheck.Labels {
+		labels["label_"+invalidLabelNameChars.ReplaceAllString(key, "_")] = value
+	}
+	return labels
+}
+
+// observeCheck updates the metrics of a healthcheck with the result of a run.
+func observeCheck(healthcheck repository.Healthcheck, result checkResult) {
+	labels := healthcheckLabels(healthcheck)
+	extra := userLabels(healthcheck)
+
+	healthcheckRuns.With(extra).Inc(labels...)
+	if result.State == repository.StateUp {
+		healthcheckUp.With(extra).Set(1, labels...)
+	} else {
+		healthcheckUp.With(extra).Set(0, labels...)
+		healthcheckFailures.With(extra).Inc(labels...)
+	}
+
+	if healthcheck.Kind != repository.KindHTTP {
+		return
+	}
+	latency := result.Latency.TotalMs / 1000
+	healthcheckLastLatency.With(extra).Set(latency, labels...)
+	healthcheckProbeDuration.With(extra).Observe(latency, labels...)
+	healthcheckLastStatusCode.With(extra).Set(float64(result.StatusCode), labels...)
+	if !result.CertExpiry.IsZero() {
+		healthcheckCertExpiry.With(extra).Set(float64(result.CertExpiry.Unix()), labels...)
+	}
+}
+
+// forgetHealthcheckMetrics removes the gauges of a stopped healthcheck, the counters are kept.
+func forgetHealthcheckMetrics(healthcheck repository.Healthcheck) {
+	labels := healthcheckLabels(healthcheck)
+
+	healthcheckUp.Delete(labels...)
+	healthcheckLastLatency.Delete(labels...)
+	healthcheckLastStatusCode.Delete(labels...)
+	healthcheckCertExpiry.Delete(labels...)
+	healthcheckSchedulerLag.Delete(labels...)
+}
+
+// deleteHealthcheckMetrics removes all the series of a deleted healthcheck.
+func deleteHealthcheckMetrics(healthcheck repository.Healthcheck) {
+	labels := healthcheckLabels(healthcheck)
+
+	forgetHealthcheckMetrics(healthcheck)
+	healthcheckRuns.Delete(labels...)
+	healthcheckFailures.Delete(labels...)
+	healthcheckProbeDuration.Delete(labels...)
+}
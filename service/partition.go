@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/therealak12/api-health-check/repository"
+)
+
+const partitionInterval = 24 * time.Hour
+
+// PartitionService maintains the monthly partitions of the events table.
+type PartitionService interface {
+	// Start creates the future partitions now and every day until the context is done.
+	Start(ctx context.Context)
+	CreateFuture() error
+	// DropBefore drops the partitions which only hold events before the given time.
+	DropBefore(before time.Time) error
+	// FindExpired returns the partitions which only hold events before the given time.
+	FindExpired(before time.Time) ([]repository.EventPartition, error)
+}
+
+type partitionService struct {
+	partitionRepo repository.HealthcheckEventPartitionRepo
+	premakeMonths int
+}
+
+var _ PartitionService = &partitionService{}
+
+func NewPartitionService(partitionRepo repository.HealthcheckEventPartitionRepo, premakeMonths int) PartitionService {
+	return &partitionService{
+		partitionRepo: partitionRepo,
+		premakeMonths: premakeMonths,
+	}
+}
+
+func (ps *partitionService) Start(ctx context.Context) {
+	ticker := time.NewTicker(partitionInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := ps.CreateFuture(); err != nil {
+			logrus.Errorf("failed to create event partitions, err: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CreateFuture creates the partitions of the current month and the configured months ahead.
+func (ps *partitionService) CreateFuture() error {
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= ps.premakeMonths; i++ {
+		if err := ps.partitionRepo.Create(month.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ps *partitionService) FindExpired(before time.Time) ([]repository.EventPartition, error) {
+	partitions, err := ps.partitionRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []repository.EventPartition
+	for _, partition := range partitions {
+		if !partition.To.After(before) {
+			result = append(result, partition)
+		}
+	}
+
+	return result, nil
+}
+
+func (ps *partitionService) DropBefore(before time.Time) error {
+	partitions, err := ps.FindExpired(before)
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		logrus.Infof("dropping expired event partition %s", partition.Name)
+		if err := ps.partitionRepo.Drop(partition.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/tracing"
+)
+
+// heartbeatCheckInterval is the longest time between the checks of a heartbeat for missing pings, so they
+// are noticed soon after the deadline even if the period is long.
+const heartbeatCheckInterval = 10 * time.Second
+
+// heartbeatMissingStatus is the status of heartbeats with no ping within their period and grace.
+const heartbeatMissingStatus = "no ping received within the period and grace time"
+
+// ErrPingNotFound indicates no heartbeat healthcheck has the ping token.
+var ErrPingNotFound = errors.New("ping token not found")
+
+// Ping kinds.
+const (
+	// PingSuccess reports the job succeeded.
+	PingSuccess = "success"
+	// PingStart reports the job started, the duration of the job is measured until its next ping.
+	PingStart = "start"
+	// PingFail reports the job failed.
+	PingFail = "fail"
+	// PingExitCode reports the exit code of the job, it failed if the code isn't zero.
+	PingExitCode = "exitCode"
+)
+
+// Ping is a ping sent by a job to its heartbeat healthcheck.
+type Ping struct {
+	Kind     string
+	ExitCode int
+}
+
+// NewPingToken generates the secret token of the ping url of a heartbeat healthcheck.
+func NewPingToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func (hs *healthcheckService) Ping(ctx context.Context, token string, ping Ping) error {
+	healthcheck, err := hs.healthcheckRepo.FindByPingToken(ctx, token)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return ErrPingNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if ping.Kind == PingStart {
+		return hs.healthcheckRepo.UpdatePingTimes(ctx, healthcheck.ID, healthcheck.LastPingAt, &now)
+	}
+
+	result := checkResult{Status: "ping received", State: repository.StateUp}
+	switch {
+	case ping.Kind == PingFail:
+		result = checkResult{Status: "job failed", State: repository.StateDown}
+	case ping.Kind == PingExitCode && ping.ExitCode != 0:
+		result = checkResult{Status: fmt.Sprintf("job exited with code %d", ping.ExitCode),
+			State: repository.StateDown}
+	}
+	// the duration is stored as the latency, so the status stays the same and alerts are sent on changes only.
+	if healthcheck.PingStartedAt != nil {
+		result.Latency.TotalMs = milliseconds(*healthcheck.PingStartedAt, now)
+	}
+
+	if err := hs.healthcheckRepo.UpdatePingTimes(ctx, healthcheck.ID, &now, nil); err != nil {
+		return err
+	}
+
+	ctx, span := hs.tracer.Start(ctx, "healthcheck.ping", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttribute("healthcheck.id", healthcheck.ID)
+	span.SetAttribute("healthcheck.kind", healthcheck.Kind)
+	hs.record(ctx, healthcheck, result)
+
+	return nil
+}
+
+// newHeartbeatCheck creates a check which is down once no ping arrives within the period and grace time,
+// counted from the last ping or from the time the healthcheck is started. Pings record their own events,
+// so the check records nothing while the heartbeat is on time or already known to be missing.
+func (hs *healthcheckService) newHeartbeatCheck(healthcheck repository.Healthcheck) checkFunc {
+	startedAt := time.Now()
+	timeout := time.Duration(healthcheck.IntervalSeconds+healthcheck.GraceSeconds) * time.Second
+
+	return func(ctx context.Context) (checkResult, error) {
+		current, err := hs.healthcheckRepo.FindOne(ctx, healthcheck.ID)
+		if err != nil {
+			return checkResult{}, err
+		}
+
+		lastPingAt := startedAt
+		if current.LastPingAt != nil && current.LastPingAt.After(lastPingAt) {
+			lastPingAt = *current.LastPingAt
+		}
+		if time.Since(lastPingAt) <= timeout {
+			return checkResult{}, errSkipRun
+		}
+
+		last, err := hs.healthcheckEventRepo.FindLast(ctx, healthcheck.ID)
+		if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+			return checkResult{}, err
+		}
+		if err == nil && strings.HasSuffix(last.Status, heartbeatMissingStatus) && last.CreatedAt.After(lastPingAt) {
+			return checkResult{}, errSkipRun
+		}
+
+		return checkResult{Status: heartbeatMissingStatus, State: repository.StateDown}, nil
+	}
+}
+
+// newHeartbeatEvaluation creates a check which reports whether the last ping of the heartbeat arrived
+// within the period and grace time, it is used by ad-hoc runs.
+func (hs *healthcheckService) newHeartbeatEvaluation(healthcheck repository.Healthcheck) checkFunc {
+	timeout := time.Duration(healthcheck.IntervalSeconds+healthcheck.GraceSeconds) * time.Second
+
+	return func(context.Context) (checkResult, error) {
+		assertion := AssertionResult{Name: "ping received within the period and grace time"}
+		if healthcheck.LastPingAt == nil {
+			assertion.Message = "no ping received yet"
+		} else {
+			since := time.Since(*healthcheck.LastPingAt)
+			assertion.Passed = since <= timeout
+			assertion.Message = fmt.Sprintf("last ping %s ago", since.Truncate(time.Second))
+		}
+
+		result := checkResult{Status: "ping received", State: repository.StateUp,
+			Assertions: []AssertionResult{assertion}}
+		if !assertion.Passed {
+			result.Status, result.State = heartbeatMissingStatus, repository.StateDown
+		}
+		return result, nil
+	}
+}
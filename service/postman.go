@@ -0,0 +1,352 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/therealak12/api-health-check/request"
+)
+
+// maxPostmanVariableDepth limits the variables resolved in the values of other variables.
+const maxPostmanVariableDepth = 10
+
+var (
+	postmanVariableRegex = regexp.MustCompile(`{{([^{}]+)}}`)
+
+	// the status code assertions of the test scripts, e.g. pm.response.to.have.status(200).
+	postmanStatusRegex = regexp.MustCompile(`pm\.response\.to\.have\.status\(\s*(\d{3})\s*\)`)
+	postmanOkRegex     = regexp.MustCompile(`pm\.response\.to\.be\.ok\b`)
+	postmanCodeRegex   = regexp.MustCompile(
+		`pm\.expect\(\s*pm\.response\.code\s*\)\.to\.(?:eql|equal|eq|be\.equal)\(\s*(\d{3})\s*\)`)
+	postmanOneOfRegex = regexp.MustCompile(
+		`pm\.expect\(\s*pm\.response\.code\s*\)\.to\.be\.oneOf\(\s*\[([\d\s,]+)\]\s*\)`)
+	postmanLegacyRegex = regexp.MustCompile(`responseCode\.code\s*===?\s*(\d{3})`)
+	// the lines of the test scripts which assert something.
+	postmanAssertionRegex = regexp.MustCompile(`pm\.expect\(|pm\.response\.to\.|tests\[`)
+)
+
+// PostmanHealthcheck is a request of a collection converted to a healthcheck, Item is the path of the
+// request in the folders of the collection. Err is set if the request can't be imported, Warnings are the
+// parts of the request which aren't.
+type PostmanHealthcheck struct {
+	Item        string
+	Healthcheck request.CreateHealthcheck
+	Warnings    []string
+	Err         error
+}
+
+// PostmanHealthchecks converts the requests of the collection to http healthchecks with the interval and
+// the labels of the import.
+func PostmanHealthchecks(req request.ImportPostman) []PostmanHealthcheck {
+	variables := make(map[string]string)
+	for _, variable := range req.Collection.Variable {
+		if variable.Active() {
+			variables[variable.Key] = string(variable.Value)
+		}
+	}
+	if req.Environment != nil {
+		for _, variable := range req.Environment.Values {
+			if variable.Active() {
+				variables[variable.Key] = string(variable.Value)
+			}
+		}
+	}
+	for key, value := range req.Variables {
+		variables[key] = value
+	}
+
+	converter := postmanConverter{req: req, variables: variables}
+	converter.convert(req.Collection.Item, nil, req.Collection.Auth, req.Collection.Event)
+	return converter.result
+}
+
+type postmanConverter struct {
+	req       request.ImportPostman
+	variables map[string]string
+	result    []PostmanHealthcheck
+}
+
+// convert converts the items of a folder, the folders pass their auth and test scripts to their items.
+func (pc *postmanConverter) convert(items []request.PostmanItem, path []string, auth *request.PostmanAuth,
+	events []request.PostmanEvent) {
+	for _, item := range items {
+		itemPath := append(append([]string{}, path...), item.Name)
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		itemEvents := append(append([]request.PostmanEvent{}, events...), item.Event...)
+
+		if item.Request == nil {
+			pc.convert(item.Item, itemPath, itemAuth, itemEvents)
+			continue
+		}
+		if item.Request.Auth != nil {
+			itemAuth = item.Request.Auth
+		}
+
+		converted := PostmanHealthcheck{Item: strings.Join(itemPath, " / ")}
+		converted.Healthcheck, converted.Warnings, converted.Err = pc.healthcheck(item, itemAuth, itemEvents)
+		if converted.Err == nil {
+			converted.Healthcheck.Name = truncate(converted.Item, 255)
+		}
+		pc.result = append(pc.result, converted)
+	}
+}
+
+func (pc *postmanConverter) healthcheck(item request.PostmanItem, auth *request.PostmanAuth,
+	events []request.PostmanEvent) (request.CreateHealthcheck, []string, error) {
+	itemRequest := item.Request
+	var warnings []string
+	description := itemRequest.Description
+	if description == "" {
+		description = item.Description
+	}
+	healthcheck := request.CreateHealthcheck{
+		Description:     truncate(string(description), 2048),
+		Labels:          pc.req.Labels,
+		Kind:            "http",
+		IntervalSeconds: pc.req.IntervalSeconds,
+		HttpMethod:      strings.ToUpper(itemRequest.Method),
+		Headers:         make(map[string]string),
+	}
+	if healthcheck.HttpMethod == "" {
+		healthcheck.HttpMethod = "GET"
+	}
+
+	// the values are resolved before they're encoded, the encoded variables can't be found.
+	var unresolved []string
+	resolve := func(value string) string {
+		return pc.resolve(value, &unresolved)
+	}
+
+	rawURL := resolve(itemRequest.URL.String())
+	for _, header := range itemRequest.Header {
+		if header.Active() && header.Key != "" {
+			healthcheck.Headers[resolve(header.Key)] = resolve(string(header.Value))
+		}
+	}
+
+	if itemRequest.Body != nil && !itemRequest.Body.Disabled {
+		body, contentType, err := postmanBody(*itemRequest.Body, resolve)
+		if err != nil {
+			return healthcheck, nil, err
+		}
+		healthcheck.Body = body
+		if contentType != "" && !hasHeader(healthcheck.Headers, "Content-Type") {
+			healthcheck.Headers["Content-Type"] = contentType
+		}
+	}
+
+	if auth != nil {
+		var warning string
+		rawURL, warning = postmanAuth(*auth, rawURL, healthcheck.Headers, resolve)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	healthcheck.Url = rawURL
+	if len(unresolved) > 0 {
+		sort.Strings(unresolved)
+		return healthcheck, nil, fmt.Errorf("unresolved variables: %s", strings.Join(unresolved, ", "))
+	}
+
+	statusCodes, statusWarnings, err := postmanStatusCodes(events)
+	if err != nil {
+		return healthcheck, nil, err
+	}
+	healthcheck.ValidStatusCodes = statusCodes
+	warnings = append(warnings, statusWarnings...)
+
+	return healthcheck, warnings, nil
+}
+
+// resolve replaces the variables of the value, the names of the unknown ones are added to unresolved.
+func (pc *postmanConverter) resolve(value string, unresolved *[]string) string {
+	for i := 0; i < maxPostmanVariableDepth && postmanVariableRegex.MatchString(value); i++ {
+		value = postmanVariableRegex.ReplaceAllStringFunc(value, func(match string) string {
+			name := strings.TrimSpace(match[2 : len(match)-2])
+			if resolved, ok := pc.variables[name]; ok {
+				return resolved
+			}
+			return match
+		})
+	}
+
+	for _, match := range postmanVariableRegex.FindAllStringSubmatch(value, -1) {
+		name := strings.TrimSpace(match[1])
+		found := false
+		for _, known := range *unresolved {
+			found = found || known == name
+		}
+		if !found {
+			*unresolved = append(*unresolved, name)
+		}
+	}
+	return value
+}
+
+// postmanBody returns the body of the request and its content type if Postman sets one, the variables are
+// replaced by resolve.
+func postmanBody(body request.PostmanBody, resolve func(string) string) (string, string, error) {
+	switch body.Mode {
+	case "", "none":
+		return "", "", nil
+	case "raw":
+		if body.Options.Raw.Language == "json" {
+			return resolve(body.Raw), "application/json", nil
+		}
+		return resolve(body.Raw), "", nil
+	case "urlencoded":
+		var params []string
+		for _, param := range body.URLEncoded {
+			if param.Active() {
+				params = append(params, url.QueryEscape(resolve(param.Key))+"="+
+					url.QueryEscape(resolve(string(param.Value))))
+			}
+		}
+		return strings.Join(params, "&"), "application/x-www-form-urlencoded", nil
+	case "graphql":
+		if body.GraphQL == nil {
+			return "", "", nil
+		}
+		query := map[string]interface{}{"query": resolve(body.GraphQL.Query)}
+		if variables := resolve(body.GraphQL.Variables); strings.TrimSpace(variables) != "" {
+			query["variables"] = json.RawMessage(variables)
+		}
+		data, err := json.Marshal(query)
+		if err != nil {
+			return "", "", errors.New("the graphql variables aren't valid json")
+		}
+		return string(data), "application/json", nil
+	default:
+		return "", "", fmt.Errorf("%s bodies can't be imported", body.Mode)
+	}
+}
+
+// postmanAuth adds the credentials of the auth to the headers or the url, it returns the url and a warning
+// if the auth can't be imported. The Authorization header of the request is kept. The variables of the
+// credentials are replaced by resolve.
+func postmanAuth(auth request.PostmanAuth, rawURL string, headers map[string]string,
+	resolve func(string) string) (string, string) {
+	switch auth.Type {
+	case "", "noauth":
+	case "bearer":
+		if !hasHeader(headers, "Authorization") {
+			headers["Authorization"] = "Bearer " + resolve(auth.Bearer["token"])
+		}
+	case "basic":
+		if !hasHeader(headers, "Authorization") {
+			credentials := resolve(auth.Basic["username"]) + ":" + resolve(auth.Basic["password"])
+			headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+		}
+	case "apikey":
+		key, value := resolve(auth.APIKey["key"]), resolve(auth.APIKey["value"])
+		if auth.APIKey["in"] == "query" {
+			separator := "?"
+			if strings.Contains(rawURL, "?") {
+				separator = "&"
+			}
+			return rawURL + separator + url.QueryEscape(key) + "=" + url.QueryEscape(value), ""
+		}
+		if !hasHeader(headers, key) {
+			headers[key] = value
+		}
+	default:
+		return rawURL, fmt.Sprintf("%s auth isn't imported", auth.Type)
+	}
+	return rawURL, ""
+}
+
+// postmanStatusCodes returns the status codes the test scripts assert, all the assertions must pass so
+// only the codes allowed by all of them are returned. The warnings report the assertions which aren't
+// imported, it fails if no status code passes all the assertions.
+func postmanStatusCodes(events []request.PostmanEvent) ([]int, []string, error) {
+	var allowed map[int]bool
+	var unsupported bool
+	for _, event := range events {
+		if event.Listen != "test" {
+			continue
+		}
+		for _, line := range event.Script.Exec {
+			lineCodes := postmanLineStatusCodes(line)
+			if lineCodes == nil {
+				unsupported = unsupported || postmanAssertionRegex.MatchString(line)
+				continue
+			}
+
+			next := make(map[int]bool)
+			for _, code := range lineCodes {
+				if allowed == nil || allowed[code] {
+					next[code] = true
+				}
+			}
+			allowed = next
+		}
+	}
+
+	if allowed != nil && len(allowed) == 0 {
+		return nil, nil, errors.New("the status code assertions of the test scripts contradict each other")
+	}
+	var warnings []string
+	if unsupported {
+		warnings = append(warnings, "only the status code assertions of the test scripts are imported")
+	}
+
+	var codes []int
+	for code := range allowed {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes, warnings, nil
+}
+
+// postmanLineStatusCodes returns the status codes asserted by the line of a test script, nil if it has no
+// status code assertion.
+func postmanLineStatusCodes(line string) []int {
+	var codes []int
+	for _, regex := range []*regexp.Regexp{postmanStatusRegex, postmanCodeRegex, postmanLegacyRegex} {
+		for _, match := range regex.FindAllStringSubmatch(line, -1) {
+			code, _ := strconv.Atoi(match[1])
+			codes = append(codes, code)
+		}
+	}
+	if postmanOkRegex.MatchString(line) {
+		codes = append(codes, 200)
+	}
+	for _, match := range postmanOneOfRegex.FindAllStringSubmatch(line, -1) {
+		for _, value := range strings.Split(match[1], ",") {
+			if code, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
+
+// hasHeader reports whether the header is set, the names are case insensitive.
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate returns at most max runes of the value.
+func truncate(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
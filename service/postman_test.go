@@ -0,0 +1,259 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/therealak12/api-health-check/request"
+)
+
+var postmanTestVariables = map[string]string{
+	"host":    "api.example.com",
+	"baseUrl": "https://{{host}}",
+	"apiKey":  "s3cr&t key",
+	"user":    "admin",
+	"loop":    "{{loop}}",
+}
+
+// postmanTestResolve resolves the test variables and returns the names of the unresolved ones.
+func postmanTestResolve() (func(string) string, *[]string) {
+	converter := postmanConverter{variables: postmanTestVariables}
+	unresolved := &[]string{}
+	return func(value string) string {
+		return converter.resolve(value, unresolved)
+	}, unresolved
+}
+
+func TestPostmanResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		value      string
+		want       string
+		unresolved []string
+	}{
+		{"no variables", "https://example.com", "https://example.com", []string{}},
+		{"variable", "{{ host }}/health", "api.example.com/health", []string{}},
+		{"nested variables", "{{baseUrl}}/health", "https://api.example.com/health", []string{}},
+		{"unknown variables", "{{baseUrl}}/{{version}}?id={{id}}&v={{version}}",
+			"https://api.example.com/{{version}}?id={{id}}&v={{version}}", []string{"version", "id"}},
+		{"recursive variable", "{{loop}}", "{{loop}}", []string{"loop"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolve, unresolved := postmanTestResolve()
+			if got := resolve(test.value); got != test.want {
+				t.Errorf("value is %q, want %q", got, test.want)
+			}
+			if !reflect.DeepEqual(*unresolved, test.unresolved) {
+				t.Errorf("unresolved variables are %v, want %v", *unresolved, test.unresolved)
+			}
+		})
+	}
+}
+
+func TestPostmanAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    request.PostmanAuth
+		url     string
+		headers map[string]string
+		wantURL string
+		want    map[string]string
+		warning string
+	}{
+		{"no auth", request.PostmanAuth{Type: "noauth"}, "https://example.com", map[string]string{},
+			"https://example.com", map[string]string{}, ""},
+		{"bearer", request.PostmanAuth{Type: "bearer", Bearer: map[string]string{"token": "{{apiKey}}"}},
+			"https://example.com", map[string]string{}, "https://example.com",
+			map[string]string{"Authorization": "Bearer s3cr&t key"}, ""},
+		{"bearer keeps the authorization header", request.PostmanAuth{Type: "bearer",
+			Bearer: map[string]string{"token": "token"}}, "https://example.com",
+			map[string]string{"authorization": "Token abc"}, "https://example.com",
+			map[string]string{"authorization": "Token abc"}, ""},
+		{"basic", request.PostmanAuth{Type: "basic",
+			Basic: map[string]string{"username": "{{user}}", "password": "p:ss"}}, "https://example.com",
+			map[string]string{}, "https://example.com", map[string]string{"Authorization": "Basic YWRtaW46cDpzcw=="},
+			""},
+		{"api key header", request.PostmanAuth{Type: "apikey",
+			APIKey: map[string]string{"key": "X-API-Key", "value": "{{apiKey}}"}}, "https://example.com",
+			map[string]string{}, "https://example.com", map[string]string{"X-API-Key": "s3cr&t key"}, ""},
+		{"api key query", request.PostmanAuth{Type: "apikey",
+			APIKey: map[string]string{"key": "api key", "value": "{{apiKey}}", "in": "query"}},
+			"https://example.com/health", map[string]string{},
+			"https://example.com/health?api+key=s3cr%26t+key", map[string]string{}, ""},
+		{"api key query after parameters", request.PostmanAuth{Type: "apikey",
+			APIKey: map[string]string{"key": "key", "value": "{{apiKey}}", "in": "query"}},
+			"https://example.com/health?full=true", map[string]string{},
+			"https://example.com/health?full=true&key=s3cr%26t+key", map[string]string{}, ""},
+		{"unsupported", request.PostmanAuth{Type: "oauth2"}, "https://example.com", map[string]string{},
+			"https://example.com", map[string]string{}, "oauth2 auth isn't imported"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolve, _ := postmanTestResolve()
+			gotURL, warning := postmanAuth(test.auth, test.url, test.headers, resolve)
+			if gotURL != test.wantURL {
+				t.Errorf("url is %q, want %q", gotURL, test.wantURL)
+			}
+			if !reflect.DeepEqual(test.headers, test.want) {
+				t.Errorf("headers are %v, want %v", test.headers, test.want)
+			}
+			if warning != test.warning {
+				t.Errorf("warning is %q, want %q", warning, test.warning)
+			}
+		})
+	}
+}
+
+func TestPostmanBody(t *testing.T) {
+	jsonBody := request.PostmanBody{Mode: "raw", Raw: `{"user": "{{user}}"}`}
+	jsonBody.Options.Raw.Language = "json"
+
+	tests := []struct {
+		name        string
+		body        request.PostmanBody
+		want        string
+		contentType string
+		valid       bool
+	}{
+		{"none", request.PostmanBody{Mode: "none"}, "", "", true},
+		{"raw", request.PostmanBody{Mode: "raw", Raw: "user={{user}}"}, "user=admin", "", true},
+		{"raw json", jsonBody, `{"user": "admin"}`, "application/json", true},
+		{"urlencoded", request.PostmanBody{Mode: "urlencoded", URLEncoded: []request.PostmanVariable{
+			{Key: "user", Value: "{{user}}"},
+			{Key: "api key", Value: "{{apiKey}}"},
+			{Key: "debug", Value: "true", Disabled: true},
+		}}, "user=admin&api+key=s3cr%26t+key", "application/x-www-form-urlencoded", true},
+		{"graphql", request.PostmanBody{Mode: "graphql", GraphQL: &request.PostmanGraphQL{
+			Query: "{ user(name: \"{{user}}\") { id } }", Variables: `{"limit": 1}`,
+		}}, `{"query":"{ user(name: \"admin\") { id } }","variables":{"limit":1}}`, "application/json", true},
+		{"graphql invalid variables", request.PostmanBody{Mode: "graphql", GraphQL: &request.PostmanGraphQL{
+			Query: "{ users { id } }", Variables: "{limit: 1}",
+		}}, "", "", false},
+		{"file", request.PostmanBody{Mode: "file"}, "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolve, _ := postmanTestResolve()
+			got, contentType, err := postmanBody(test.body, resolve)
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("body is %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to convert body: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("body is %q, want %q", got, test.want)
+			}
+			if contentType != test.contentType {
+				t.Errorf("content type is %q, want %q", contentType, test.contentType)
+			}
+		})
+	}
+}
+
+// postmanTests returns the test events with the lines of the script.
+func postmanTests(lines ...string) []request.PostmanEvent {
+	event := request.PostmanEvent{Listen: "test"}
+	event.Script.Exec = lines
+	return []request.PostmanEvent{event}
+}
+
+func TestPostmanStatusCodes(t *testing.T) {
+	prerequest := request.PostmanEvent{Listen: "prerequest"}
+	prerequest.Script.Exec = []string{"pm.response.to.have.status(500);"}
+
+	tests := []struct {
+		name     string
+		events   []request.PostmanEvent
+		want     []int
+		warnings []string
+		valid    bool
+	}{
+		{"no tests", nil, nil, nil, true},
+		{"prerequest script", []request.PostmanEvent{prerequest}, nil, nil, true},
+		{"have status", postmanTests(`pm.test("ok", () => pm.response.to.have.status(204));`), []int{204}, nil,
+			true},
+		{"be ok", postmanTests("pm.response.to.be.ok;"), []int{200}, nil, true},
+		{"expect code", postmanTests("pm.expect(pm.response.code).to.eql(201);"), []int{201}, nil, true},
+		{"one of", postmanTests("pm.expect(pm.response.code).to.be.oneOf([200, 201, 202]);"),
+			[]int{200, 201, 202}, nil, true},
+		{"legacy", postmanTests(`tests["ok"] = responseCode.code === 200;`), []int{200}, nil, true},
+		{"assertions narrow the codes", postmanTests(
+			"pm.expect(pm.response.code).to.be.oneOf([200, 201, 202]);",
+			"pm.expect(pm.response.code).to.be.oneOf([202, 201]);",
+		), []int{201, 202}, nil, true},
+		{"other assertions", postmanTests(
+			"pm.response.to.have.status(200);",
+			`pm.expect(pm.response.json().status).to.eql("ok");`,
+		), []int{200}, []string{"only the status code assertions of the test scripts are imported"}, true},
+		{"contradicting assertions", postmanTests(
+			"pm.response.to.have.status(200);",
+			"pm.response.to.have.status(201);",
+		), nil, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, warnings, err := postmanStatusCodes(test.events)
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("status codes are %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to get status codes: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("status codes are %v, want %v", got, test.want)
+			}
+			if !reflect.DeepEqual(warnings, test.warnings) {
+				t.Errorf("warnings are %v, want %v", warnings, test.warnings)
+			}
+		})
+	}
+}
+
+func TestPostmanHealthchecksResolveEncodedVariables(t *testing.T) {
+	body := request.PostmanBody{Mode: "urlencoded", URLEncoded: []request.PostmanVariable{
+		{Key: "token", Value: "{{token}}"},
+	}}
+	req := request.ImportPostman{
+		IntervalSeconds: 60,
+		Collection: request.PostmanCollection{
+			Item: []request.PostmanItem{{
+				Name: "login",
+				Request: &request.PostmanRequest{
+					Method: "post",
+					URL:    request.PostmanURL{Raw: "{{baseUrl}}/login"},
+					Body:   &body,
+				},
+			}},
+			Variable: []request.PostmanVariable{{Key: "baseUrl", Value: "https://api.example.com"}},
+			Auth: &request.PostmanAuth{Type: "apikey",
+				APIKey: map[string]string{"key": "key", "value": "{{apiKey}}", "in": "query"}},
+		},
+		Variables: map[string]string{"apiKey": "a&b"},
+	}
+
+	got := PostmanHealthchecks(req)
+	if len(got) != 1 || got[0].Err == nil || got[0].Err.Error() != "unresolved variables: token" {
+		t.Fatalf("healthchecks are %+v, want the unresolved token", got)
+	}
+
+	req.Variables["token"] = "{{apiKey}} c"
+	got = PostmanHealthchecks(req)
+	if len(got) != 1 || got[0].Err != nil {
+		t.Fatalf("healthchecks are %+v, want one", got)
+	}
+	healthcheck := got[0].Healthcheck
+	if want := "https://api.example.com/login?key=a%26b"; healthcheck.Url != want {
+		t.Errorf("url is %q, want %q", healthcheck.Url, want)
+	}
+	if want := "token=a%26b+c"; healthcheck.Body != want {
+		t.Errorf("body is %q, want %q", healthcheck.Body, want)
+	}
+}
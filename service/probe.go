@@ -0,0 +1,248 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/therealak12/api-health-check/config"
+	"github.com/therealak12/api-health-check/metrics"
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/tracing"
+)
+
+// probeResult is the outcome of a single healthcheck request.
+type probeResult struct {
+	Status     string
+	StatusCode int
+	Header     http.Header
+	// Body is the beginning of the response body, it is read only if a body limit is given.
+	Body          []byte
+	BodyTruncated bool
+	Latency       repository.Latency
+	// CertExpiry is the expiry time of the leaf certificate of https urls.
+	CertExpiry time.Time
+	Err        error
+}
+
+// newHealthcheckRequest builds the request of a http healthcheck, a new request is needed for every run
+// since the body is consumed.
+func newHealthcheckRequest(healthcheck repository.Healthcheck) (*http.Request, error) {
+	req, err := http.NewRequest(
+		healthcheck.HttpMethod,
+		healthcheck.Url,
+		bytes.NewBuffer([]byte(healthcheck.Body)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range healthcheck.Headers {
+		req.Header.Add(key, value)
+	}
+
+	return req, nil
+}
+
+// probe sends the request, reads the whole response body and measures the latency of each phase.
+// Up to bodyLimit bytes of the body are kept in the result.
+func probe(httpClient *http.Client, req *http.Request, bodyLimit int64) probeResult {
+	var (
+		result                                 probeResult
+		mu                                     sync.Mutex
+		dnsStart, connectStart, tlsStart       time.Time
+		dnsDone, connectDone, tlsDone, gotConn time.Time
+		firstByte                              time.Time
+	)
+	// the connect callbacks of the parallel dials of the addresses of the host (happy eyeballs) run
+	// concurrently, and those of the losing dials may run after the request is done.
+	mark := func(t *time.Time, first bool) {
+		mu.Lock()
+		if !first || t.IsZero() {
+			*t = time.Now()
+		}
+		mu.Unlock()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { mark(&dnsStart, false) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { mark(&dnsDone, false) },
+		ConnectStart: func(string, string) { mark(&connectStart, true) },
+		ConnectDone: func(_, _ string, err error) {
+			// the first dial which succeeds wins, the connections of the others are closed.
+			if err == nil {
+				mark(&connectDone, true)
+			}
+		},
+		TLSHandshakeStart:    func() { mark(&tlsStart, false) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark(&tlsDone, false) },
+		GotConn:              func(httptrace.GotConnInfo) { mark(&gotConn, false) },
+		GotFirstResponseByte: func() { mark(&firstByte, false) },
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err == nil {
+		//goland:noinspection GoUnhandledErrorResult
+		defer resp.Body.Close()
+
+		result.Status = resp.Status
+		result.StatusCode = resp.StatusCode
+		result.Header = resp.Header
+		if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+			result.CertExpiry = resp.TLS.PeerCertificates[0].NotAfter
+		}
+		if bodyLimit > 0 {
+			result.Body, err = io.ReadAll(io.LimitReader(resp.Body, bodyLimit+1))
+			if int64(len(result.Body)) > bodyLimit {
+				result.Body, result.BodyTruncated = result.Body[:bodyLimit], true
+			}
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, resp.Body)
+		}
+	}
+	end := time.Now()
+	result.Err = err
+
+	mu.Lock()
+	defer mu.Unlock()
+	result.Latency = repository.Latency{
+		TotalMs:   milliseconds(start, end),
+		DNSMs:     milliseconds(dnsStart, dnsDone),
+		ConnectMs: milliseconds(connectStart, connectDone),
+		TLSMs:     milliseconds(tlsStart, tlsDone),
+	}
+	if !gotConn.IsZero() && !firstByte.IsZero() {
+		result.Latency.TTFBMs = milliseconds(gotConn, firstByte)
+		result.Latency.TransferMs = milliseconds(firstByte, end)
+	}
+
+	return result
+}
+
+// milliseconds returns the duration between start and end, zero if either of them is missing.
+func milliseconds(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return float64(end.Sub(start)) / float64(time.Millisecond)
+}
+
+// ErrUnknownProbeModule indicates the requested probe module is not configured.
+var ErrUnknownProbeModule = errors.New("unknown probe module")
+
+// ProbeService runs one-off probes of arbitrary targets using the configured modules.
+type ProbeService interface {
+	// Probe probes the target and writes the result in the Prometheus text format.
+	Probe(ctx context.Context, target, module string, w io.Writer) error
+}
+
+type probeService struct {
+	probeConfig config.Probe
+	tracer      *tracing.Tracer
+}
+
+var _ ProbeService = &probeService{}
+
+func NewProbeService(probeConfig config.Probe, tracer *tracing.Tracer) ProbeService {
+	return &probeService{
+		probeConfig: probeConfig,
+		tracer:      tracer,
+	}
+}
+
+func (ps *probeService) Probe(ctx context.Context, target, module string, w io.Writer) error {
+	probeModule, ok := ps.probeConfig.Modules[module]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownProbeModule, module)
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+
+	method := probeModule.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	registry := metrics.NewRegistry()
+	success := metrics.NewGaugeVec(registry, "probe_success", "Whether the probe was a success.")
+	duration := metrics.NewGaugeVec(registry, "probe_duration_seconds",
+		"How long the probe took to complete in seconds.")
+	statusCode := metrics.NewGaugeVec(registry, "probe_http_status_code", "Response HTTP status code.")
+	phases := metrics.NewGaugeVec(registry, "probe_http_duration_seconds",
+		"Duration of the http request by phase.", "phase")
+	dnsLookup := metrics.NewGaugeVec(registry, "probe_dns_lookup_time_seconds",
+		"Returns the time taken for probe dns lookup in seconds.")
+	ssl := metrics.NewGaugeVec(registry, "probe_http_ssl", "Indicates if SSL was used for the final redirect.")
+	certExpiry := metrics.NewGaugeVec(registry, "probe_ssl_earliest_cert_expiry",
+		"Returns earliest SSL cert expiry in unixtime.")
+
+	start := time.Now()
+	req, err := newHealthcheckRequest(repository.Healthcheck{
+		Url:        target,
+		HttpMethod: method,
+		Headers:    probeModule.Headers,
+		Body:       probeModule.Body,
+	})
+	if err != nil {
+		logrus.Warnf("failed to build probe request for %s, err: %s", target, err)
+		success.Set(0)
+		duration.Set(time.Since(start).Seconds())
+		return registry.Write(w)
+	}
+
+	transport := &tracing.Transport{Tracer: ps.tracer}
+	if probeModule.InsecureSkipTLS {
+		transport.Base = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	httpClient := &http.Client{Timeout: probeModule.Timeout, Transport: transport}
+	result := probe(httpClient, req.WithContext(ctx), 0)
+
+	success.Set(0)
+	if result.Err == nil && validProbeStatusCode(probeModule, result.StatusCode) {
+		success.Set(1)
+	}
+	duration.Set(time.Since(start).Seconds())
+	statusCode.Set(float64(result.StatusCode))
+	dnsLookup.Set(result.Latency.DNSMs / 1000)
+	phases.Set(result.Latency.DNSMs/1000, "resolve")
+	phases.Set(result.Latency.ConnectMs/1000, "connect")
+	phases.Set(result.Latency.TLSMs/1000, "tls")
+	phases.Set(result.Latency.TTFBMs/1000, "processing")
+	phases.Set(result.Latency.TransferMs/1000, "transfer")
+	if result.CertExpiry.IsZero() {
+		ssl.Set(0)
+	} else {
+		ssl.Set(1)
+		certExpiry.Set(float64(result.CertExpiry.Unix()))
+	}
+
+	return registry.Write(w)
+}
+
+func validProbeStatusCode(module config.ProbeModule, statusCode int) bool {
+	if len(module.ValidStatusCodes) == 0 {
+		return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
+	}
+	for _, code := range module.ValidStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/therealak12/api-health-check/config"
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// ErrHourlyUptimeExpired indicates the hourly rollups of the requested range are already deleted.
+var ErrHourlyUptimeExpired = errors.New("hourly uptime expired")
+
+// UptimeBucketReport is the uptime of a single bucket, Availability is a percentage.
+type UptimeBucketReport struct {
+	repository.UptimeBucket
+	Availability float64 `json:"availability"`
+}
+
+// UptimeReport is the availability of a healthcheck in a time range, Availability is a percentage.
+type UptimeReport struct {
+	HealthcheckID   int                    `json:"healthcheckId"`
+	From            time.Time              `json:"from"`
+	To              time.Time              `json:"to"`
+	Granularity     string                 `json:"granularity"`
+	Excluded        []repository.TimeRange `json:"excluded"`
+	Availability    float64                `json:"availability"`
+	UptimeSeconds   float64                `json:"uptimeSeconds"`
+	DowntimeSeconds float64                `json:"downtimeSeconds"`
+	Incidents       int                    `json:"incidents"`
+	Buckets         []UptimeBucketReport   `json:"buckets"`
+}
+
+// LatencyReport is the latency percentiles of a healthcheck in a time range.
+// The percentiles are Approximated when a part of the range is read from rollups.
+type LatencyReport struct {
+	HealthcheckID int       `json:"healthcheckId"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	Approximated  bool      `json:"approximated"`
+	repository.LatencyPercentiles
+}
+
+type ReportService interface {
+	Uptime(ctx context.Context, healthcheckID int, from, to time.Time, granularity string,
+		excluded []repository.TimeRange) (UptimeReport, error)
+	Latency(ctx context.Context, healthcheckID int, from, to time.Time) (LatencyReport, error)
+}
+
+// reportService reads the ranges before the rollup watermark of a healthcheck from its rollups
+// and the rest from the raw events.
+type reportService struct {
+	healthcheckEventRepo repository.HealthcheckEventRepo
+	rollupRepo           repository.HealthcheckEventRollupRepo
+	retentionConfig      config.Retention
+}
+
+var _ ReportService = &reportService{}
+
+func NewReportService(healthcheckEventRepo repository.HealthcheckEventRepo,
+	rollupRepo repository.HealthcheckEventRollupRepo,
+	retentionConfig config.Retention) ReportService {
+	return &reportService{
+		healthcheckEventRepo: healthcheckEventRepo,
+		rollupRepo:           rollupRepo,
+		retentionConfig:      retentionConfig,
+	}
+}
+
+func (rs *reportService) Uptime(ctx context.Context, healthcheckID int, from, to time.Time, granularity string,
+	excluded []repository.TimeRange) (UptimeReport, error) {
+	excluded = mergeTimeRanges(excluded)
+	report := UptimeReport{
+		HealthcheckID: healthcheckID,
+		From:          from,
+		To:            to,
+		Granularity:   granularity,
+		Excluded:      excluded,
+		Buckets:       []UptimeBucketReport{},
+	}
+
+	watermark, err := rs.rollupRepo.FindWatermark(ctx, healthcheckID)
+	if err != nil {
+		return report, err
+	}
+
+	var buckets []repository.UptimeBucket
+	rawFrom := from
+	if from.Before(watermark) {
+		length := time.Hour
+		if granularity == repository.GranularityDay {
+			length = day
+		} else if expiry := time.Now().Add(-rs.retentionConfig.HourlyPeriod).Truncate(day); from.Before(expiry) {
+			// the hourly rollups are deleted by the retention job, the range would be partly empty.
+			return report, fmt.Errorf("%w: hourly uptime is kept for %d days, use the day granularity",
+				ErrHourlyUptimeExpired, rs.retentionConfig.HourlyPeriod/day)
+		}
+		window := repository.TimeRange{From: from, To: minTime(to, watermark)}
+		rollups, err := rs.rollupRepo.Find(ctx, healthcheckID, granularity, from.Truncate(length), window.To)
+		if err != nil {
+			return report, err
+		}
+		for _, rollup := range rollups {
+			buckets = append(buckets, uptimeFromRollup(rollup, length, window, excluded))
+		}
+		rawFrom = watermark
+	}
+
+	if rawFrom.Before(to) {
+		raw, err := rs.healthcheckEventRepo.Uptime(ctx, healthcheckID, rawFrom, to, granularity, excluded)
+		if err != nil {
+			return report, err
+		}
+		buckets = append(buckets, raw...)
+	}
+
+	for _, bucket := range buckets {
+		report.UptimeSeconds += bucket.UpSeconds
+		report.DowntimeSeconds += bucket.DownSeconds
+		report.Incidents += bucket.Incidents
+		report.Buckets = append(report.Buckets, UptimeBucketReport{
+			UptimeBucket: bucket,
+			Availability: availability(bucket.UpSeconds, bucket.DownSeconds),
+		})
+	}
+	report.Availability = availability(report.UptimeSeconds, report.DowntimeSeconds)
+
+	return report, nil
+}
+
+// uptimeFromRollup converts a rollup to an uptime bucket of the window, the rollups at its edges are partly
+// out of it. The time out of the window and the excluded time are removed from the up and down durations
+// proportionally since the rollup doesn't know when in the bucket the events happened.
+func uptimeFromRollup(rollup repository.HealthcheckEventRollup, length time.Duration, window repository.TimeRange,
+	excluded []repository.TimeRange) repository.UptimeBucket {
+	start, end := maxTime(rollup.Bucket, window.From), minTime(rollup.Bucket.Add(length), window.To)
+	includedDuration := end.Sub(start)
+	for _, r := range excluded {
+		if overlap := minTime(end, r.To).Sub(maxTime(start, r.From)); overlap > 0 {
+			includedDuration -= overlap
+		}
+	}
+	included := math.Max(0, float64(includedDuration)/float64(length))
+
+	return repository.UptimeBucket{
+		Bucket:      rollup.Bucket,
+		UpSeconds:   rollup.UpSeconds * included,
+		DownSeconds: rollup.DownSeconds * included,
+		Incidents:   rollup.Incidents,
+	}
+}
+
+func (rs *reportService) Latency(ctx context.Context, healthcheckID int, from, to time.Time) (LatencyReport, error) {
+	report := LatencyReport{
+		HealthcheckID: healthcheckID,
+		From:          from,
+		To:            to,
+	}
+
+	watermark, err := rs.rollupRepo.FindWatermark(ctx, healthcheckID)
+	if err != nil {
+		return report, err
+	}
+
+	rawFrom := from
+	if from.Before(watermark) {
+		window := repository.TimeRange{From: from, To: minTime(to, watermark)}
+		rollups, err := rs.rollupRepo.Find(ctx, healthcheckID, repository.GranularityDay, from.Truncate(day),
+			window.To)
+		if err != nil {
+			return report, err
+		}
+		for _, rollup := range rollups {
+			// the latencies of the rollups at the edges of the window are weighted by the part in it.
+			start, end := maxTime(rollup.Bucket, window.From), minTime(rollup.Bucket.Add(day), window.To)
+			included := math.Max(0, float64(end.Sub(start))/float64(day))
+			report.LatencyPercentiles = mergePercentiles(report.LatencyPercentiles, repository.LatencyPercentiles{
+				Count: int(math.Round(float64(rollup.LatencyCount) * included)),
+				P50:   rollup.P50Ms,
+				P90:   rollup.P90Ms,
+				P95:   rollup.P95Ms,
+				P99:   rollup.P99Ms,
+			})
+		}
+		report.Approximated = len(rollups) > 0
+		rawFrom = watermark
+	}
+
+	if rawFrom.Before(to) {
+		percentiles, err := rs.healthcheckEventRepo.LatencyPercentiles(ctx, healthcheckID, rawFrom, to)
+		if err != nil {
+			return report, err
+		}
+		report.LatencyPercentiles = mergePercentiles(report.LatencyPercentiles, percentiles)
+	}
+
+	return report, nil
+}
+
+// mergePercentiles approximates the percentiles of the union by the weighted average of the percentiles.
+func mergePercentiles(a, b repository.LatencyPercentiles) repository.LatencyPercentiles {
+	count := a.Count + b.Count
+	if count == 0 {
+		return repository.LatencyPercentiles{}
+	}
+	weighted := func(x, y float64) float64 {
+		return (x*float64(a.Count) + y*float64(b.Count)) / float64(count)
+	}
+
+	return repository.LatencyPercentiles{
+		Count: count,
+		P50:   weighted(a.P50, b.P50),
+		P90:   weighted(a.P90, b.P90),
+		P95:   weighted(a.P95, b.P95),
+		P99:   weighted(a.P99, b.P99),
+	}
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// availability returns the percentage of up time, a range without any data is considered available.
+func availability(up, down float64) float64 {
+	if up+down == 0 {
+		return 100
+	}
+	return up / (up + down) * 100
+}
+
+// mergeTimeRanges sorts the ranges and merges the overlapping ones.
+func mergeTimeRanges(ranges []repository.TimeRange) []repository.TimeRange {
+	sorted := make([]repository.TimeRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.To.After(r.From) {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].From.Before(sorted[j].From) })
+
+	result := []repository.TimeRange{}
+	for _, r := range sorted {
+		if last := len(result) - 1; last >= 0 && !r.From.After(result[last].To) {
+			if r.To.After(result[last].To) {
+				result[last].To = r.To
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+
+	return result
+}
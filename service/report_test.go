@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+func TestUptimeFromRollupScalesEdgeBuckets(t *testing.T) {
+	bucket := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return bucket.Add(time.Duration(minutes) * time.Minute)
+	}
+	rollup := repository.HealthcheckEventRollup{Bucket: bucket, UpSeconds: 3000, DownSeconds: 600, Incidents: 1}
+
+	tests := []struct {
+		name     string
+		window   repository.TimeRange
+		excluded []repository.TimeRange
+		up       float64
+		down     float64
+	}{
+		{"whole bucket", repository.TimeRange{From: at(0), To: at(60)}, nil, 3000, 600},
+		{"window starts in the bucket", repository.TimeRange{From: at(45), To: at(120)}, nil, 750, 150},
+		{"window ends in the bucket", repository.TimeRange{From: at(-60), To: at(30)}, nil, 1500, 300},
+		{"excluded in the window", repository.TimeRange{From: at(30), To: at(60)},
+			[]repository.TimeRange{{From: at(50), To: at(60)}}, 1000, 200},
+		{"excluded out of the window", repository.TimeRange{From: at(30), To: at(60)},
+			[]repository.TimeRange{{From: at(0), To: at(30)}}, 1500, 300},
+		{"bucket out of the window", repository.TimeRange{From: at(60), To: at(120)}, nil, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := uptimeFromRollup(rollup, time.Hour, test.window, test.excluded)
+			if got.UpSeconds != test.up || got.DownSeconds != test.down {
+				t.Errorf("up and down seconds are %v and %v, want %v and %v", got.UpSeconds, got.DownSeconds,
+					test.up, test.down)
+			}
+		})
+	}
+}
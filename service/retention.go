@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/therealak12/api-health-check/config"
+	"github.com/therealak12/api-health-check/repository"
+)
+
+const day = 24 * time.Hour
+
+// RetentionService rolls up the old events and deletes them.
+type RetentionService interface {
+	// Start runs the retention job every configured interval until the context is done.
+	Start(ctx context.Context)
+	Run(ctx context.Context) error
+}
+
+type retentionService struct {
+	healthcheckEventRepo repository.HealthcheckEventRepo
+	rollupRepo           repository.HealthcheckEventRollupRepo
+	partitionService     PartitionService
+	retentionConfig      config.Retention
+}
+
+var _ RetentionService = &retentionService{}
+
+func NewRetentionService(healthcheckEventRepo repository.HealthcheckEventRepo,
+	rollupRepo repository.HealthcheckEventRollupRepo,
+	partitionService PartitionService,
+	retentionConfig config.Retention) RetentionService {
+	return &retentionService{
+		healthcheckEventRepo: healthcheckEventRepo,
+		rollupRepo:           rollupRepo,
+		partitionService:     partitionService,
+		retentionConfig:      retentionConfig,
+	}
+}
+
+func (rs *retentionService) Start(ctx context.Context) {
+	ticker := time.NewTicker(rs.retentionConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := rs.Run(ctx); err != nil {
+			logrus.Errorf("failed to run retention job, err: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run rolls up the raw events older than the raw period a day at a time and deletes them, the expired
+// partitions are dropped instead of deleting their events in batches. The expired hourly rollups are
+// deleted too. Rolling up a day keeps the existing hourly rollups, so an interrupted run resumes
+// deleting the day it was on.
+func (rs *retentionService) Run(ctx context.Context) error {
+	now := time.Now()
+	cutoff := now.Add(-rs.retentionConfig.RawPeriod).Truncate(day)
+
+	oldest, err := rs.healthcheckEventRepo.FindOldest(ctx)
+	if err != nil && !errors.Is(err, repository.ErrRecordNotFound) {
+		return err
+	}
+
+	if err == nil {
+		expired, err := rs.partitionService.FindExpired(cutoff)
+		if err != nil {
+			return err
+		}
+
+		for from := oldest.Truncate(day); from.Before(cutoff); from = from.Add(day) {
+			if err := rs.rollupDay(ctx, from, from.Add(day), !inPartitions(expired, from)); err != nil {
+				return err
+			}
+		}
+
+		if err := rs.partitionService.DropBefore(cutoff); err != nil {
+			return err
+		}
+	}
+
+	return rs.rollupRepo.DeleteHours(ctx, now.Add(-rs.retentionConfig.HourlyPeriod).Truncate(day))
+}
+
+func inPartitions(partitions []repository.EventPartition, t time.Time) bool {
+	for _, partition := range partitions {
+		if !t.Before(partition.From) && t.Before(partition.To) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rs *retentionService) rollupDay(ctx context.Context, from, to time.Time, deleteEvents bool) error {
+	logrus.Debugf("rolling up events of %s", from.Format("2006-01-02"))
+
+	if err := rs.rollupRepo.RollupHours(ctx, from, to); err != nil {
+		return err
+	}
+	if err := rs.rollupRepo.RollupDays(ctx, from, to); err != nil {
+		return err
+	}
+	if !deleteEvents {
+		return nil
+	}
+
+	for {
+		deleted, err := rs.healthcheckEventRepo.DeleteBetween(ctx, from, to, rs.retentionConfig.BatchSize)
+		if err != nil {
+			return err
+		}
+		if deleted < int64(rs.retentionConfig.BatchSize) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/therealak12/api-health-check/repository"
+	"github.com/therealak12/api-health-check/tracing"
+)
+
+// runBodyLimit is the number of bytes of the response body returned by ad-hoc runs.
+const runBodyLimit = 4096
+
+// ErrInvalidHealthcheck indicates the healthcheck can't be run, e.g. its request can't be built.
+var ErrInvalidHealthcheck = errors.New("invalid healthcheck")
+
+// RunResult is the full result of an ad-hoc run of a healthcheck.
+type RunResult struct {
+	Status        string             `json:"status"`
+	State         string             `json:"state"`
+	StatusCode    int                `json:"statusCode,omitempty"`
+	Latency       repository.Latency `json:"latency"`
+	CertExpiry    *time.Time         `json:"certExpiry,omitempty"`
+	Headers       http.Header        `json:"headers,omitempty"`
+	Body          string             `json:"body,omitempty"`
+	BodyTruncated bool               `json:"bodyTruncated,omitempty"`
+	Assertions    []AssertionResult  `json:"assertions"`
+	// Recorded reports whether the event of the run was recorded.
+	Recorded bool   `json:"recorded"`
+	TraceID  string `json:"traceId,omitempty"`
+}
+
+func (hs *healthcheckService) Run(ctx context.Context, healthcheck repository.Healthcheck,
+	record bool) (RunResult, error) {
+	if record && healthcheck.ID == 0 {
+		return RunResult{}, fmt.Errorf("%w: unsaved healthchecks can't be recorded", ErrInvalidHealthcheck)
+	}
+
+	var check checkFunc
+	switch healthcheck.Kind {
+	case repository.KindComposite:
+		check = hs.newCompositeCheck(healthcheck)
+	case repository.KindHeartbeat:
+		check = hs.newHeartbeatEvaluation(healthcheck)
+	default:
+		var err error
+		check, err = hs.newHTTPCheck(healthcheck, runBodyLimit)
+		if err != nil {
+			return RunResult{}, fmt.Errorf("%w: %s", ErrInvalidHealthcheck, err)
+		}
+	}
+
+	ctx, span := hs.tracer.Start(ctx, "healthcheck.run", tracing.SpanKindInternal)
+	defer span.End()
+	span.SetAttribute("healthcheck.id", healthcheck.ID)
+	span.SetAttribute("healthcheck.kind", healthcheck.Kind)
+
+	result, err := check(ctx)
+	if err != nil {
+		span.SetError(err)
+		return RunResult{}, err
+	}
+	if record {
+		hs.record(ctx, healthcheck, result)
+	}
+
+	runResult := RunResult{
+		Status:        result.Status,
+		State:         result.State,
+		StatusCode:    result.StatusCode,
+		Latency:       result.Latency,
+		Headers:       result.Header,
+		Body:          string(result.Body),
+		BodyTruncated: result.BodyTruncated,
+		Assertions:    result.Assertions,
+		Recorded:      record,
+	}
+	if !result.CertExpiry.IsZero() {
+		runResult.CertExpiry = &result.CertExpiry
+	}
+	if traceID := span.Context().TraceID; traceID.IsValid() {
+		runResult.TraceID = traceID.String()
+	}
+
+	return runResult, nil
+}
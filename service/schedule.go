@@ -0,0 +1,189 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// ErrInvalidSchedule indicates the interval, cron expression, timezone or active hours of a healthcheck
+// are not valid.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// maxScheduleIterations bounds the search for a run in the active hours, so schedules whose runs never
+// fall in them don't loop forever.
+const maxScheduleIterations = 10000
+
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month |
+	cron.Dow | cron.Descriptor)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule computes the run times of a healthcheck.
+type Schedule interface {
+	// Next returns the first run time after t, the zero time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// everySchedule runs at a fixed interval.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// window is an active hours window, from and to are offsets from the start of the day.
+type window struct {
+	days     map[time.Weekday]bool
+	from, to time.Duration
+}
+
+// activeSchedule limits the runs of a schedule to the active hours windows.
+type activeSchedule struct {
+	schedule Schedule
+	location *time.Location
+	windows  []window
+}
+
+func (s activeSchedule) Next(t time.Time) time.Time {
+	for i := 0; i < maxScheduleIterations; i++ {
+		next := s.schedule.Next(t)
+		if next.IsZero() {
+			return next
+		}
+
+		start, active := s.nextWindow(next)
+		if active {
+			return next
+		}
+		if start.IsZero() {
+			return time.Time{}
+		}
+		// intervals restart at the window start, cron runs continue from it.
+		if _, ok := s.schedule.(everySchedule); ok {
+			return start
+		}
+		t = start.Add(-time.Nanosecond)
+	}
+	return time.Time{}
+}
+
+// nextWindow reports whether t is in a window, otherwise it returns the start of the next window.
+func (s activeSchedule) nextWindow(t time.Time) (time.Time, bool) {
+	local := t.In(s.location)
+
+	var next time.Time
+	// a window of the previous day may span midnight.
+	for day := -1; day <= 7; day++ {
+		date := time.Date(local.Year(), local.Month(), local.Day()+day, 0, 0, 0, 0, s.location)
+		for _, w := range s.windows {
+			if len(w.days) > 0 && !w.days[date.Weekday()] {
+				continue
+			}
+			// the times are built from the wall clock, so the windows keep their hours on DST changes.
+			from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, int(w.from.Seconds()), 0, s.location)
+			to := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, int(w.to.Seconds()), 0, s.location)
+			if w.to <= w.from {
+				to = to.AddDate(0, 0, 1)
+			}
+
+			if !t.Before(from) && t.Before(to) {
+				return time.Time{}, true
+			}
+			if from.After(t) && (next.IsZero() || from.Before(next)) {
+				next = from
+			}
+		}
+	}
+	return next, false
+}
+
+// NewSchedule creates the schedule of a healthcheck from its cron expression or interval, limited to its
+// active hours.
+func NewSchedule(healthcheck repository.Healthcheck) (Schedule, error) {
+	location := time.Local
+	if healthcheck.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(healthcheck.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, healthcheck.Timezone)
+		}
+	}
+
+	var schedule Schedule
+	if healthcheck.CronExpression != "" {
+		if strings.Contains(healthcheck.CronExpression, "TZ=") {
+			return nil, fmt.Errorf("%w: set the timezone instead of using TZ in the cron expression",
+				ErrInvalidSchedule)
+		}
+		spec, err := cronParser.Parse(healthcheck.CronExpression)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSchedule, err)
+		}
+		if specSchedule, ok := spec.(*cron.SpecSchedule); ok {
+			specSchedule.Location = location
+		}
+		schedule = spec
+	} else {
+		if healthcheck.IntervalSeconds <= 0 {
+			return nil, fmt.Errorf("%w: IntervalSeconds must be positive", ErrInvalidSchedule)
+		}
+		schedule = everySchedule(time.Duration(healthcheck.IntervalSeconds) * time.Second)
+	}
+
+	if len(healthcheck.ActiveHours) == 0 {
+		return schedule, nil
+	}
+
+	windows := make([]window, 0, len(healthcheck.ActiveHours))
+	for _, activeHours := range healthcheck.ActiveHours {
+		w, err := parseWindow(activeHours)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+
+	return activeSchedule{schedule: schedule, location: location, windows: windows}, nil
+}
+
+func parseWindow(activeHours repository.ActiveHours) (window, error) {
+	w := window{days: make(map[time.Weekday]bool)}
+
+	for _, day := range activeHours.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return w, fmt.Errorf("%w: unknown day %q", ErrInvalidSchedule, day)
+		}
+		w.days[weekday] = true
+	}
+
+	var err error
+	if w.from, err = parseTimeOfDay(activeHours.From); err != nil {
+		return w, err
+	}
+	if w.to, err = parseTimeOfDay(activeHours.To); err != nil {
+		return w, err
+	}
+	return w, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid time of day %q, expected HH:MM", ErrInvalidSchedule, value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
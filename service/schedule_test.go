@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+	"time"
+	// the tests don't depend on the timezone database of the host.
+	_ "time/tzdata"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+const scheduleTimezone = "Europe/Berlin"
+
+func TestActiveScheduleNext(t *testing.T) {
+	location, err := time.LoadLocation(scheduleTimezone)
+	if err != nil {
+		t.Fatalf("failed to load %s: %s", scheduleTimezone, err)
+	}
+
+	overnight := []repository.ActiveHours{{From: "22:00", To: "06:00"}}
+	officeHours := []repository.ActiveHours{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, From: "09:00",
+		To: "17:00"}}
+	// 2022-03-27 02:00 doesn't exist in Berlin and 2022-10-30 02:00 happens twice.
+	tests := []struct {
+		name        string
+		interval    int
+		cron        string
+		activeHours []repository.ActiveHours
+		after       string
+		want        string
+	}{
+		{"interval in overnight window", 3600, "", overnight, "2022-05-10 23:30", "2022-05-11 00:30"},
+		{"interval leaves overnight window", 3600, "", overnight, "2022-05-11 05:30", "2022-05-11 22:00"},
+		{"interval waits for next weekday", 3600, "", officeHours, "2022-05-13 16:30", "2022-05-16 09:00"},
+		{"cron crosses midnight", 0, "0 * * * *", overnight, "2022-05-10 23:30", "2022-05-11 00:00"},
+		{"cron continues from window start", 0, "15 * * * *", officeHours, "2022-05-13 16:30", "2022-05-16 09:15"},
+		{"cron never in window", 0, "30 8 * * *", officeHours, "2022-05-10 10:00", ""},
+		{"window after spring forward", 3600, "", officeHours, "2022-03-25 17:00", "2022-03-28 09:00"},
+		{"overnight window over spring forward", 3600, "", overnight, "2022-03-27 00:30", "2022-03-27 01:30"},
+		{"interval skips missing hour", 3600, "", overnight, "2022-03-27 01:30", "2022-03-27 03:30"},
+		{"window after fall back", 3600, "", []repository.ActiveHours{{From: "09:00", To: "17:00"}},
+			"2022-10-29 17:30", "2022-10-30 09:00"},
+		{"cron over fall back", 0, "0 4 * * *", overnight, "2022-10-29 23:00", "2022-10-30 04:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := NewSchedule(repository.Healthcheck{
+				IntervalSeconds: test.interval,
+				CronExpression:  test.cron,
+				Timezone:        scheduleTimezone,
+				ActiveHours:     test.activeHours,
+			})
+			if err != nil {
+				t.Fatalf("failed to create schedule: %s", err)
+			}
+			after, err := time.ParseInLocation("2006-01-02 15:04", test.after, location)
+			if err != nil {
+				t.Fatalf("invalid time %q: %s", test.after, err)
+			}
+
+			next := schedule.Next(after)
+			got := ""
+			if !next.IsZero() {
+				got = next.In(location).Format("2006-01-02 15:04")
+			}
+			if got != test.want {
+				t.Errorf("next run is %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/therealak12/api-health-check/repository"
+)
+
+// ErrQuotaExceeded indicates a healthcheck doesn't fit in the quotas of its organization.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrProjectNotFound indicates the project of a healthcheck doesn't exist.
+var ErrProjectNotFound = errors.New("project not found")
+
+// minIntervalSamples is the number of runs of cron expressions the shortest interval is looked for in.
+const minIntervalSamples = 100
+
+// TenantService enforces the quotas of the organizations.
+type TenantService interface {
+	// CheckQuota checks the healthcheck is within the quotas of the organization of its project, the
+	// number of healthchecks is checked by CheckCount.
+	CheckQuota(ctx context.Context, healthcheck repository.Healthcheck) error
+	// CheckCount checks created more healthchecks fit in the organization of the project. The organization
+	// is locked until the transaction of the context ends, the healthchecks must be created in it.
+	CheckCount(ctx context.Context, projectID int, created int) error
+}
+
+type tenantService struct {
+	organizationRepo repository.OrganizationRepo
+	projectRepo      repository.ProjectRepo
+}
+
+var _ TenantService = &tenantService{}
+
+func NewTenantService(organizationRepo repository.OrganizationRepo,
+	projectRepo repository.ProjectRepo) TenantService {
+	return &tenantService{
+		organizationRepo: organizationRepo,
+		projectRepo:      projectRepo,
+	}
+}
+
+func (ts *tenantService) CheckQuota(ctx context.Context, healthcheck repository.Healthcheck) error {
+	organization, err := ts.organization(ctx, healthcheck.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	if organization.MinIntervalSeconds > 0 && healthcheck.Kind != repository.KindHeartbeat {
+		interval, err := minInterval(healthcheck)
+		if err != nil {
+			return err
+		}
+		if interval < time.Duration(organization.MinIntervalSeconds)*time.Second {
+			return fmt.Errorf("%w: the healthchecks of organization %d can't run more often than every %d seconds",
+				ErrQuotaExceeded, organization.ID, organization.MinIntervalSeconds)
+		}
+	}
+
+	return nil
+}
+
+func (ts *tenantService) CheckCount(ctx context.Context, projectID int, created int) error {
+	organization, err := ts.organization(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if organization.MaxHealthchecks == 0 {
+		return nil
+	}
+
+	if err := ts.organizationRepo.Lock(ctx, organization.ID); err != nil {
+		return err
+	}
+	count, err := ts.organizationRepo.CountHealthchecks(ctx, organization.ID)
+	if err != nil {
+		return err
+	}
+	if count+int64(created) > int64(organization.MaxHealthchecks) {
+		return fmt.Errorf("%w: organization %d has at most %d healthchecks", ErrQuotaExceeded,
+			organization.ID, organization.MaxHealthchecks)
+	}
+	return nil
+}
+
+// organization returns the organization of the project.
+func (ts *tenantService) organization(ctx context.Context, projectID int) (repository.Organization, error) {
+	project, err := ts.projectRepo.FindOne(ctx, projectID)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return repository.Organization{}, fmt.Errorf("%w: %d", ErrProjectNotFound, projectID)
+	}
+	if err != nil {
+		return repository.Organization{}, err
+	}
+	return ts.organizationRepo.FindOne(ctx, project.OrganizationID)
+}
+
+// minInterval returns the shortest time between two runs of the healthcheck. The active hours are ignored,
+// they only skip runs.
+func minInterval(healthcheck repository.Healthcheck) (time.Duration, error) {
+	if healthcheck.CronExpression == "" {
+		return time.Duration(healthcheck.IntervalSeconds) * time.Second, nil
+	}
+
+	healthcheck.ActiveHours = nil
+	schedule, err := NewSchedule(healthcheck)
+	if err != nil {
+		return 0, err
+	}
+
+	var shortest time.Duration
+	previous := schedule.Next(time.Now())
+	for i := 0; i < minIntervalSamples && !previous.IsZero(); i++ {
+		next := schedule.Next(previous)
+		if next.IsZero() {
+			break
+		}
+		if interval := next.Sub(previous); shortest == 0 || interval < shortest {
+			shortest = interval
+		}
+		previous = next
+	}
+	return shortest, nil
+}
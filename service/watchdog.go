@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/therealak12/api-health-check/config"
+	"github.com/therealak12/api-health-check/metrics"
+)
+
+// schedule is the scheduling state of a running healthcheck.
+type schedule struct {
+	// NextRunAt is the time the next run is due, zero if there is none.
+	NextRunAt time.Time
+	// generation tells the starts of a healthcheck apart, so a goroutine of a stopped start can't update
+	// the schedule of a later one.
+	generation uint64
+}
+
+type scheduleMap struct {
+	sync.Mutex
+	schedules  map[int]schedule
+	generation uint64
+}
+
+func newScheduleMap() *scheduleMap {
+	return &scheduleMap{
+		schedules: make(map[int]schedule),
+	}
+}
+
+// Start starts the schedule of the key and returns the generation its updates are made with.
+func (s *scheduleMap) Start(key int) uint64 {
+	s.Lock()
+	defer s.Unlock()
+	s.generation++
+	s.schedules[key] = schedule{generation: s.generation}
+	return s.generation
+}
+
+func (s *scheduleMap) Get(key int) (schedule, bool) {
+	s.Lock()
+	value, ok := s.schedules[key]
+	s.Unlock()
+	return value, ok
+}
+
+// Scheduled sets the next run of the key if its schedule is still of the given generation.
+func (s *scheduleMap) Scheduled(key int, generation uint64, next time.Time) {
+	s.Lock()
+	if value, ok := s.schedules[key]; ok && value.generation == generation {
+		value.NextRunAt = next
+		s.schedules[key] = value
+	}
+	s.Unlock()
+}
+
+func (s *scheduleMap) Delete(key int) {
+	s.Lock()
+	delete(s.schedules, key)
+	s.Unlock()
+}
+
+func (s *scheduleMap) All() map[int]schedule {
+	s.Lock()
+	defer s.Unlock()
+
+	result := make(map[int]schedule, len(s.schedules))
+	for key, value := range s.schedules {
+		result[key] = value
+	}
+	return result
+}
+
+var (
+	schedules = newScheduleMap()
+
+	stalledHealthchecks = metrics.NewGaugeVec(metrics.Default, "healthcheck_stalled",
+		"The number of running healthchecks whose runs stopped, as detected by the watchdog.")
+)
+
+// WatchdogReport is the result of the last watchdog check.
+type WatchdogReport struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	Running   int       `json:"running"`
+	// Stalled are the ids of the running healthchecks which haven't finished the run that was due longer
+	// than the stall timeout ago.
+	Stalled []int `json:"stalled"`
+}
+
+// WatchdogService detects the running healthchecks whose goroutines are stuck or stopped ticking.
+type WatchdogService interface {
+	// Start checks the running healthchecks every configured interval until the context is done.
+	Start(ctx context.Context)
+	Check() WatchdogReport
+	// Report returns the last report, its CheckedAt is zero if the watchdog hasn't run yet.
+	Report() WatchdogReport
+	// Verify fails if the watchdog has stopped running or its last report has stalled healthchecks.
+	Verify() error
+	// Alive fails if the watchdog has stopped running.
+	Alive() error
+}
+
+type watchdogService struct {
+	watchdogConfig config.Watchdog
+
+	mu     sync.Mutex
+	report WatchdogReport
+}
+
+var _ WatchdogService = &watchdogService{}
+
+func NewWatchdogService(watchdogConfig config.Watchdog) WatchdogService {
+	return &watchdogService{
+		watchdogConfig: watchdogConfig,
+	}
+}
+
+func (ws *watchdogService) Start(ctx context.Context) {
+	ticker := time.NewTicker(ws.watchdogConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		ws.Check()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ws *watchdogService) Check() WatchdogReport {
+	now := time.Now()
+	running := schedules.All()
+	report := WatchdogReport{CheckedAt: now, Running: len(running), Stalled: []int{}}
+
+	for id, s := range running {
+		if !s.NextRunAt.IsZero() && now.Sub(s.NextRunAt) > ws.watchdogConfig.StallTimeout {
+			report.Stalled = append(report.Stalled, id)
+		}
+	}
+	sort.Ints(report.Stalled)
+
+	ws.mu.Lock()
+	previous := ws.report.Stalled
+	ws.report = report
+	ws.mu.Unlock()
+
+	// log the healthchecks once when they stall.
+	for _, id := range report.Stalled {
+		if !containsInt(previous, id) {
+			logrus.Errorf("healthcheck %d stalled, its run was due at %s", id,
+				running[id].NextRunAt.Format(time.RFC3339))
+		}
+	}
+	stalledHealthchecks.Set(float64(len(report.Stalled)))
+
+	return report
+}
+
+func (ws *watchdogService) Report() WatchdogReport {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	return ws.report
+}
+
+func (ws *watchdogService) Verify() error {
+	if err := ws.Alive(); err != nil {
+		return err
+	}
+	if report := ws.Report(); len(report.Stalled) > 0 {
+		return fmt.Errorf("healthchecks %v stalled", report.Stalled)
+	}
+	return nil
+}
+
+func (ws *watchdogService) Alive() error {
+	report := ws.Report()
+	if report.CheckedAt.IsZero() {
+		return errors.New("watchdog hasn't run yet")
+	}
+	if since := time.Since(report.CheckedAt); since > 2*ws.watchdogConfig.Interval {
+		return fmt.Errorf("watchdog hasn't run for %s", since.Truncate(time.Second))
+	}
+	return nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
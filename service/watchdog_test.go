@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleMapIgnoresStoppedStarts(t *testing.T) {
+	s := newScheduleMap()
+	previous := s.Start(1)
+	s.Delete(1)
+	current := s.Start(1)
+
+	want := time.Date(2022, 5, 10, 12, 0, 0, 0, time.UTC)
+	s.Scheduled(1, current, want)
+	s.Scheduled(1, previous, want.Add(-time.Hour))
+
+	got, ok := s.Get(1)
+	if !ok {
+		t.Fatal("schedule is deleted, want it started")
+	}
+	if !got.NextRunAt.Equal(want) {
+		t.Errorf("next run is %s, want %s", got.NextRunAt, want)
+	}
+}
@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// EchoMiddleware starts a server span for every request, continuing the trace of the traceparent header.
+func EchoMiddleware(tracer *Tracer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := Extract(req.Context(), req.Header)
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", req.Method, c.Path()), SpanKindServer)
+			defer span.End()
+
+			span.SetAttribute("http.method", req.Method)
+			span.SetAttribute("http.route", c.Path())
+			span.SetAttribute("http.target", req.URL.RequestURI())
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+
+			status := c.Response().Status
+			if httpError, ok := err.(*echo.HTTPError); ok {
+				status = httpError.Code
+			}
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetError(fmt.Errorf("%s", http.StatusText(status)))
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxQueuedSpans limits the spans waiting for export, newer spans are dropped when it's reached.
+const maxQueuedSpans = 10000
+
+// Exporter sends the ended spans in batches to an OTLP/HTTP collector using the JSON encoding.
+type Exporter struct {
+	endpoint      string
+	serviceName   string
+	batchSize     int
+	flushInterval time.Duration
+	httpClient    *http.Client
+
+	mu    sync.Mutex
+	queue []*Span
+	full  chan struct{}
+}
+
+// NewExporter creates an exporter, endpoint is the traces url of the collector, e.g.
+// http://localhost:4318/v1/traces.
+func NewExporter(endpoint, serviceName string, batchSize int, flushInterval time.Duration) *Exporter {
+	return &Exporter{
+		endpoint:      endpoint,
+		serviceName:   serviceName,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		// the exporter requests must not be traced.
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		full:       make(chan struct{}, 1),
+	}
+}
+
+// Start exports the queued spans periodically until the context is done, then exports the remaining ones.
+func (e *Exporter) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := e.Flush(); err != nil {
+				logrus.Errorf("failed to export spans, err: %s", err)
+			}
+			return
+		case <-ticker.C:
+		case <-e.full:
+		}
+
+		if err := e.Flush(); err != nil {
+			logrus.Errorf("failed to export spans, err: %s", err)
+		}
+	}
+}
+
+func (e *Exporter) enqueue(span *Span) {
+	e.mu.Lock()
+	if len(e.queue) >= maxQueuedSpans {
+		e.mu.Unlock()
+		logrus.Warnf("dropping span %s, the export queue is full", span.name)
+		return
+	}
+	e.queue = append(e.queue, span)
+	full := len(e.queue) >= e.batchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush exports the queued spans.
+func (e *Exporter) Flush() error {
+	e.mu.Lock()
+	spans := e.queue
+	e.queue = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := e.batchSize
+		if n > len(spans) {
+			n = len(spans)
+		}
+		if err := e.export(spans[:n]); err != nil {
+			return err
+		}
+		spans = spans[n:]
+	}
+
+	return nil
+}
+
+func (e *Exporter) export(spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.httpClient.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// encode builds an OTLP ExportTraceServiceRequest.
+func (e *Exporter) encode(spans []*Span) map[string]interface{} {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mu.Lock()
+		s := otlpSpan{
+			TraceID:           span.context.TraceID.String(),
+			SpanID:            span.context.SpanID.String(),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Status:            otlpStatus{Code: span.status, Message: span.message},
+		}
+		if span.parent.IsValid() {
+			s.ParentSpanID = span.parent.String()
+		}
+		for key, value := range span.attributes {
+			s.Attributes = append(s.Attributes, otlpKeyValue{Key: key, Value: otlpValue(value)})
+		}
+		span.mu.Unlock()
+
+		encoded = append(encoded, s)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpKeyValue{{Key: "service.name", Value: otlpValue(e.serviceName)}},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "github.com/therealak12/api-health-check/tracing"},
+						"spans": encoded,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpValue(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(v)}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprint(v)}
+	}
+}
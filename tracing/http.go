@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Transport starts a client span for every request and propagates it using the traceparent header.
+type Transport struct {
+	Tracer *Tracer
+	// Base is the underlying transport, http.DefaultTransport when nil.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := t.Tracer.Start(req.Context(), fmt.Sprintf("HTTP %s", req.Method), SpanKindClient)
+	defer span.End()
+
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", req.URL.String())
+
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return resp, err
+	}
+
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetError(fmt.Errorf("%s", resp.Status))
+	}
+	return resp, nil
+}
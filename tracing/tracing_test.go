@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	remoteTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteSpanID  = "00f067aa0ba902b7"
+)
+
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+}
+
+// collector is an OTLP/HTTP collector which keeps the received spans by name.
+type collector struct {
+	mu    sync.Mutex
+	spans map[string]exportedSpan
+}
+
+func newCollector(t *testing.T) (*collector, *httptest.Server) {
+	c := &collector{spans: make(map[string]exportedSpan)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid export request: %s", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, resourceSpans := range body.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					c.spans[span.Name] = span
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return c, server
+}
+
+// startTrace starts a server span continuing the remote trace of the traceparent and a child of it, and
+// exports them.
+func startTrace(t *testing.T, endpoint, traceparent string) http.Header {
+	exporter := NewExporter(endpoint, "test", 10, time.Minute)
+	tracer := NewTracer(exporter)
+
+	header := http.Header{}
+	header.Set(TraceparentHeader, traceparent)
+	ctx, server := tracer.Start(Extract(context.Background(), header), "server", SpanKindServer)
+	ctx, child := tracer.Start(ctx, "child", SpanKindClient)
+
+	outgoing := http.Header{}
+	Inject(ctx, outgoing)
+	child.End()
+	server.End()
+
+	if err := exporter.Flush(); err != nil {
+		t.Fatalf("failed to export spans: %s", err)
+	}
+	return outgoing
+}
+
+func TestExportContinuesRemoteTrace(t *testing.T) {
+	c, server := newCollector(t)
+
+	outgoing := startTrace(t, server.URL, "00-"+remoteTraceID+"-"+remoteSpanID+"-01")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	serverSpan, ok := c.spans["server"]
+	if !ok {
+		t.Fatal("server span isn't exported")
+	}
+	childSpan, ok := c.spans["child"]
+	if !ok {
+		t.Fatal("child span isn't exported")
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"server trace id", serverSpan.TraceID, remoteTraceID},
+		{"server parent span id", serverSpan.ParentSpanID, remoteSpanID},
+		{"child trace id", childSpan.TraceID, remoteTraceID},
+		{"child parent span id", childSpan.ParentSpanID, serverSpan.SpanID},
+		{"outgoing traceparent", outgoing.Get(TraceparentHeader),
+			"00-" + remoteTraceID + "-" + childSpan.SpanID + "-01"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s is %q, want %q", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestUnsampledTraceIsNotExported(t *testing.T) {
+	c, server := newCollector(t)
+
+	outgoing := startTrace(t, server.URL, "00-"+remoteTraceID+"-"+remoteSpanID+"-00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.spans) != 0 {
+		t.Errorf("exported %d spans of an unsampled trace", len(c.spans))
+	}
+	sc, ok := parseTraceparent(outgoing.Get(TraceparentHeader))
+	if !ok || sc.Sampled || sc.TraceID.String() != remoteTraceID {
+		t.Errorf("outgoing traceparent %q doesn't continue the unsampled trace", outgoing.Get(TraceparentHeader))
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		valid       bool
+		sampled     bool
+	}{
+		{"sampled", "00-" + remoteTraceID + "-" + remoteSpanID + "-01", true, true},
+		{"unsampled", "00-" + remoteTraceID + "-" + remoteSpanID + "-00", true, false},
+		{"other flags", "00-" + remoteTraceID + "-" + remoteSpanID + "-03", true, true},
+		{"future version", "01-" + remoteTraceID + "-" + remoteSpanID + "-01-extra", true, true},
+		{"invalid version", "ff-" + remoteTraceID + "-" + remoteSpanID + "-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-" + remoteSpanID + "-01", false, false},
+		{"short span id", "00-" + remoteTraceID + "-00f067aa-01", false, false},
+		{"not hex", "00-" + remoteTraceID + "-" + remoteSpanID + "-zz", false, false},
+		{"empty", "", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sc, ok := parseTraceparent(test.traceparent)
+			if ok != test.valid {
+				t.Fatalf("valid is %v, want %v", ok, test.valid)
+			}
+			if ok && sc.Sampled != test.sampled {
+				t.Errorf("sampled is %v, want %v", sc.Sampled, test.sampled)
+			}
+		})
+	}
+}